@@ -10,6 +10,7 @@ import (
 	"github.com/favonia/cloudflare-ddns/internal/config"
 	"github.com/favonia/cloudflare-ddns/internal/cron"
 	"github.com/favonia/cloudflare-ddns/internal/heartbeat"
+	"github.com/favonia/cloudflare-ddns/internal/netwatch"
 	"github.com/favonia/cloudflare-ddns/internal/notifier"
 	"github.com/favonia/cloudflare-ddns/internal/pp"
 	"github.com/favonia/cloudflare-ddns/internal/setter"
@@ -56,7 +57,7 @@ func initConfig(ppfmt pp.PP, hb heartbeat.Heartbeat, nt notifier.Notifier) (*con
 	}
 
 	// Get the setter.
-	s, ok := setter.New(ppfmt, h)
+	s, ok := setter.New(ppfmt, h, builtConfig.Update.DryRun)
 	if !ok {
 		return builtConfig, nil, false
 	}
@@ -126,7 +127,11 @@ func realMain() int {
 	updateConfig := builtConfig.Update
 	// If UPDATE_CRON is not `@once` (not single-run mode), then send a notification to signal the start.
 	if lifecycleConfig.UpdateCron != nil {
-		nt.Send(ctx, ppfmt, notifier.NewMessagef("Started running Cloudflare DDNS."))
+		if updateConfig.DryRun {
+			nt.Send(ctx, ppfmt, notifier.NewMessagef("Started running Cloudflare DDNS in the dry-run mode."))
+		} else {
+			nt.Send(ctx, ppfmt, notifier.NewMessagef("Started running Cloudflare DDNS."))
+		}
 	}
 
 	// Without the following line, the quiet mode can be too quiet, and some system (Portainer)
@@ -140,6 +145,19 @@ func realMain() int {
 		ppfmt.Noticef(pp.EmojiMute, "Quiet mode enabled")
 	}
 
+	// The tracker remembers the detected IP addresses so that changes can be confirmed before publishing them.
+	tracker := updater.NewChangeTracker(time.Now)
+
+	// The watcher wakes up the main loop when network addresses or routes change.
+	// A nil channel (when the watcher is disabled or fails to start) never fires.
+	// The watcher only reports its failure through watchErrors; it is logged here so that
+	// all the logging happens in the main goroutine.
+	var networkChanges <-chan struct{}
+	var watchErrors <-chan error
+	if lifecycleConfig.UpdateOnNetworkChange && lifecycleConfig.UpdateCron != nil {
+		networkChanges, watchErrors, _ = netwatch.Watch(ctxWithSignals, ppfmt, netwatch.DefaultDebounce)
+	}
+
 	first := true
 	for {
 		// The next time to run the updater.
@@ -148,12 +166,16 @@ func realMain() int {
 
 		// Update the IP addresses
 		if first && !lifecycleConfig.UpdateOnStart {
-			hb.Ping(ctx, ppfmt, heartbeat.NewMessagef(true, "Started (no action)"))
+			if updateConfig.DryRun {
+				hb.Ping(ctx, ppfmt, heartbeat.NewMessagef(true, "Started in the dry-run mode (no action)"))
+			} else {
+				hb.Ping(ctx, ppfmt, heartbeat.NewMessagef(true, "Started (no action)"))
+			}
 		} else {
 			// Improve readability of the logging by separating each round of checks with blank lines.
 			ppfmt.BlankLineIfVerbose()
 
-			msg := updater.UpdateIPs(ctxWithSignals, ppfmt, updateConfig, s)
+			msg := tracker.UpdateIPs(ctxWithSignals, ppfmt, updateConfig, s)
 			hb.Ping(ctx, ppfmt, msg.HeartbeatMessage)
 			nt.Send(ctx, ppfmt, msg.NotifierMessage)
 		}
@@ -189,12 +211,18 @@ func realMain() int {
 			return 1
 		}
 
-		// Display the remaining time interval
-		cron.PrintCountdown(ppfmt, "Checking the IP addresses", time.Now(), next)
+		// Check again sooner if a change of IP addresses is waiting for confirmation
+		if recheck := tracker.NextRecheck(updateConfig); !recheck.IsZero() && recheck.Before(next) {
+			next = recheck
+			cron.PrintCountdown(ppfmt, "Re-checking the IP addresses", time.Now(), next)
+		} else {
+			// Display the remaining time interval
+			cron.PrintCountdown(ppfmt, "Checking the IP addresses", time.Now(), next)
+		}
 
 	signaled:
-		// Wait for the next signal or the alarm, whichever comes first
-		if sig.WaitForSignalsUntil(ppfmt, next) {
+		// Wait for the next signal, the alarm, or a network change, whichever comes first
+		if sig.WaitForSignalsUntil(ppfmt, next, networkChanges) {
 			stopUpdating(ctx, ppfmt, lifecycleConfig, updateConfig, hb, nt, s)
 			hb.Exit(ctx, ppfmt, "Stopped")
 			if lifecycleConfig.UpdateCron != nil {
@@ -203,5 +231,14 @@ func realMain() int {
 			ppfmt.Infof(pp.EmojiBye, "Bye!")
 			return 0
 		}
+		select {
+		case err := <-watchErrors:
+			ppfmt.Noticef(pp.EmojiError, "Stopped watching network changes: %v", err)
+			networkChanges, watchErrors = nil, nil
+		default:
+			if time.Now().Before(next) {
+				ppfmt.Infof(pp.EmojiNow, "Checking the IP addresses now because the network changed")
+			}
+		}
 	} // mainLoop
 }
@@ -30,11 +30,16 @@ func resetInitConfigEnv(t *testing.T) {
 	for _, key := range []string{
 		"CLOUDFLARE_API_TOKEN", "CLOUDFLARE_API_TOKEN_FILE",
 		"CF_API_TOKEN", "CF_API_TOKEN_FILE", "CF_ACCOUNT_ID",
+		"API_BACKEND", "RFC2136_SERVER", "RFC2136_TSIG_KEY_NAME", "RFC2136_TSIG_ALGORITHM",
+		"RFC2136_TSIG_SECRET", "RFC2136_TSIG_SECRET_FILE",
+		"POWERDNS_API_URL", "POWERDNS_API_KEY", "POWERDNS_API_KEY_FILE", "POWERDNS_SERVER_ID",
 		"IP4_PROVIDER", "IP6_PROVIDER",
 		"DOMAINS", "IP4_DOMAINS", "IP6_DOMAINS", "WAF_LISTS",
 		"UPDATE_CRON",
 		"UPDATE_ON_START",
 		"DELETE_ON_STOP",
+		"UPDATE_ON_NETWORK_CHANGE",
+		"DRY_RUN",
 		"CACHE_EXPIRATION",
 		"TTL",
 		"PROXIED",
@@ -213,6 +218,7 @@ func TestStopUpdatingDeleteOnStop(t *testing.T) {
 		UpdateTimeout:      time.Second,
 	}
 
+	mockSetter.EXPECT().StartRound()
 	mockSetter.EXPECT().FinalDelete(gomock.Any(), ppfmt, ipnet.IP4, domain4, params).Return(setter.ResponseUpdated)
 	mockSetter.EXPECT().FinalClearWAFList(gomock.Any(), ppfmt, wafList, "managed list").Return(setter.ResponseUpdated)
 	mockHeartbeat.EXPECT().Log(gomock.Any(), ppfmt, gomock.Any()).DoAndReturn(
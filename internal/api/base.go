@@ -77,7 +77,9 @@ type HandleOptions struct {
 }
 
 // A Handle represents a generic API to update DNS records and WAF lists.
-// Currently, the only implementation is Cloudflare.
+// The implementations are [CloudflareHandle] for the Cloudflare API,
+// [RFC2136Handle] for dynamic updates to DNS servers, and [PowerDNSHandle]
+// for the HTTP API of PowerDNS.
 type Handle interface {
 	// ListRecords lists managed DNS records matching the given domain/IP-family scope.
 	// The managed-record selector is bound into the handle options because
@@ -133,3 +135,78 @@ type Auth interface {
 	// New uses the authentication information to create a Handle.
 	New(ppfmt pp.PP, options HandleOptions) (Handle, bool)
 }
+
+// DNSServerDefaultTTL is the TTL of new records on DNS servers other than Cloudflare
+// when TTL=1 (auto), because the automatic TTL only makes sense for Cloudflare.
+const DNSServerDefaultTTL TTL = 300
+
+// dnsServerTTL replaces TTL=1 (auto) with [DNSServerDefaultTTL].
+func dnsServerTTL(ttl TTL) TTL {
+	if ttl == TTLAuto {
+		return DNSServerDefaultTTL
+	}
+	return ttl
+}
+
+// hintDNSServerMismatchedTTL warns about the TTL of an RRset on a DNS server other than Cloudflare.
+// The records in an RRset share the same TTL (RFC 2181, Section 5.2).
+func hintDNSServerMismatchedTTL(ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain, server string, current, expected TTL) {
+	ppfmt.Noticef(pp.EmojiUserWarning,
+		"The TTL for the %s records of %s is %s. However, it is expected to be %s. You can either change the TTL on %s or change the expected TTL with TTL=%d.", //nolint:lll
+		ipNet.RecordType(), domain.Describe(), current.Describe(), expected.Describe(), server, current.Int(),
+	)
+}
+
+// hintDNSServerMismatchedComment warns about the comment of an RRset on a DNS server other than Cloudflare.
+func hintDNSServerMismatchedComment(ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain, server string,
+	current, expected string,
+) {
+	ppfmt.Noticef(pp.EmojiUserWarning,
+		"The comment for the %s records of %s is %s. However, it is expected to be %s. You can either change the comment on %s or change the value of RECORD_COMMENT to match the current comment.", //nolint:lll
+		ipNet.RecordType(), domain.Describe(), DescribeFreeFormString(current), DescribeFreeFormString(expected), server,
+	)
+}
+
+// hintProxiedOnlyWithCloudflare warns (once) that records on other DNS servers cannot be proxied.
+func hintProxiedOnlyWithCloudflare(ppfmt pp.PP) {
+	ppfmt.NoticeOncef(pp.MessageProxiedOnlyWithCloudflare, pp.EmojiUserWarning,
+		"DNS records cannot be proxied because proxying is only available with Cloudflare; PROXIED is ignored")
+}
+
+// noticeWAFListsOnlyWithCloudflare reports that WAF lists are a Cloudflare feature.
+func noticeWAFListsOnlyWithCloudflare(ppfmt pp.PP, list WAFList) {
+	ppfmt.Noticef(pp.EmojiUserError,
+		"The WAF list %s cannot be managed because WAF lists are only available with Cloudflare", list.Describe())
+}
+
+// noWAFLists implements the WAF-list methods of [Handle] for DNS servers other than Cloudflare,
+// which always fail because WAF lists are only available with Cloudflare.
+type noWAFLists struct{}
+
+// ListWAFListItems reports that WAF lists are not available.
+func (noWAFLists) ListWAFListItems(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+) ([]WAFListItem, bool, bool, bool) {
+	noticeWAFListsOnlyWithCloudflare(ppfmt, list)
+	return nil, false, false, false
+}
+
+// FinalClearWAFListAsync reports that WAF lists are not available.
+func (noWAFLists) FinalClearWAFListAsync(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+) (bool, bool) {
+	noticeWAFListsOnlyWithCloudflare(ppfmt, list)
+	return false, false
+}
+
+// DeleteWAFListItems reports that WAF lists are not available.
+func (noWAFLists) DeleteWAFListItems(_ context.Context, ppfmt pp.PP, list WAFList, _ string, _ []ID) bool {
+	noticeWAFListsOnlyWithCloudflare(ppfmt, list)
+	return false
+}
+
+// CreateWAFListItems reports that WAF lists are not available.
+func (noWAFLists) CreateWAFListItems(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+	_ []netip.Prefix, _ string,
+) bool {
+	noticeWAFListsOnlyWithCloudflare(ppfmt, list)
+	return false
+}
@@ -1,13 +1,21 @@
 package api_test
 
+// vim: nowrap
+
 import (
+	"context"
 	"slices"
 	"testing"
 	"testing/quick"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 
 	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
 )
 
 func TestCompareWAFList(t *testing.T) {
@@ -28,3 +36,56 @@ func TestCompareWAFList(t *testing.T) {
 		nil,
 	))
 }
+
+// newDNSServerHandles creates handles for all the DNS servers other than Cloudflare.
+func newDNSServerHandles(t *testing.T) map[string]api.Handle {
+	t.Helper()
+
+	return map[string]api.Handle{
+		"rfc2136":  newRFC2136Handle(t, newDNSServer(t, mockTSIGKey()), mockTSIGKey()),
+		"powerdns": newPowerDNSHandle(t, newPowerDNSServer(t), powerDNSOptions("")),
+	}
+}
+
+func TestDNSServerInvalidID(t *testing.T) {
+	t.Parallel()
+
+	for name, h := range newDNSServerHandles(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockPP.EXPECT().Noticef(pp.EmojiImpossible, "Failed to parse the IP address in the ID %s: %v", api.ID("record"), gomock.Any()).Times(2)
+
+			ok := h.DeleteRecord(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), "record", api.RegularDelitionMode)
+			require.False(t, ok)
+			ok = h.UpdateRecord(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), "record", mustIP("192.0.2.1"),
+				api.RecordParams{TTL: 300, Proxied: false, Comment: ""}, api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestDNSServerWAFLists(t *testing.T) {
+	t.Parallel()
+
+	list := api.WAFList{AccountID: "account", Name: "list"}
+
+	for name, h := range newDNSServerHandles(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockPP.EXPECT().Noticef(pp.EmojiUserError, "The WAF list %s cannot be managed because WAF lists are only available with Cloudflare", "account/list").Times(4)
+
+			_, _, _, ok := h.ListWAFListItems(context.Background(), mockPP, list, "")
+			require.False(t, ok)
+			_, ok = h.FinalClearWAFListAsync(context.Background(), mockPP, list, "")
+			require.False(t, ok)
+			require.False(t, h.DeleteWAFListItems(context.Background(), mockPP, list, "", nil))
+			require.False(t, h.CreateWAFListItems(context.Background(), mockPP, list, "", nil, ""))
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// DryRunIDPrefix is the prefix of the IDs of the records and list items
+// that would have been created in the dry-run mode.
+const DryRunIDPrefix = "dry-run:"
+
+// DryRunCache holds the in-memory view of the records and lists after the planned changes.
+type DryRunCache = struct {
+	listRecords   map[ipnet.Type]*ttlcache.Cache[string, *[]Record] // domain names to records
+	listListItems *ttlcache.Cache[WAFList, *[]WAFListItem]          // lists to list items
+}
+
+// A DryRunHandle implements the [Handle] interface by reading from another handle
+// and only logging the changes that would be made. The planned changes are kept in
+// an in-memory view so that later steps see a consistent state, as if they were made.
+// The view should be reset with [DryRunHandle.ResetView] before each round of updating.
+type DryRunHandle struct {
+	handle  Handle
+	options HandleOptions
+	cache   DryRunCache
+}
+
+// wafListFinder is implemented by handles that can check the existence of
+// a WAF list without creating it, such as [CloudflareHandle].
+type wafListFinder interface {
+	WAFListID(ctx context.Context, ppfmt pp.PP, list WAFList, expectedDescription string) (ID, bool, bool)
+}
+
+var _ wafListFinder = CloudflareHandle{} //nolint:exhaustruct
+
+// NewDryRunHandle wraps a handle so that nothing will be changed.
+func NewDryRunHandle(handle Handle, options HandleOptions) DryRunHandle {
+	return DryRunHandle{
+		handle:  handle,
+		options: options,
+		cache: DryRunCache{
+			listRecords: map[ipnet.Type]*ttlcache.Cache[string, *[]Record]{
+				ipnet.IP4: newCache[string, *[]Record](options.CacheExpiration),
+				ipnet.IP6: newCache[string, *[]Record](options.CacheExpiration),
+			},
+			listListItems: newCache[WAFList, *[]WAFListItem](options.CacheExpiration),
+		},
+	}
+}
+
+// ResetView forgets the planned changes. The next reading goes to the wrapped handle again.
+func (h DryRunHandle) ResetView() {
+	for _, cache := range h.cache.listRecords {
+		cache.DeleteAll()
+	}
+	h.cache.listListItems.DeleteAll()
+}
+
+// A DryRunAuth implements the [Auth] interface, wrapping the handle created by
+// another [Auth] with [NewDryRunHandle].
+type DryRunAuth struct {
+	Auth Auth
+}
+
+// New creates a [DryRunHandle] from another [Auth].
+func (a DryRunAuth) New(ppfmt pp.PP, options HandleOptions) (Handle, bool) {
+	handle, ok := a.Auth.New(ppfmt, options)
+	if !ok {
+		return nil, false
+	}
+
+	return NewDryRunHandle(handle, options), true
+}
+
+// ListRecords returns the records in the in-memory view, reading them from the wrapped handle if needed.
+func (h DryRunHandle) ListRecords(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain,
+	expectedParams RecordParams,
+) ([]Record, bool, bool) {
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		return *rs.Value(), true, true
+	}
+
+	rs, cached, ok := h.handle.ListRecords(ctx, ppfmt, ipNet, domain, expectedParams)
+	if !ok {
+		return nil, false, false
+	}
+
+	view := slices.Clone(rs)
+	h.cache.listRecords[ipNet].DeleteExpired()
+	h.cache.listRecords[ipNet].Set(domain.DNSNameASCII(), &view, ttlcache.DefaultTTL)
+
+	return rs, cached, true
+}
+
+// UpdateRecord logs the update and changes the in-memory view.
+func (h DryRunHandle) UpdateRecord(_ context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID, ip netip.Addr,
+	_, _ RecordParams,
+) bool {
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would update a stale %s record of %s (ID: %s) to %s",
+		ipNet.RecordType(), domain.Describe(), id, ip)
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		for i, r := range *rs.Value() {
+			if r.ID == id {
+				(*rs.Value())[i].IP = ip
+			}
+		}
+	}
+
+	return true
+}
+
+// CreateRecord logs the creation and changes the in-memory view.
+func (h DryRunHandle) CreateRecord(_ context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, ip netip.Addr, params RecordParams,
+) (ID, bool) {
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would add a new %s record of %s with %s",
+		ipNet.RecordType(), domain.Describe(), ip)
+
+	id := ID(DryRunIDPrefix + ip.String())
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil &&
+		matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, params.Comment) {
+		*rs.Value() = append([]Record{{ID: id, IP: ip, RecordParams: params}}, *rs.Value()...)
+	}
+
+	return id, true
+}
+
+// DeleteRecord logs the deletion and changes the in-memory view.
+func (h DryRunHandle) DeleteRecord(_ context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID, _ DeletionMode,
+) bool {
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would delete a stale %s record of %s (ID: %s)",
+		ipNet.RecordType(), domain.Describe(), id)
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		*rs.Value() = slices.DeleteFunc(*rs.Value(), func(r Record) bool { return r.ID == id })
+	}
+
+	return true
+}
+
+// ListWAFListItems returns the items in the in-memory view, reading them from the wrapped handle if needed.
+// A missing list is not created; instead, an empty list is added to the view.
+func (h DryRunHandle) ListWAFListItems(ctx context.Context, ppfmt pp.PP, list WAFList, expectedDescription string,
+) ([]WAFListItem, bool, bool, bool) {
+	if items := h.cache.listListItems.Get(list); items != nil {
+		return *items.Value(), true, true, true
+	}
+
+	if finder, ok := h.handle.(wafListFinder); ok {
+		_, found, ok := finder.WAFListID(ctx, ppfmt, list, expectedDescription)
+		if !ok {
+			ppfmt.Noticef(pp.EmojiError, "Failed to check the existence of the list %s", list.Describe())
+			return nil, false, false, false
+		}
+		if !found {
+			ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would create the list %s", list.Describe())
+
+			items := []WAFListItem{}
+			h.cache.listListItems.DeleteExpired()
+			h.cache.listListItems.Set(list, &items, ttlcache.DefaultTTL)
+			return items, false, false, true
+		}
+	}
+
+	items, alreadyExisting, cached, ok := h.handle.ListWAFListItems(ctx, ppfmt, list, expectedDescription)
+	if !ok {
+		return nil, false, false, false
+	}
+
+	view := slices.Clone(items)
+	h.cache.listListItems.DeleteExpired()
+	h.cache.listListItems.Set(list, &view, ttlcache.DefaultTTL)
+
+	return items, alreadyExisting, cached, true
+}
+
+// FinalClearWAFListAsync logs the deletion of the list and removes it from the in-memory view.
+func (h DryRunHandle) FinalClearWAFListAsync(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+) (bool, bool) {
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would delete the list %s", list.Describe())
+
+	h.cache.listListItems.Delete(list)
+
+	return true, true
+}
+
+// DeleteWAFListItems logs the deletion of the items and changes the in-memory view.
+func (h DryRunHandle) DeleteWAFListItems(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+	ids []ID,
+) bool {
+	if len(ids) == 0 {
+		return true
+	}
+
+	// Items not in the view are described by their IDs.
+	describe := ID.String
+	if items := h.cache.listListItems.Get(list); items != nil {
+		prefixes := map[ID]netip.Prefix{}
+		for _, item := range *items.Value() {
+			prefixes[item.ID] = item.Prefix
+		}
+		describe = func(id ID) string {
+			if prefix, found := prefixes[id]; found {
+				return ipnet.DescribePrefixOrIP(prefix)
+			}
+			return id.String()
+		}
+		*items.Value() = slices.DeleteFunc(*items.Value(), func(item WAFListItem) bool { return slices.Contains(ids, item.ID) })
+	}
+
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would delete %s from the list %s", pp.JoinMap(describe, ids), list.Describe())
+
+	return true
+}
+
+// CreateWAFListItems logs the creation of the items and changes the in-memory view.
+func (h DryRunHandle) CreateWAFListItems(_ context.Context, ppfmt pp.PP, list WAFList, _ string,
+	itemsToCreate []netip.Prefix, _ string,
+) bool {
+	if len(itemsToCreate) == 0 {
+		return true
+	}
+
+	ppfmt.Noticef(pp.EmojiDryRun, "Dry run: would add %s to the list %s",
+		pp.JoinMap(ipnet.DescribePrefixOrIP, itemsToCreate), list.Describe())
+
+	if items := h.cache.listListItems.Get(list); items != nil {
+		for _, prefix := range itemsToCreate {
+			*items.Value() = append(*items.Value(), WAFListItem{
+				ID:     ID(DryRunIDPrefix + ipnet.DescribePrefixOrIP(prefix)),
+				Prefix: prefix,
+			})
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,237 @@
+package api_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+func TestDryRunAuthNew(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	server := newPowerDNSServer(t)
+	h, ok := api.DryRunAuth{Auth: api.PowerDNSAuth{BaseURL: server.url(), APIKey: powerDNSAPIKey, ServerID: ""}}.New(mockPP, powerDNSOptions(""))
+	require.True(t, ok)
+	require.IsType(t, api.DryRunHandle{}, h) //nolint:exhaustruct
+
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, "The PowerDNS API URL %q does not look like a valid URL", "")
+	h, ok = api.DryRunAuth{Auth: api.PowerDNSAuth{BaseURL: "", APIKey: powerDNSAPIKey, ServerID: ""}}.New(mockPP, powerDNSOptions(""))
+	require.False(t, ok)
+	require.Nil(t, h)
+}
+
+func TestDryRunRecords(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records:  []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, {Content: "192.0.2.2", Disabled: false}},
+		Comments: []powerDNSComment{{Content: "ddns", Account: "", ModifiedAt: 1}},
+	})
+	h := api.NewDryRunHandle(newPowerDNSHandle(t, server, powerDNSOptions("")), powerDNSOptions(""))
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	ctx := context.Background()
+	dom := domain.FQDN("www.example.org")
+	params := api.RecordParams{TTL: 300, Proxied: false, Comment: "ddns"}
+
+	rs, cached, ok := h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.False(t, cached)
+	require.Len(t, rs, 2)
+
+	gomock.InOrder(
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would update a stale %s record of %s (ID: %s) to %s", "A", "www.example.org", api.ID("192.0.2.1"), mustIP("192.0.2.3")),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would delete a stale %s record of %s (ID: %s)", "A", "www.example.org", api.ID("192.0.2.2")),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would add a new %s record of %s with %s", "A", "www.example.org", mustIP("192.0.2.4")),
+	)
+	require.True(t, h.UpdateRecord(ctx, mockPP, ipnet.IP4, dom, "192.0.2.1", mustIP("192.0.2.3"), params, params))
+	require.True(t, h.DeleteRecord(ctx, mockPP, ipnet.IP4, dom, "192.0.2.2", api.RegularDelitionMode))
+	id, ok := h.CreateRecord(ctx, mockPP, ipnet.IP4, dom, mustIP("192.0.2.4"), params)
+	require.True(t, ok)
+	require.Equal(t, api.ID(api.DryRunIDPrefix+"192.0.2.4"), id)
+
+	// Later steps see the planned changes.
+	rs, cached, ok = h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.True(t, cached)
+	require.Equal(t, []api.Record{
+		{ID: id, IP: mustIP("192.0.2.4"), RecordParams: params},
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.3"), RecordParams: params},
+	}, rs)
+
+	// Nothing was changed on the server.
+	require.Empty(t, server.acceptedPatches())
+	rrset, found := server.get("www.example.org.", "A")
+	require.True(t, found)
+	require.Equal(t, []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, {Content: "192.0.2.2", Disabled: false}}, rrset.Records)
+
+	// The next round starts from the records on the server again.
+	h.ResetView()
+	rs, _, ok = h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.ElementsMatch(t, []api.Record{
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: params},
+		{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: params},
+	}, rs)
+}
+
+// wafListFinderHandle is a mock handle that can also check the existence of WAF lists.
+type wafListFinderHandle struct {
+	*mocks.MockHandle
+
+	found, ok bool
+}
+
+func (h wafListFinderHandle) WAFListID(context.Context, pp.PP, api.WAFList, string) (api.ID, bool, bool) {
+	return "", h.found, h.ok
+}
+
+func TestDryRunWAFListItems(t *testing.T) {
+	t.Parallel()
+
+	list := api.WAFList{AccountID: "account", Name: "list"}
+	prefix1 := netip.MustParsePrefix("192.0.2.1/32")
+	prefix2 := netip.MustParsePrefix("2001:db8::/48")
+	prefix3 := netip.MustParsePrefix("192.0.2.3/32")
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockHandle := mocks.NewMockHandle(mockCtrl)
+	h := api.NewDryRunHandle(mockHandle, powerDNSOptions(""))
+
+	mockHandle.EXPECT().ListWAFListItems(ctx, mockPP, list, "description").
+		Return([]api.WAFListItem{{ID: "item1", Prefix: prefix1}, {ID: "item2", Prefix: prefix2}}, true, false, true)
+	items, alreadyExisting, cached, ok := h.ListWAFListItems(ctx, mockPP, list, "description")
+	require.True(t, ok)
+	require.True(t, alreadyExisting)
+	require.False(t, cached)
+	require.Len(t, items, 2)
+
+	gomock.InOrder(
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would delete %s from the list %s", "192.0.2.1, item9", "account/list"),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would add %s to the list %s", "192.0.2.3", "account/list"),
+	)
+	require.True(t, h.DeleteWAFListItems(ctx, mockPP, list, "description", []api.ID{"item1", "item9"}))
+	require.True(t, h.CreateWAFListItems(ctx, mockPP, list, "description", []netip.Prefix{prefix3}, "comment"))
+	require.True(t, h.DeleteWAFListItems(ctx, mockPP, list, "description", nil))
+	require.True(t, h.CreateWAFListItems(ctx, mockPP, list, "description", nil, "comment"))
+
+	// Later steps see the planned changes.
+	items, alreadyExisting, cached, ok = h.ListWAFListItems(ctx, mockPP, list, "description")
+	require.True(t, ok)
+	require.True(t, alreadyExisting)
+	require.True(t, cached)
+	require.Equal(t, []api.WAFListItem{
+		{ID: "item2", Prefix: prefix2},
+		{ID: api.DryRunIDPrefix + "192.0.2.3", Prefix: prefix3},
+	}, items)
+
+	mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would delete the list %s", "account/list")
+	deleted, ok := h.FinalClearWAFListAsync(ctx, mockPP, list, "description")
+	require.True(t, deleted)
+	require.True(t, ok)
+}
+
+func TestDryRunWAFListMissing(t *testing.T) {
+	t.Parallel()
+
+	list := api.WAFList{AccountID: "account", Name: "list"}
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"missing": {
+			true,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would create the list %s", "account/list")
+			},
+		},
+		"error": {
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to check the existence of the list %s", "account/list")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			tc.prepareMockPP(mockPP)
+
+			// The wrapped handle must not be asked to list (and thus create) the list.
+			h := api.NewDryRunHandle(wafListFinderHandle{mocks.NewMockHandle(mockCtrl), false, tc.ok}, powerDNSOptions(""))
+			items, alreadyExisting, cached, ok := h.ListWAFListItems(ctx, mockPP, list, "description")
+			require.Equal(t, tc.ok, ok)
+			require.False(t, alreadyExisting)
+			require.False(t, cached)
+			require.Empty(t, items)
+		})
+	}
+}
+
+func TestDryRunCloudflareWAFList(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+
+		f := newCloudflareHarness(t)
+		lh := newListListsHandler(t, f.serveMux, []listMeta{})
+		ch := newCreateListHandler(t, f.serveMux, cloudflare.ListCreateRequest{}, listMeta{}) //nolint:exhaustruct
+		lh.setRequestLimit(1)
+		ch.setRequestLimit(0)
+
+		mockPP := f.newPP()
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would create the list %s", mockWAFList.Describe())
+		h := api.NewDryRunHandle(f.cfHandle, defaultHandleOptions())
+		items, alreadyExisting, cached, ok := h.ListWAFListItems(ctx, mockPP, mockWAFList, "description")
+		require.True(t, ok)
+		require.False(t, alreadyExisting)
+		require.False(t, cached)
+		require.Empty(t, items)
+		assertHandlersExhausted(t, lh, ch)
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		t.Parallel()
+
+		f := newCloudflareHarness(t)
+		lh := newListListsHandler(t, f.serveMux, []listMeta{{name: "list", size: 1, kind: cloudflare.ListTypeIP}})
+		lih := newListListItemsHandler(t, f.serveMux, mockID("list", 0), []listItem{{"10.0.0.1", ""}})
+		lh.setRequestLimit(1)
+		lih.setRequestLimit(1)
+
+		h := api.NewDryRunHandle(f.cfHandle, defaultHandleOptions())
+		items, alreadyExisting, cached, ok := h.ListWAFListItems(ctx, f.newPP(), mockWAFList, "description")
+		require.True(t, ok)
+		require.True(t, alreadyExisting)
+		require.False(t, cached)
+		require.Equal(t, []api.WAFListItem{{ID: mockID("10.0.0.1", 0), Prefix: netip.MustParsePrefix("10.0.0.1/32")}}, items)
+		assertHandlersExhausted(t, lh, lih)
+	})
+}
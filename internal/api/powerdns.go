@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+const (
+	// PowerDNSDefaultServerID is the server ID of the PowerDNS Authoritative Server itself.
+	PowerDNSDefaultServerID = "localhost"
+
+	// powerDNSMaxErrorLength is the maximum number of bytes of an error response to read.
+	powerDNSMaxErrorLength = 1024
+)
+
+// PowerDNSCache holds the previous responses from the PowerDNS API.
+type PowerDNSCache = struct {
+	zoneIDOfDomain *ttlcache.Cache[string, ID]                       // domain names to their zone IDs
+	listRecords    map[ipnet.Type]*ttlcache.Cache[string, *[]Record] // domain names to records
+}
+
+// A PowerDNSHandle implements the [Handle] interface with the HTTP API of
+// the PowerDNS Authoritative Server. Records are changed by replacing whole
+// RRsets (the records sharing the same name and type), and the IDs of records
+// are their IP addresses, because PowerDNS does not assign IDs to records.
+//
+// PowerDNS keeps comments per RRset, not per record. The comment of an RRset
+// is used as the comment of each of its records, and it is kept intact when
+// the records are changed.
+type PowerDNSHandle struct {
+	noWAFLists
+
+	client   *http.Client
+	baseURL  string
+	apiKey   string
+	serverID string
+	options  HandleOptions
+	cache    PowerDNSCache
+}
+
+// A PowerDNSAuth implements the [Auth] interface, holding the data to create a [PowerDNSHandle].
+type PowerDNSAuth struct {
+	BaseURL  string // the URL of the API, such as "http://127.0.0.1:8081"
+	APIKey   string // the API key sent as X-API-Key
+	ServerID string // the server ID; empty means [PowerDNSDefaultServerID]
+}
+
+// New creates a [PowerDNSHandle] from the API URL, the API key, and handle options.
+func (a PowerDNSAuth) New(ppfmt pp.PP, options HandleOptions) (Handle, bool) {
+	u, err := url.Parse(a.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		ppfmt.Noticef(pp.EmojiUserError, "The PowerDNS API URL %q does not look like a valid URL", a.BaseURL)
+		return nil, false
+	}
+
+	serverID := a.ServerID
+	if serverID == "" {
+		serverID = PowerDNSDefaultServerID
+	}
+
+	h := PowerDNSHandle{
+		noWAFLists: noWAFLists{},
+		client:     &http.Client{}, //nolint:exhaustruct // the timeouts are set by the contexts
+		baseURL:    strings.TrimSuffix(a.BaseURL, "/"),
+		apiKey:     a.APIKey,
+		serverID:   serverID,
+		options:    options,
+		cache: PowerDNSCache{
+			zoneIDOfDomain: newCache[string, ID](options.CacheExpiration),
+			listRecords: map[ipnet.Type]*ttlcache.Cache[string, *[]Record]{
+				ipnet.IP4: newCache[string, *[]Record](options.CacheExpiration),
+				ipnet.IP6: newCache[string, *[]Record](options.CacheExpiration),
+			},
+		},
+	}
+
+	return h, true
+}
+
+// FlushCache flushes the API cache.
+func (h PowerDNSHandle) FlushCache() {
+	h.cache.zoneIDOfDomain.DeleteAll()
+	for _, cache := range h.cache.listRecords {
+		cache.DeleteAll()
+	}
+}
+
+// PowerDNSError is an error response from the PowerDNS API.
+type PowerDNSError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error describes the error response.
+func (e *PowerDNSError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("the PowerDNS API responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("the PowerDNS API responded with %d %s: %s",
+		e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// powerDNSZone is a zone in the PowerDNS API.
+type powerDNSZone struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	RRSets []powerDNSRRSet `json:"rrsets,omitempty"`
+}
+
+// powerDNSRRSet is an RRset in the PowerDNS API. The records and comments are
+// omitted when empty, which keeps the existing comments when replacing an RRset.
+type powerDNSRRSet struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	TTL        int               `json:"ttl,omitempty"`
+	ChangeType string            `json:"changetype,omitempty"`
+	Records    []powerDNSRecord  `json:"records,omitempty"`
+	Comments   []powerDNSComment `json:"comments,omitempty"`
+}
+
+// powerDNSRecord is a record in an RRset.
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+// powerDNSComment is a comment attached to an RRset.
+type powerDNSComment struct {
+	Content    string `json:"content"`
+	Account    string `json:"account"`
+	ModifiedAt int64  `json:"modified_at,omitempty"`
+}
+
+// comment returns the first comment of the RRset, or the empty string if there are none.
+func (r powerDNSRRSet) comment() string {
+	if len(r.Comments) == 0 {
+		return ""
+	}
+	return r.Comments[0].Content
+}
+
+// ip parses the content of a record.
+func (r powerDNSRecord) ip() (netip.Addr, error) {
+	return netip.ParseAddr(r.Content)
+}
+
+// request sends a request to the endpoint of the server (such as "/zones") and
+// decodes the JSON response into result unless result is nil.
+func (h PowerDNSHandle) request(ctx context.Context, method, endpoint string, query url.Values,
+	body any, result any,
+) error {
+	u := h.baseURL + "/api/v1/servers/" + url.PathEscape(h.serverID) + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", h.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, powerDNSMaxErrorLength))
+		var parsed struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(raw))
+		if json.Unmarshal(raw, &parsed) == nil && parsed.Error != "" {
+			message = parsed.Error
+		}
+		return &PowerDNSError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// hintPowerDNSPermission gives a hint when the API key is not accepted.
+func hintPowerDNSPermission(ppfmt pp.PP, err error) {
+	var perr *PowerDNSError
+	if errors.As(err, &perr) &&
+		(perr.StatusCode == http.StatusUnauthorized || perr.StatusCode == http.StatusForbidden) {
+		ppfmt.NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint,
+			"Double check the PowerDNS API key. Make sure the API is enabled with the same key (api-key) on the server")
+	}
+}
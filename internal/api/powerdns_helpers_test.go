@@ -0,0 +1,222 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+)
+
+const (
+	powerDNSAPIKey = "a very secret key"
+	powerDNSZoneID = "example.org."
+)
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDNSComment struct {
+	Content    string `json:"content"`
+	Account    string `json:"account"`
+	ModifiedAt int64  `json:"modified_at"`
+}
+
+type powerDNSRRSet struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	TTL        int               `json:"ttl"`
+	ChangeType string            `json:"changetype,omitempty"`
+	Records    []powerDNSRecord  `json:"records"`
+	Comments   []powerDNSComment `json:"comments"`
+}
+
+type powerDNSRRSetKey struct {
+	name  string
+	rtype string
+}
+
+// powerDNSServer is an in-process stand-in of the HTTP API of
+// the PowerDNS Authoritative Server with the zone example.org.
+type powerDNSServer struct {
+	server *httptest.Server
+
+	mu              sync.Mutex
+	rrsets          map[powerDNSRRSetKey]powerDNSRRSet
+	ignoreFilters   bool            // whether to ignore the filters, as old versions of PowerDNS do
+	patchStatus     int             // the status code of all PATCH requests; zero for success
+	patches         []powerDNSRRSet // the RRsets in the accepted PATCH requests
+	concurrentRRSet *powerDNSRRSet  // the RRset set by another writer right after each accepted PATCH request
+}
+
+func newPowerDNSServer(t *testing.T) *powerDNSServer {
+	t.Helper()
+
+	s := &powerDNSServer{
+		server:          nil,
+		mu:              sync.Mutex{},
+		rrsets:          map[powerDNSRRSetKey]powerDNSRRSet{},
+		ignoreFilters:   false,
+		patchStatus:     0,
+		patches:         nil,
+		concurrentRRSet: nil,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+func (s *powerDNSServer) url() string { return s.server.URL }
+
+func (s *powerDNSServer) set(rrset powerDNSRRSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rrsets[powerDNSRRSetKey{rrset.Name, rrset.Type}] = rrset
+}
+
+func (s *powerDNSServer) get(name, rtype string) (powerDNSRRSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rrset, ok := s.rrsets[powerDNSRRSetKey{name, rtype}]
+	return rrset, ok
+}
+
+func (s *powerDNSServer) acceptedPatches() []powerDNSRRSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.patches)
+}
+
+func writePowerDNSError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func (s *powerDNSServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-API-Key") != powerDNSAPIKey {
+		writePowerDNSError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	const prefix = "/api/v1/servers/localhost/zones"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == prefix:
+		s.mu.Lock()
+		ignoreFilters := s.ignoreFilters
+		s.mu.Unlock()
+
+		zones := []map[string]string{}
+		zone := r.URL.Query().Get("zone")
+		if ignoreFilters || zone == "" {
+			zones = append(zones, map[string]string{"id": "example.com.", "name": "example.com."})
+		}
+		if ignoreFilters || zone == "" || zone == powerDNSZoneID {
+			zones = append(zones, map[string]string{"id": powerDNSZoneID, "name": powerDNSZoneID})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(zones)
+
+	case r.URL.Path == prefix+"/"+powerDNSZoneID && r.Method == http.MethodGet:
+		s.list(w, r.URL.Query())
+
+	case r.URL.Path == prefix+"/"+powerDNSZoneID && r.Method == http.MethodPatch:
+		s.patch(w, r)
+
+	case strings.HasPrefix(r.URL.Path, prefix+"/"):
+		writePowerDNSError(w, http.StatusNotFound, "Could not find domain")
+
+	default:
+		writePowerDNSError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *powerDNSServer) list(w http.ResponseWriter, query url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rrsets := []powerDNSRRSet{}
+	for key, rrset := range s.rrsets {
+		if s.ignoreFilters {
+			rrsets = append(rrsets, rrset)
+			continue
+		}
+		if name := query.Get("rrset_name"); name != "" && name != key.name {
+			continue
+		}
+		if rtype := query.Get("rrset_type"); rtype != "" && rtype != key.rtype {
+			continue
+		}
+		rrsets = append(rrsets, rrset)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"id": powerDNSZoneID, "name": powerDNSZoneID, "rrsets": rrsets})
+}
+
+func (s *powerDNSServer) patch(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RRSets []powerDNSRRSet `json:"rrsets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writePowerDNSError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.patchStatus != 0 {
+		writePowerDNSError(w, s.patchStatus, "Refused")
+		return
+	}
+
+	for _, rrset := range body.RRSets {
+		key := powerDNSRRSetKey{rrset.Name, rrset.Type}
+		switch rrset.ChangeType {
+		case "DELETE":
+			delete(s.rrsets, key)
+		case "REPLACE":
+			if len(rrset.Records) == 0 {
+				writePowerDNSError(w, http.StatusUnprocessableEntity, "REPLACE without records")
+				return
+			}
+			if rrset.Comments == nil { // missing comments are kept
+				rrset.Comments = s.rrsets[key].Comments
+			}
+			rrset.ChangeType = ""
+			s.rrsets[key] = rrset
+		default:
+			writePowerDNSError(w, http.StatusUnprocessableEntity, "Unknown changetype")
+			return
+		}
+	}
+
+	s.patches = append(s.patches, body.RRSets...)
+	if s.concurrentRRSet != nil {
+		s.rrsets[powerDNSRRSetKey{s.concurrentRRSet.Name, s.concurrentRRSet.Type}] = *s.concurrentRRSet
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newPowerDNSHandle(t *testing.T, server *powerDNSServer, options api.HandleOptions) api.Handle {
+	t.Helper()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	h, ok := api.PowerDNSAuth{BaseURL: server.url(), APIKey: powerDNSAPIKey, ServerID: ""}.New(mockPP, options)
+	require.True(t, ok)
+	return h
+}
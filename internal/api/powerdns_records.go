@@ -0,0 +1,357 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// ZoneIDOfDomain finds the zone ID governing a particular domain.
+func (h PowerDNSHandle) ZoneIDOfDomain(ctx context.Context, ppfmt pp.PP, domain domain.Domain) (ID, bool) {
+	if id := h.cache.zoneIDOfDomain.Get(domain.DNSNameASCII()); id != nil {
+		return id.Value(), true
+	}
+
+	for zoneName := range domain.Zones {
+		// The DNS root zone will not be managed by us anyways!
+		if zoneName == "" {
+			continue
+		}
+
+		var zones []powerDNSZone
+		if err := h.request(ctx, http.MethodGet, "/zones",
+			url.Values{"zone": {CanonicalDNSName(zoneName)}}, nil, &zones); err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s: %v", zoneName, err)
+			hintPowerDNSPermission(ppfmt, err)
+			return "", false
+		}
+
+		// Old versions of PowerDNS ignore the filter and list all zones.
+		zones = slices.DeleteFunc(zones, func(z powerDNSZone) bool {
+			return CanonicalDNSName(z.Name) != CanonicalDNSName(zoneName)
+		})
+
+		switch len(zones) {
+		case 0:
+			continue
+		case 1:
+			id := ID(zones[0].ID)
+			h.cache.zoneIDOfDomain.DeleteExpired()
+			h.cache.zoneIDOfDomain.Set(domain.DNSNameASCII(), id, ttlcache.DefaultTTL)
+			return id, true
+		default:
+			ppfmt.Noticef(pp.EmojiImpossible,
+				"Found multiple zones named %s (IDs: %s); please report this at %s",
+				zoneName, pp.EnglishJoinMap(func(z powerDNSZone) string { return z.ID }, zones), pp.IssueReportingURL)
+			return "", false
+		}
+	}
+
+	ppfmt.Noticef(pp.EmojiError, "Failed to find the zone of %s", domain.Describe())
+
+	return "", false
+}
+
+// getRRSet retrieves the A or AAAA RRset of a domain. It returns nil if the RRset does not exist.
+func (h PowerDNSHandle) getRRSet(ctx context.Context, zone ID, ipNet ipnet.Type, domain domain.Domain,
+) (*powerDNSRRSet, error) {
+	name := CanonicalDNSName(domain.DNSNameASCII())
+
+	var z powerDNSZone
+	if err := h.request(ctx, http.MethodGet, "/zones/"+url.PathEscape(string(zone)),
+		url.Values{"rrsets": {"true"}, "rrset_name": {name}, "rrset_type": {ipNet.RecordType()}}, nil, &z); err != nil {
+		return nil, err
+	}
+
+	// Old versions of PowerDNS ignore the filters and return all RRsets.
+	for _, rrset := range z.RRSets {
+		if CanonicalDNSName(rrset.Name) == name && rrset.Type == ipNet.RecordType() {
+			return &rrset, nil
+		}
+	}
+	return nil, nil //nolint:nilnil // the RRset does not exist
+}
+
+// ListRecords retrieves the A or AAAA RRset of a domain. Disabled records are skipped.
+func (h PowerDNSHandle) ListRecords(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain,
+	expectedParams RecordParams,
+) ([]Record, bool, bool) {
+	if cachedManagedRecords := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); cachedManagedRecords != nil {
+		// Cache stores managed records only; this assumes a stable selector per handle.
+		return *cachedManagedRecords.Value(), true, true
+	}
+
+	zone, ok := h.ZoneIDOfDomain(ctx, ppfmt, domain)
+	if !ok {
+		return nil, false, false
+	}
+
+	rrset, err := h.getRRSet(ctx, zone, ipNet, domain)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to retrieve %s records of %s: %v", ipNet.RecordType(), domain.Describe(), err)
+		hintPowerDNSPermission(ppfmt, err)
+		return nil, false, false
+	}
+
+	if expectedParams.Proxied {
+		hintProxiedOnlyWithCloudflare(ppfmt)
+	}
+
+	managedRecords := []Record{}
+	if rrset != nil && matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, rrset.comment()) {
+		for _, r := range rrset.Records {
+			if r.Disabled {
+				continue
+			}
+
+			ip, err := r.ip()
+			if err != nil {
+				ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the IP address in an %s record of %s (content: %q): %v",
+					ipNet.RecordType(), domain.Describe(), r.Content, err)
+				return nil, false, false
+			}
+
+			managedRecords = append(managedRecords, Record{
+				ID: ID(ip.String()),
+				IP: ip,
+				RecordParams: RecordParams{
+					TTL:     TTL(rrset.TTL),
+					Proxied: false,
+					Comment: rrset.comment(),
+				},
+			})
+		}
+
+		if len(managedRecords) > 0 {
+			if expected := dnsServerTTL(expectedParams.TTL); TTL(rrset.TTL) != expected {
+				hintDNSServerMismatchedTTL(ppfmt, ipNet, domain, "the PowerDNS server", TTL(rrset.TTL), expected)
+			}
+			if rrset.comment() != expectedParams.Comment {
+				hintDNSServerMismatchedComment(ppfmt, ipNet, domain, "the PowerDNS server", rrset.comment(), expectedParams.Comment)
+			}
+		}
+	}
+
+	h.cache.listRecords[ipNet].DeleteExpired()
+	h.cache.listRecords[ipNet].Set(domain.DNSNameASCII(), &managedRecords, ttlcache.DefaultTTL)
+
+	return managedRecords, false, true
+}
+
+// modifyRRSet reads the A or AAAA RRset of a domain, changes its records, and then replaces it.
+// The TTL and the comments of an existing RRset are kept; the given TTL and comment are only
+// used to create a new RRset. The RRset is deleted if no records are left. Existing RRsets
+// with comments not matching MANAGED_RECORDS_COMMENT_REGEX are never changed.
+// The description is used in error messages, such as "delete a stale A record of example.org".
+//
+// The PowerDNS API cannot replace an RRset conditionally, so a concurrent writer changing
+// the same RRset between the GET and the PATCH may lose its changes or undo ours. The RRset
+// is read again after the PATCH to catch the latter; a conflict is reported as a failure,
+// so that the callers drop the cached records and the next round starts from a fresh read.
+func (h PowerDNSHandle) modifyRRSet(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain,
+	description string, ttl TTL, comment string, change func([]powerDNSRecord) []powerDNSRecord,
+) (powerDNSRRSet, bool) {
+	zone, ok := h.ZoneIDOfDomain(ctx, ppfmt, domain)
+	if !ok {
+		return powerDNSRRSet{}, false //nolint:exhaustruct
+	}
+
+	current, err := h.getRRSet(ctx, zone, ipNet, domain)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to %s: %v", description, err)
+		hintPowerDNSPermission(ppfmt, err)
+		return powerDNSRRSet{}, false //nolint:exhaustruct
+	}
+
+	rrset := powerDNSRRSet{
+		Name:       CanonicalDNSName(domain.DNSNameASCII()),
+		Type:       ipNet.RecordType(),
+		TTL:        ttl.Int(),
+		ChangeType: "REPLACE",
+		Records:    nil,
+		Comments:   nil,
+	}
+	if comment != "" {
+		rrset.Comments = []powerDNSComment{{Content: comment, Account: "", ModifiedAt: 0}}
+	}
+	if current != nil {
+		if !matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, current.comment()) {
+			ppfmt.Noticef(pp.EmojiError,
+				"Failed to %s: the existing %s records are not managed by this updater because their comment %s does not match MANAGED_RECORDS_COMMENT_REGEX", //nolint:lll
+				description, ipNet.RecordType(), DescribeFreeFormString(current.comment()))
+			return powerDNSRRSet{}, false //nolint:exhaustruct
+		}
+		rrset.TTL, rrset.Records, rrset.Comments = current.TTL, current.Records, current.Comments
+	}
+
+	rrset.Records = change(slices.Clone(rrset.Records))
+	if len(rrset.Records) == 0 {
+		rrset.ChangeType, rrset.TTL, rrset.Comments = "DELETE", 0, nil
+	} else if disabled := slices.DeleteFunc(slices.Clone(rrset.Records),
+		func(r powerDNSRecord) bool { return !r.Disabled }); len(disabled) > 0 {
+		ppfmt.Infof(pp.EmojiBullet, "Keeping the disabled %s records of %s: %s", ipNet.RecordType(), domain.Describe(),
+			pp.EnglishJoinMap(func(r powerDNSRecord) string { return r.Content }, disabled))
+	}
+
+	if err := h.request(ctx, http.MethodPatch, "/zones/"+url.PathEscape(string(zone)), nil,
+		struct {
+			RRSets []powerDNSRRSet `json:"rrsets"`
+		}{[]powerDNSRRSet{rrset}}, nil); err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to %s: %v", description, err)
+		hintPowerDNSPermission(ppfmt, err)
+		return powerDNSRRSet{}, false //nolint:exhaustruct
+	}
+
+	after, err := h.getRRSet(ctx, zone, ipNet, domain)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to verify the %s records of %s after trying to %s: %v",
+			ipNet.RecordType(), domain.Describe(), description, err)
+		return powerDNSRRSet{}, false //nolint:exhaustruct
+	}
+	var afterRecords []powerDNSRecord
+	if after != nil {
+		afterRecords = after.Records
+	}
+	if !samePowerDNSRecords(afterRecords, rrset.Records) {
+		ppfmt.Noticef(pp.EmojiWarning,
+			"Failed to %s: the %s records of %s were changed by someone else at the same time; they will be read again",
+			description, ipNet.RecordType(), domain.Describe())
+		return powerDNSRRSet{}, false //nolint:exhaustruct
+	}
+
+	return rrset, true
+}
+
+// samePowerDNSRecords checks whether two lists have the same records, ignoring the order.
+func samePowerDNSRecords(records1, records2 []powerDNSRecord) bool {
+	compare := func(r1, r2 powerDNSRecord) int {
+		switch {
+		case r1.Content != r2.Content:
+			return cmp.Compare(r1.Content, r2.Content)
+		case r1.Disabled == r2.Disabled:
+			return 0
+		case r1.Disabled:
+			return 1
+		default:
+			return -1
+		}
+	}
+	records1 = slices.SortedFunc(slices.Values(records1), compare)
+	records2 = slices.SortedFunc(slices.Values(records2), compare)
+	return slices.Equal(records1, records2)
+}
+
+// withoutIP removes the records with the IP address.
+func withoutIP(records []powerDNSRecord, ip netip.Addr) []powerDNSRecord {
+	return slices.DeleteFunc(records, func(r powerDNSRecord) bool {
+		recordIP, err := r.ip()
+		return err == nil && recordIP == ip
+	})
+}
+
+// DeleteRecord removes one A or AAAA record from its RRset.
+func (h PowerDNSHandle) DeleteRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID,
+	mode DeletionMode,
+) bool {
+	ip, err := netip.ParseAddr(string(id))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the IP address in the ID %s: %v", id, err)
+		return false
+	}
+
+	if _, ok := h.modifyRRSet(ctx, ppfmt, ipNet, domain,
+		"delete a stale "+ipNet.RecordType()+" record of "+domain.Describe()+" (ID: "+string(id)+")",
+		DNSServerDefaultTTL, "",
+		func(records []powerDNSRecord) []powerDNSRecord { return withoutIP(records, ip) },
+	); !ok {
+		if mode == RegularDelitionMode {
+			h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		}
+		return false
+	}
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		*rs.Value() = slices.DeleteFunc(*rs.Value(), func(r Record) bool { return r.ID == id })
+	}
+
+	return true
+}
+
+// UpdateRecord replaces one A or AAAA record with another one in its RRset.
+// The ID of the record changes because it is the IP address.
+func (h PowerDNSHandle) UpdateRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID, ip netip.Addr,
+	currentParams, _ RecordParams,
+) bool {
+	oldIP, err := netip.ParseAddr(string(id))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the IP address in the ID %s: %v", id, err)
+		return false
+	}
+
+	rrset, ok := h.modifyRRSet(ctx, ppfmt, ipNet, domain,
+		"update a stale "+ipNet.RecordType()+" record of "+domain.Describe()+" (ID: "+string(id)+")",
+		dnsServerTTL(currentParams.TTL), currentParams.Comment,
+		func(records []powerDNSRecord) []powerDNSRecord {
+			records = withoutIP(withoutIP(records, oldIP), ip)
+			return append(records, powerDNSRecord{Content: ip.String(), Disabled: false})
+		},
+	)
+	if !ok {
+		h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		return false
+	}
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		updatedRecord := Record{
+			ID:           ID(ip.String()),
+			IP:           ip,
+			RecordParams: RecordParams{TTL: TTL(rrset.TTL), Proxied: false, Comment: rrset.comment()},
+		}
+		*rs.Value() = slices.DeleteFunc(*rs.Value(), func(r Record) bool { return r.ID == id || r.ID == updatedRecord.ID })
+		*rs.Value() = append([]Record{updatedRecord}, *rs.Value()...)
+	}
+
+	return true
+}
+
+// CreateRecord adds one A or AAAA record to its RRset, creating the RRset with the comment
+// if it does not exist. The ID of the new record is its IP address.
+func (h PowerDNSHandle) CreateRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, ip netip.Addr, params RecordParams,
+) (ID, bool) {
+	rrset, ok := h.modifyRRSet(ctx, ppfmt, ipNet, domain,
+		"add a new "+ipNet.RecordType()+" record of "+domain.Describe(),
+		dnsServerTTL(params.TTL), params.Comment,
+		func(records []powerDNSRecord) []powerDNSRecord {
+			// A disabled record with the same IP address will be enabled.
+			return append(withoutIP(records, ip), powerDNSRecord{Content: ip.String(), Disabled: false})
+		},
+	)
+	if !ok {
+		h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		return "", false
+	}
+
+	id := ID(ip.String())
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil &&
+		matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, rrset.comment()) {
+		*rs.Value() = append([]Record{{
+			ID:           id,
+			IP:           ip,
+			RecordParams: RecordParams{TTL: TTL(rrset.TTL), Proxied: false, Comment: rrset.comment()},
+		}}, *rs.Value()...)
+	}
+
+	return id, true
+}
@@ -0,0 +1,429 @@
+package api_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+func powerDNSOptions(regex string) api.HandleOptions {
+	options := api.HandleOptions{CacheExpiration: time.Minute, ManagedRecordsCommentRegex: nil}
+	if regex != "" {
+		options.ManagedRecordsCommentRegex = regexp.MustCompile(regex)
+	}
+	return options
+}
+
+func TestPowerDNSAuthNew(t *testing.T) {
+	t.Parallel()
+
+	for name, baseURL := range map[string]string{
+		"empty":     "",
+		"no-scheme": "127.0.0.1:8081",
+		"ftp":       "ftp://127.0.0.1",
+		"no-host":   "http://",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockPP.EXPECT().Noticef(pp.EmojiUserError, "The PowerDNS API URL %q does not look like a valid URL", baseURL)
+
+			h, ok := api.PowerDNSAuth{BaseURL: baseURL, APIKey: powerDNSAPIKey, ServerID: ""}.New(mockPP, powerDNSOptions(""))
+			require.False(t, ok)
+			require.Nil(t, h)
+		})
+	}
+}
+
+func TestPowerDNSListRecords(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 60, ChangeType: "",
+		Records:  []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, {Content: "192.0.2.2", Disabled: false}, {Content: "192.0.2.3", Disabled: true}},
+		Comments: []powerDNSComment{{Content: "old", Account: "", ModifiedAt: 1}},
+	})
+	h := newPowerDNSHandle(t, server, powerDNSOptions(""))
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	gomock.InOrder(
+		mockPP.EXPECT().NoticeOncef(pp.MessageProxiedOnlyWithCloudflare, pp.EmojiUserWarning, "DNS records cannot be proxied because proxying is only available with Cloudflare; PROXIED is ignored"),
+		mockPP.EXPECT().Noticef(pp.EmojiUserWarning, "The TTL for the %s records of %s is %s. However, it is expected to be %s. You can either change the TTL on %s or change the expected TTL with TTL=%d.", "A", "www.example.org", "60", "300", "the PowerDNS server", 60),
+		mockPP.EXPECT().Noticef(pp.EmojiUserWarning, "The comment for the %s records of %s is %s. However, it is expected to be %s. You can either change the comment on %s or change the value of RECORD_COMMENT to match the current comment.", "A", "www.example.org", `"old"`, `"new"`, "the PowerDNS server"),
+	)
+	params := api.RecordParams{TTL: api.TTLAuto, Proxied: true, Comment: "new"}
+
+	// Disabled records are skipped, and the comment of the RRset is the comment of each record.
+	rs, cached, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), params)
+	require.True(t, ok)
+	require.False(t, cached)
+	require.Equal(t, []api.Record{
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 60, Proxied: false, Comment: "old"}},
+		{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 60, Proxied: false, Comment: "old"}},
+	}, rs)
+
+	_, cached, ok = h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), params)
+	require.True(t, ok)
+	require.True(t, cached)
+}
+
+// TestPowerDNSIgnoredFilters checks that the handle works with old versions of PowerDNS,
+// which ignore the filters and list all zones and RRsets.
+func TestPowerDNSIgnoredFilters(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.ignoreFilters = true
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}}, Comments: nil,
+	})
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "AAAA", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "2001:db8::1", Disabled: false}}, Comments: nil,
+	})
+	server.set(powerDNSRRSet{
+		Name: "ftp.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "192.0.2.2", Disabled: false}}, Comments: nil,
+	})
+	h := newPowerDNSHandle(t, server, powerDNSOptions(""))
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	rs, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+	require.True(t, ok)
+	require.Equal(t, []api.Record{
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 300, Proxied: false, Comment: ""}},
+	}, rs)
+}
+
+func TestPowerDNSZoneNotFound(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	h := newPowerDNSHandle(t, server, powerDNSOptions(""))
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to find the zone of %s", "www.example.net")
+
+	rs, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.net"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+	require.False(t, ok)
+	require.Nil(t, rs)
+}
+
+// TestPowerDNSPatches checks the PATCH requests. Records are changed by replacing the whole
+// RRset (changetype REPLACE) with its TTL, comments, and disabled records intact, and
+// the RRset is deleted (changetype DELETE) when no records are left.
+func TestPowerDNSPatches(t *testing.T) {
+	t.Parallel()
+
+	dom := domain.FQDN("www.example.org")
+	comments := []powerDNSComment{{Content: "ddns", Account: "admin", ModifiedAt: 1}}
+	disabled := powerDNSRecord{Content: "192.0.2.9", Disabled: true}
+
+	for name, tc := range map[string]struct {
+		existing      *powerDNSRRSet
+		change        func(context.Context, *mocks.MockPP, api.Handle) bool
+		patch         powerDNSRRSet
+		expected      []api.Record
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"create": {
+			&powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "",
+				Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, disabled}, Comments: comments,
+			},
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				id, ok := h.CreateRecord(ctx, ppfmt, ipnet.IP4, dom, mustIP("192.0.2.2"), api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: "ddns"})
+				require.Equal(t, api.ID("192.0.2.2"), id)
+				return ok
+			},
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "REPLACE",
+				Records:  []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, disabled, {Content: "192.0.2.2", Disabled: false}},
+				Comments: comments,
+			},
+			[]api.Record{
+				{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}},
+				{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}},
+			},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Infof(pp.EmojiBullet, "Keeping the disabled %s records of %s: %s", "A", "www.example.org", "192.0.2.9")
+			},
+		},
+		"create/enable": {
+			&powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "",
+				Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, disabled}, Comments: comments,
+			},
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				_, ok := h.CreateRecord(ctx, ppfmt, ipnet.IP4, dom, mustIP("192.0.2.9"), api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: "ddns"})
+				return ok
+			},
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "REPLACE",
+				Records:  []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, {Content: "192.0.2.9", Disabled: false}},
+				Comments: comments,
+			},
+			[]api.Record{
+				{ID: "192.0.2.9", IP: mustIP("192.0.2.9"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}},
+				{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}},
+			},
+			nil,
+		},
+		"create/new-rrset": {
+			nil,
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				_, ok := h.CreateRecord(ctx, ppfmt, ipnet.IP4, dom, mustIP("192.0.2.2"), api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: "ddns"})
+				return ok
+			},
+			// A new RRset gets the comment so that it is managed by this updater.
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "REPLACE",
+				Records:  []powerDNSRecord{{Content: "192.0.2.2", Disabled: false}},
+				Comments: []powerDNSComment{{Content: "ddns", Account: "", ModifiedAt: 0}},
+			},
+			[]api.Record{
+				{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 300, Proxied: false, Comment: "ddns"}},
+			},
+			nil,
+		},
+		"update": {
+			&powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "",
+				Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, disabled}, Comments: comments,
+			},
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				return h.UpdateRecord(ctx, ppfmt, ipnet.IP4, dom, "192.0.2.1", mustIP("192.0.2.3"),
+					api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}, api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: "ddns"})
+			},
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "REPLACE",
+				Records:  []powerDNSRecord{disabled, {Content: "192.0.2.3", Disabled: false}},
+				Comments: comments,
+			},
+			[]api.Record{
+				{ID: "192.0.2.3", IP: mustIP("192.0.2.3"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}},
+			},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Infof(pp.EmojiBullet, "Keeping the disabled %s records of %s: %s", "A", "www.example.org", "192.0.2.9")
+			},
+		},
+		"delete": {
+			&powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "",
+				Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, disabled}, Comments: comments,
+			},
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				return h.DeleteRecord(ctx, ppfmt, ipnet.IP4, dom, "192.0.2.1", api.RegularDelitionMode)
+			},
+			// The disabled record keeps the RRset alive.
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "REPLACE",
+				Records: []powerDNSRecord{disabled}, Comments: comments,
+			},
+			[]api.Record{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Infof(pp.EmojiBullet, "Keeping the disabled %s records of %s: %s", "A", "www.example.org", "192.0.2.9")
+			},
+		},
+		"delete/last": {
+			&powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 600, ChangeType: "",
+				Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}}, Comments: comments,
+			},
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				return h.DeleteRecord(ctx, ppfmt, ipnet.IP4, dom, "192.0.2.1", api.RegularDelitionMode)
+			},
+			powerDNSRRSet{
+				Name: "www.example.org.", Type: "A", TTL: 0, ChangeType: "DELETE",
+				Records: nil, Comments: nil,
+			},
+			[]api.Record{},
+			nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newPowerDNSServer(t)
+			if tc.existing != nil {
+				server.set(*tc.existing)
+			}
+			h := newPowerDNSHandle(t, server, powerDNSOptions("^ddns$"))
+			ctx := context.Background()
+			params := api.RecordParams{TTL: 600, Proxied: false, Comment: "ddns"}
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			_, _, ok := h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+			require.True(t, ok)
+
+			require.True(t, tc.change(ctx, mockPP, h))
+			require.Equal(t, []powerDNSRRSet{tc.patch}, server.acceptedPatches())
+
+			// The cache follows the changes.
+			rs, cached, ok := h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+			require.True(t, ok)
+			require.True(t, cached)
+			require.Equal(t, tc.expected, rs)
+		})
+	}
+}
+
+func TestPowerDNSConcurrentWriter(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}}, Comments: nil,
+	})
+	// Another writer replaces the RRset right after our PATCH.
+	server.concurrentRRSet = &powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}, {Content: "192.0.2.5", Disabled: false}}, Comments: nil,
+	}
+	h := newPowerDNSHandle(t, server, powerDNSOptions(""))
+	dom := domain.FQDN("www.example.org")
+	params := api.RecordParams{TTL: 300, Proxied: false, Comment: ""}
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	_, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+
+	mockPP.EXPECT().Noticef(pp.EmojiWarning,
+		"Failed to %s: the %s records of %s were changed by someone else at the same time; they will be read again",
+		"add a new A record of www.example.org", "A", "www.example.org")
+	_, ok = h.CreateRecord(context.Background(), mockPP, ipnet.IP4, dom, mustIP("192.0.2.2"), params)
+	require.False(t, ok)
+
+	// The cache is flushed so that the other writer's records are seen.
+	rs, cached, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.False(t, cached)
+	require.Equal(t, []api.Record{
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: params},
+		{ID: "192.0.2.5", IP: mustIP("192.0.2.5"), RecordParams: params},
+	}, rs)
+}
+
+func TestPowerDNSUnmanagedRRSet(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records:  []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}},
+		Comments: []powerDNSComment{{Content: "manual", Account: "", ModifiedAt: 1}},
+	})
+	h := newPowerDNSHandle(t, server, powerDNSOptions("^ddns$"))
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	rs, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: "ddns"})
+	require.True(t, ok)
+	require.Empty(t, rs)
+
+	// The RRset is owned by someone else and must not be touched, even though
+	// adding a record means replacing the whole RRset.
+	mockPP.EXPECT().Noticef(pp.EmojiError,
+		"Failed to %s: the existing %s records are not managed by this updater because their comment %s does not match MANAGED_RECORDS_COMMENT_REGEX",
+		"add a new A record of www.example.org", "A", `"manual"`)
+	_, ok = h.CreateRecord(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), mustIP("192.0.2.2"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: "ddns"})
+	require.False(t, ok)
+	require.Empty(t, server.acceptedPatches())
+}
+
+func TestPowerDNSWrongKey(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	h, ok := api.PowerDNSAuth{BaseURL: server.url() + "/", APIKey: "wrong", ServerID: "localhost"}.New(mockPP, powerDNSOptions(""))
+	require.True(t, ok)
+
+	gomock.InOrder(
+		mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s: %v", "www.example.org",
+			&api.PowerDNSError{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"}),
+		mockPP.EXPECT().NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint, "Double check the PowerDNS API key. Make sure the API is enabled with the same key (api-key) on the server"),
+	)
+
+	_, _, ok = h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+	require.False(t, ok)
+}
+
+func TestPowerDNSError(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "the PowerDNS API responded with 422 Unprocessable Entity: bad",
+		(&api.PowerDNSError{StatusCode: http.StatusUnprocessableEntity, Message: "bad"}).Error())
+	require.Equal(t, "the PowerDNS API responded with 500 Internal Server Error",
+		(&api.PowerDNSError{StatusCode: http.StatusInternalServerError, Message: ""}).Error())
+}
+
+func TestPowerDNSPatchRefused(t *testing.T) {
+	t.Parallel()
+
+	server := newPowerDNSServer(t)
+	server.set(powerDNSRRSet{
+		Name: "www.example.org.", Type: "A", TTL: 300, ChangeType: "",
+		Records: []powerDNSRecord{{Content: "192.0.2.1", Disabled: false}}, Comments: nil,
+	})
+	server.patchStatus = http.StatusForbidden
+	h := newPowerDNSHandle(t, server, powerDNSOptions(""))
+	dom := domain.FQDN("www.example.org")
+	params := api.RecordParams{TTL: 300, Proxied: false, Comment: ""}
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	_, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+
+	gomock.InOrder(
+		mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to %s: %v", "update a stale A record of www.example.org (ID: 192.0.2.1)",
+			&api.PowerDNSError{StatusCode: http.StatusForbidden, Message: "Refused"}),
+		mockPP.EXPECT().NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint, "Double check the PowerDNS API key. Make sure the API is enabled with the same key (api-key) on the server"),
+	)
+	ok = h.UpdateRecord(context.Background(), mockPP, ipnet.IP4, dom, "192.0.2.1", mustIP("192.0.2.2"), params, params)
+	require.False(t, ok)
+
+	// The cache is flushed so that the RRset is read again.
+	_, cached, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.False(t, cached)
+}
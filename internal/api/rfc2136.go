@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/dnstcp"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+const (
+	// rfc2136OpCodeUpdate is the opcode of DNS UPDATE messages (RFC 2136).
+	rfc2136OpCodeUpdate dnsmessage.OpCode = 5
+
+	// rfc2136ClassNONE is the class to delete one record in an UPDATE message (RFC 2136, Section 2.5.4).
+	rfc2136ClassNONE dnsmessage.Class = 254
+
+	// rfc2136RCodeNotAuth is the response code when the server is not authoritative
+	// for the zone or the TSIG signature is not accepted (RFC 2136 and RFC 8945).
+	rfc2136RCodeNotAuth dnsmessage.RCode = 9
+)
+
+var (
+	errDNSMismatchedResponse = errors.New("the response does not match the request")
+	errDNSUnsignedResponse   = errors.New("the response is not signed")
+)
+
+// RFC2136Cache holds the previous responses from the DNS server.
+type RFC2136Cache = struct {
+	zoneOfDomain *ttlcache.Cache[string, string]                   // domain names to their zone names
+	listRecords  map[ipnet.Type]*ttlcache.Cache[string, *[]Record] // domain names to records
+}
+
+// An RFC2136Handle implements the [Handle] interface with dynamic updates in the
+// Domain Name System (RFC 2136) signed by TSIG (RFC 8945). The IDs of DNS records
+// are their IP addresses, because DNS servers do not assign IDs to records.
+type RFC2136Handle struct {
+	noWAFLists
+
+	server  string
+	key     *TSIGKey
+	options HandleOptions
+	cache   RFC2136Cache
+}
+
+// An RFC2136Auth implements the [Auth] interface, holding the data to create an [RFC2136Handle].
+type RFC2136Auth struct {
+	Server        string   // the primary DNS server in the form host:port
+	Key           *TSIGKey // the TSIG key to sign the messages; nil to send unsigned messages
+	AllowUnsigned bool     // whether Key can be nil; unsigned updates must be explicitly allowed
+}
+
+// New creates an [RFC2136Handle] from the server address, the TSIG key, and handle options.
+func (a RFC2136Auth) New(ppfmt pp.PP, options HandleOptions) (Handle, bool) {
+	if a.Key == nil && !a.AllowUnsigned {
+		ppfmt.Noticef(pp.EmojiUserError, "A TSIG key is required to sign the DNS updates")
+		return nil, false
+	}
+	if a.Key != nil && !IsTSIGAlgorithm(a.Key.Algorithm) {
+		ppfmt.Noticef(pp.EmojiUserError, "The TSIG algorithm %q is not supported", a.Key.Algorithm)
+		return nil, false
+	}
+
+	h := RFC2136Handle{
+		noWAFLists: noWAFLists{},
+		server:     a.Server,
+		key:        a.Key,
+		options:    options,
+		cache: RFC2136Cache{
+			zoneOfDomain: newCache[string, string](options.CacheExpiration),
+			listRecords: map[ipnet.Type]*ttlcache.Cache[string, *[]Record]{
+				ipnet.IP4: newCache[string, *[]Record](options.CacheExpiration),
+				ipnet.IP6: newCache[string, *[]Record](options.CacheExpiration),
+			},
+		},
+	}
+
+	return h, true
+}
+
+// FlushCache flushes the cache of DNS responses.
+func (h RFC2136Handle) FlushCache() {
+	h.cache.zoneOfDomain.DeleteAll()
+	for _, cache := range h.cache.listRecords {
+		cache.DeleteAll()
+	}
+}
+
+// randDNSID generates a random message ID.
+func randDNSID() uint16 {
+	var buf [2]byte
+	_, _ = rand.Read(buf[:]) // crypto/rand.Read never returns an error
+	return binary.BigEndian.Uint16(buf[:])
+}
+
+// exchangeDNSOverTCP sends one message over TCP as described in RFC 7766.
+func exchangeDNSOverTCP(ctx context.Context, server string, q []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return dnstcp.Exchange(ctx, conn, q)
+}
+
+// rfc2136RCodeNames are the names of the response codes introduced by RFC 2136.
+//
+//nolint:gochecknoglobals
+var rfc2136RCodeNames = map[dnsmessage.RCode]string{
+	6:                   "YXDomain",
+	7:                   "YXRRSet",
+	8:                   "NXRRSet",
+	rfc2136RCodeNotAuth: "NotAuth",
+	10:                  "NotZone",
+}
+
+// describeRCode describes a response code, such as "Refused".
+func describeRCode(rcode dnsmessage.RCode) string {
+	if name, found := rfc2136RCodeNames[rcode]; found {
+		return name
+	}
+	return strings.TrimPrefix(rcode.String(), "RCode")
+}
+
+// rfc2136RecordType gives the DNS record type of the IP network.
+func rfc2136RecordType(ipNet ipnet.Type) dnsmessage.Type {
+	if ipNet == ipnet.IP6 {
+		return dnsmessage.TypeAAAA
+	}
+	return dnsmessage.TypeA
+}
+
+// exchange signs and sends a message, and then verifies and parses the response.
+// Unsigned responses are only accepted when they report errors other than NXDOMAIN,
+// because servers may not sign the responses to requests they cannot authenticate.
+func (h RFC2136Handle) exchange(ctx context.Context, msg dnsmessage.Message) (dnsmessage.Message, error) {
+	id := randDNSID()
+	msg.Header.ID = id
+
+	q, err := msg.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var requestMAC []byte
+	if h.key != nil {
+		if q, requestMAC, err = h.key.Sign(q, nil, time.Now()); err != nil {
+			return dnsmessage.Message{}, err
+		}
+	}
+
+	resp, err := exchangeDNSOverTCP(ctx, h.server, q)
+	if err != nil {
+		if ctx.Err() != nil {
+			return dnsmessage.Message{}, context.Cause(ctx)
+		}
+		return dnsmessage.Message{}, err
+	}
+
+	var m dnsmessage.Message
+	if err := m.Unpack(resp); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if m.Header.ID != id || !m.Header.Response {
+		return dnsmessage.Message{}, errDNSMismatchedResponse
+	}
+
+	if h.key != nil {
+		unsigned, _, err := h.key.Verify(resp, requestMAC, time.Now())
+		switch {
+		case errors.Is(err, errTSIGMissing):
+			if m.Header.RCode == dnsmessage.RCodeSuccess || m.Header.RCode == dnsmessage.RCodeNameError {
+				return dnsmessage.Message{}, errDNSUnsignedResponse
+			}
+			return m, nil
+		case err != nil:
+			return dnsmessage.Message{}, err
+		}
+		if err := m.Unpack(unsigned); err != nil {
+			return dnsmessage.Message{}, err
+		}
+	}
+
+	return m, nil
+}
+
+// newRFC2136Query creates a query for the records of a name.
+func newRFC2136Query(name dnsmessage.Name, qtype dnsmessage.Type) dnsmessage.Message {
+	return dnsmessage.Message{
+		Header: dnsmessage.Header{}, //nolint:exhaustruct // a non-recursive query
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+		Answers:     nil,
+		Authorities: nil,
+		Additionals: nil,
+	}
+}
+
+// newRFC2136Update creates an UPDATE message for a zone with the changes in the update section.
+func newRFC2136Update(zone dnsmessage.Name, changes ...dnsmessage.Resource) dnsmessage.Message {
+	return dnsmessage.Message{
+		Header: dnsmessage.Header{OpCode: rfc2136OpCodeUpdate}, //nolint:exhaustruct
+		Questions: []dnsmessage.Question{
+			{Name: zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}, // the zone section
+		},
+		Answers:     nil,     // the prerequisite section
+		Authorities: changes, // the update section
+		Additionals: nil,
+	}
+}
+
+// newRFC2136Resource creates an A or AAAA record.
+func newRFC2136Resource(name dnsmessage.Name, class dnsmessage.Class, ttl uint32, ip netip.Addr,
+) dnsmessage.Resource {
+	header := dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: class, TTL: ttl, Length: 0}
+	if ip.Is4() {
+		return dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: ip.As4()}}
+	}
+	header.Type = dnsmessage.TypeAAAA
+	return dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: ip.As16()}}
+}
+
+// newDNSName converts a domain name into [dnsmessage.Name].
+func newDNSName(name string) (dnsmessage.Name, error) {
+	return dnsmessage.NewName(CanonicalDNSName(name))
+}
+
+// hintRFC2136Permission gives a hint when the server refuses the update.
+func hintRFC2136Permission(ppfmt pp.PP, rcode dnsmessage.RCode) {
+	if rcode == dnsmessage.RCodeRefused || rcode == rfc2136RCodeNotAuth {
+		ppfmt.NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint,
+			"Double check the TSIG key. Make sure the DNS server allows the key to update the zone")
+	}
+}
@@ -0,0 +1,270 @@
+package api_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+)
+
+const (
+	dnsZone    = "example.org."
+	dnsKeyName = "ddns-key."
+)
+
+func mockTSIGKey() *api.TSIGKey {
+	return &api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-sha256", Secret: []byte("a very secret secret")}
+}
+
+// dnsServer is an in-process stand-in of an authoritative DNS server
+// that accepts queries and dynamic updates (RFC 2136) over TCP.
+type dnsServer struct {
+	listener       net.Listener
+	key            *api.TSIGKey // nil to accept unsigned messages
+	responseKey    *api.TSIGKey // the key to sign the responses; nil to use key
+	unsignedErrors bool         // whether to send unsigned responses with errors
+
+	mu          sync.Mutex
+	records     map[string]map[netip.Addr]uint32 // names to IP addresses to TTLs
+	updateRCode dnsmessage.RCode                 // the response code of all updates
+	updates     []dnsmessage.Message             // the accepted updates
+}
+
+func newDNSServer(t *testing.T, key *api.TSIGKey) *dnsServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &dnsServer{
+		listener:       listener,
+		key:            key,
+		responseKey:    nil,
+		unsignedErrors: false,
+		mu:             sync.Mutex{},
+		records:        map[string]map[netip.Addr]uint32{},
+		updateRCode:    dnsmessage.RCodeSuccess,
+		updates:        nil,
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+func (s *dnsServer) addr() string { return s.listener.Addr().String() }
+
+func (s *dnsServer) set(name string, ip netip.Addr, ttl uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.records[name] == nil {
+		s.records[name] = map[netip.Addr]uint32{}
+	}
+	s.records[name][ip] = ttl
+}
+
+func (s *dnsServer) snapshot(name string) map[netip.Addr]uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := map[netip.Addr]uint32{}
+	for ip, ttl := range s.records[name] {
+		snapshot[ip] = ttl
+	}
+	return snapshot
+}
+
+func (s *dnsServer) acceptedUpdates() []dnsmessage.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.updates)
+}
+
+func (s *dnsServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *dnsServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		resp := s.handle(req)
+		if resp == nil {
+			return
+		}
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *dnsServer) handle(req []byte) []byte {
+	var requestMAC []byte
+	if s.key != nil {
+		unsigned, mac, err := s.key.Verify(req, nil, time.Now())
+		if err != nil {
+			// Reject the request without signing the response.
+			var m dnsmessage.Message
+			if m.Unpack(req) != nil {
+				return nil
+			}
+			return s.pack(m, 9, nil, nil) // NOTAUTH
+		}
+		req, requestMAC = unsigned, mac
+	}
+
+	var m dnsmessage.Message
+	if err := m.Unpack(req); err != nil || len(m.Questions) != 1 {
+		return nil
+	}
+
+	switch m.Header.OpCode {
+	case 0:
+		rcode, answers := s.query(m.Questions[0])
+		return s.pack(m, rcode, answers, requestMAC)
+	case 5:
+		return s.pack(m, s.update(m), nil, requestMAC)
+	default:
+		return s.pack(m, dnsmessage.RCodeNotImplemented, nil, requestMAC)
+	}
+}
+
+func inZone(name string) bool {
+	return name == dnsZone || strings.HasSuffix(name, "."+dnsZone)
+}
+
+func (s *dnsServer) query(q dnsmessage.Question) (dnsmessage.RCode, []dnsmessage.Resource) {
+	name := strings.ToLower(q.Name.String())
+	if !inZone(name) {
+		return dnsmessage.RCodeRefused, nil
+	}
+
+	switch q.Type {
+	case dnsmessage.TypeSOA:
+		if name != dnsZone {
+			return dnsmessage.RCodeSuccess, nil
+		}
+		return dnsmessage.RCodeSuccess, []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 3600, Length: 0},
+			Body: &dnsmessage.SOAResource{
+				NS: dnsmessage.MustNewName("ns." + dnsZone), MBox: dnsmessage.MustNewName("admin." + dnsZone),
+				Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, MinTTL: 300,
+			},
+		}}
+	case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var answers []dnsmessage.Resource
+		for ip, ttl := range s.records[name] {
+			header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: ttl, Length: 0}
+			switch {
+			case q.Type == dnsmessage.TypeA && ip.Is4():
+				answers = append(answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: ip.As4()}})
+			case q.Type == dnsmessage.TypeAAAA && ip.Is6():
+				answers = append(answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: ip.As16()}})
+			}
+		}
+		return dnsmessage.RCodeSuccess, answers
+	default:
+		return dnsmessage.RCodeSuccess, nil
+	}
+}
+
+func (s *dnsServer) update(m dnsmessage.Message) dnsmessage.RCode {
+	if strings.ToLower(m.Questions[0].Name.String()) != dnsZone || m.Questions[0].Type != dnsmessage.TypeSOA {
+		return 10 // NOTZONE
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.updateRCode != dnsmessage.RCodeSuccess {
+		return s.updateRCode
+	}
+
+	for _, r := range m.Authorities {
+		name := strings.ToLower(r.Header.Name.String())
+		if !inZone(name) {
+			return 10 // NOTZONE
+		}
+		var ip netip.Addr
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			ip = netip.AddrFrom16(body.AAAA)
+		default:
+			return dnsmessage.RCodeFormatError
+		}
+
+		switch r.Header.Class {
+		case dnsmessage.ClassINET:
+			if s.records[name] == nil {
+				s.records[name] = map[netip.Addr]uint32{}
+			}
+			s.records[name][ip] = r.Header.TTL
+		case 254: // NONE
+			delete(s.records[name], ip)
+		default:
+			return dnsmessage.RCodeFormatError
+		}
+	}
+
+	s.updates = append(s.updates, m)
+	return dnsmessage.RCodeSuccess
+}
+
+func (s *dnsServer) pack(req dnsmessage.Message, rcode dnsmessage.RCode, answers []dnsmessage.Resource, requestMAC []byte,
+) []byte {
+	resp, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{ //nolint:exhaustruct
+			ID: req.Header.ID, Response: true, OpCode: req.Header.OpCode, Authoritative: true, RCode: rcode,
+		},
+		Questions:   req.Questions,
+		Answers:     answers,
+		Authorities: nil,
+		Additionals: nil,
+	}).Pack()
+	if err != nil {
+		return nil
+	}
+
+	if s.unsignedErrors && rcode != dnsmessage.RCodeSuccess {
+		return resp
+	}
+	key := s.key
+	if s.responseKey != nil {
+		key = s.responseKey
+	}
+	if key != nil && requestMAC != nil {
+		resp, _, err = key.Sign(resp, requestMAC, time.Now())
+		if err != nil {
+			return nil
+		}
+	}
+	return resp
+}
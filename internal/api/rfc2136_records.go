@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+
+	"github.com/jellydator/ttlcache/v3"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// hintRFC2136Params warns (once) about the parameters that only make sense for Cloudflare.
+func hintRFC2136Params(ppfmt pp.PP, expectedParams RecordParams) {
+	if expectedParams.Proxied {
+		hintProxiedOnlyWithCloudflare(ppfmt)
+	}
+	if expectedParams.Comment != "" {
+		ppfmt.NoticeOncef(pp.MessageRecordCommentsUnsupported, pp.EmojiUserWarning,
+			"DNS records cannot have comments because DNS servers do not keep comments; RECORD_COMMENT is ignored")
+	}
+}
+
+// ZoneOfDomain finds the zone governing a particular domain by querying the SOA records.
+func (h RFC2136Handle) ZoneOfDomain(ctx context.Context, ppfmt pp.PP, domain domain.Domain) (string, bool) {
+	if zone := h.cache.zoneOfDomain.Get(domain.DNSNameASCII()); zone != nil {
+		return zone.Value(), true
+	}
+
+	for zoneName := range domain.Zones {
+		// The DNS root zone will not be managed by us anyways!
+		if zoneName == "" {
+			continue
+		}
+
+		name, err := newDNSName(zoneName)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the DNS query for %s: %v", zoneName, err)
+			return "", false
+		}
+
+		m, err := h.exchange(ctx, newRFC2136Query(name, dnsmessage.TypeSOA))
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s on %s: %v",
+				zoneName, h.server, err)
+			return "", false
+		}
+		switch m.Header.RCode {
+		case dnsmessage.RCodeSuccess:
+		case rfc2136RCodeNotAuth: // the TSIG key was not accepted
+			ppfmt.Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s: %s responded with %s",
+				zoneName, h.server, describeRCode(m.Header.RCode))
+			hintRFC2136Permission(ppfmt, m.Header.RCode)
+			return "", false
+		default:
+			continue
+		}
+
+		for _, answer := range m.Answers {
+			if answer.Header.Type == dnsmessage.TypeSOA && CanonicalDNSName(answer.Header.Name.String()) == name.String() {
+				h.cache.zoneOfDomain.DeleteExpired()
+				h.cache.zoneOfDomain.Set(domain.DNSNameASCII(), zoneName, ttlcache.DefaultTTL)
+				return zoneName, true
+			}
+		}
+	}
+
+	ppfmt.Noticef(pp.EmojiError, "Failed to find the zone of %s on %s", domain.Describe(), h.server)
+
+	return "", false
+}
+
+// ListRecords queries the A or AAAA records of a domain.
+func (h RFC2136Handle) ListRecords(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, domain domain.Domain,
+	expectedParams RecordParams,
+) ([]Record, bool, bool) {
+	if cachedManagedRecords := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); cachedManagedRecords != nil {
+		return *cachedManagedRecords.Value(), true, true
+	}
+
+	// Make sure the domain is in a zone that we can update.
+	if _, ok := h.ZoneOfDomain(ctx, ppfmt, domain); !ok {
+		return nil, false, false
+	}
+
+	name, err := newDNSName(domain.DNSNameASCII())
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the DNS query for %s: %v", domain.Describe(), err)
+		return nil, false, false
+	}
+
+	m, err := h.exchange(ctx, newRFC2136Query(name, rfc2136RecordType(ipNet)))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to retrieve %s records of %s: %v", ipNet.RecordType(), domain.Describe(), err)
+		return nil, false, false
+	}
+	if m.Header.RCode != dnsmessage.RCodeSuccess && m.Header.RCode != dnsmessage.RCodeNameError {
+		ppfmt.Noticef(pp.EmojiError, "Failed to retrieve %s records of %s: %s responded with %s",
+			ipNet.RecordType(), domain.Describe(), h.server, describeRCode(m.Header.RCode))
+		return nil, false, false
+	}
+
+	hintRFC2136Params(ppfmt, expectedParams)
+
+	// DNS records do not have comments.
+	managed := matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, "")
+
+	managedRecords := []Record{}
+	for _, answer := range m.Answers {
+		if CanonicalDNSName(answer.Header.Name.String()) != name.String() {
+			continue
+		}
+
+		var ip netip.Addr
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			ip = netip.AddrFrom16(body.AAAA)
+		default:
+			continue
+		}
+		if !ipNet.Matches(ip) || !managed {
+			continue
+		}
+
+		record := Record{
+			ID: ID(ip.String()),
+			IP: ip,
+			RecordParams: RecordParams{
+				TTL:     TTL(answer.Header.TTL),
+				Proxied: false,
+				Comment: "",
+			},
+		}
+		managedRecords = append(managedRecords, record)
+	}
+
+	if len(managedRecords) > 0 {
+		if expected := dnsServerTTL(expectedParams.TTL); managedRecords[0].TTL != expected {
+			hintDNSServerMismatchedTTL(ppfmt, ipNet, domain, "the DNS server", managedRecords[0].TTL, expected)
+		}
+	}
+
+	h.cache.listRecords[ipNet].DeleteExpired()
+	h.cache.listRecords[ipNet].Set(domain.DNSNameASCII(), &managedRecords, ttlcache.DefaultTTL)
+
+	return managedRecords, false, true
+}
+
+// update sends an UPDATE message with the changes to the zone of the domain.
+// The description is used in error messages, such as "delete a stale A record of example.org".
+func (h RFC2136Handle) update(ctx context.Context, ppfmt pp.PP, domain domain.Domain, description string,
+	change func(name dnsmessage.Name) []dnsmessage.Resource,
+) bool {
+	zoneName, ok := h.ZoneOfDomain(ctx, ppfmt, domain)
+	if !ok {
+		return false
+	}
+
+	zone, err := newDNSName(zoneName)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the DNS update for %s: %v", zoneName, err)
+		return false
+	}
+	name, err := newDNSName(domain.DNSNameASCII())
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the DNS update for %s: %v", domain.Describe(), err)
+		return false
+	}
+
+	m, err := h.exchange(ctx, newRFC2136Update(zone, change(name)...))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to %s: %v", description, err)
+		return false
+	}
+	if m.Header.RCode != dnsmessage.RCodeSuccess {
+		ppfmt.Noticef(pp.EmojiError, "Failed to %s: %s responded with %s",
+			description, h.server, describeRCode(m.Header.RCode))
+		hintRFC2136Permission(ppfmt, m.Header.RCode)
+		return false
+	}
+
+	return true
+}
+
+// DeleteRecord deletes one A or AAAA record.
+func (h RFC2136Handle) DeleteRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID,
+	mode DeletionMode,
+) bool {
+	ip, err := netip.ParseAddr(string(id))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the IP address in the ID %s: %v", id, err)
+		return false
+	}
+
+	if !h.update(ctx, ppfmt, domain,
+		"delete a stale "+ipNet.RecordType()+" record of "+domain.Describe()+" (ID: "+string(id)+")",
+		func(name dnsmessage.Name) []dnsmessage.Resource {
+			return []dnsmessage.Resource{newRFC2136Resource(name, rfc2136ClassNONE, 0, ip)}
+		},
+	) {
+		if mode == RegularDelitionMode {
+			h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		}
+		return false
+	}
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		*rs.Value() = slices.DeleteFunc(*rs.Value(), func(r Record) bool { return r.ID == id })
+	}
+
+	return true
+}
+
+// UpdateRecord replaces one A or AAAA record with another one in a single UPDATE message.
+// The ID of the record changes because it is the IP address.
+func (h RFC2136Handle) UpdateRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, id ID, ip netip.Addr,
+	currentParams, _ RecordParams,
+) bool {
+	oldIP, err := netip.ParseAddr(string(id))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the IP address in the ID %s: %v", id, err)
+		return false
+	}
+
+	// All records in the same set share the same TTL, so the current TTL is kept.
+	ttl := dnsServerTTL(currentParams.TTL)
+	if !h.update(ctx, ppfmt, domain,
+		"update a stale "+ipNet.RecordType()+" record of "+domain.Describe()+" (ID: "+string(id)+")",
+		func(name dnsmessage.Name) []dnsmessage.Resource {
+			return []dnsmessage.Resource{
+				newRFC2136Resource(name, rfc2136ClassNONE, 0, oldIP),
+				newRFC2136Resource(name, dnsmessage.ClassINET, uint32(ttl.Int()), ip), //nolint:gosec // TTLs are small
+			}
+		},
+	) {
+		h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		return false
+	}
+
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil {
+		updatedRecord := Record{
+			ID:           ID(ip.String()),
+			IP:           ip,
+			RecordParams: RecordParams{TTL: ttl, Proxied: false, Comment: ""},
+		}
+		*rs.Value() = slices.DeleteFunc(*rs.Value(), func(r Record) bool { return r.ID == id || r.ID == updatedRecord.ID })
+		*rs.Value() = append([]Record{updatedRecord}, *rs.Value()...)
+	}
+
+	return true
+}
+
+// CreateRecord adds one A or AAAA record. The ID of the new record is its IP address.
+func (h RFC2136Handle) CreateRecord(ctx context.Context, ppfmt pp.PP,
+	ipNet ipnet.Type, domain domain.Domain, ip netip.Addr, params RecordParams,
+) (ID, bool) {
+	ttl := dnsServerTTL(params.TTL)
+	if !h.update(ctx, ppfmt, domain,
+		"add a new "+ipNet.RecordType()+" record of "+domain.Describe(),
+		func(name dnsmessage.Name) []dnsmessage.Resource {
+			return []dnsmessage.Resource{
+				newRFC2136Resource(name, dnsmessage.ClassINET, uint32(ttl.Int()), ip), //nolint:gosec // TTLs are small
+			}
+		},
+	) {
+		h.cache.listRecords[ipNet].Delete(domain.DNSNameASCII())
+		return "", false
+	}
+
+	id := ID(ip.String())
+	if rs := h.cache.listRecords[ipNet].Get(domain.DNSNameASCII()); rs != nil &&
+		matchManagedRecordComment(h.options.ManagedRecordsCommentRegex, "") {
+		*rs.Value() = append([]Record{{ID: id, IP: ip, RecordParams: RecordParams{TTL: ttl, Proxied: false, Comment: ""}}},
+			*rs.Value()...)
+	}
+
+	return id, true
+}
@@ -0,0 +1,455 @@
+package api_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/netip"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+func newRFC2136Handle(t *testing.T, server *dnsServer, key *api.TSIGKey) api.Handle {
+	t.Helper()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	h, ok := api.RFC2136Auth{Server: server.addr(), Key: key, AllowUnsigned: key == nil}.New(mockPP, api.HandleOptions{
+		CacheExpiration:            time.Minute,
+		ManagedRecordsCommentRegex: nil,
+	})
+	require.True(t, ok)
+	return h
+}
+
+func mustPackQuery(t *testing.T) []byte {
+	t.Helper()
+
+	msg, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 0x1234}, //nolint:exhaustruct
+		Questions: []dnsmessage.Question{
+			{Name: dnsmessage.MustNewName(dnsZone), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET},
+		},
+		Answers:     nil,
+		Authorities: nil,
+		Additionals: nil,
+	}).Pack()
+	require.NoError(t, err)
+	return msg
+}
+
+func TestTSIGSignVerify(t *testing.T) {
+	t.Parallel()
+
+	key := mockTSIGKey()
+	now := time.Unix(1700000000, 0)
+	msg := mustPackQuery(t)
+
+	signed, mac, err := key.Sign(msg, nil, now)
+	require.NoError(t, err)
+	require.Len(t, mac, 32)
+
+	unsigned, verifiedMAC, err := key.Verify(signed, nil, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, msg, unsigned)
+	require.Equal(t, mac, verifiedMAC)
+
+	// A response is signed together with the MAC of the request.
+	response, _, err := key.Sign(msg, mac, now)
+	require.NoError(t, err)
+	_, _, err = key.Verify(response, mac, now)
+	require.NoError(t, err)
+	_, _, err = key.Verify(response, nil, now)
+	require.Error(t, err)
+
+	// The signature expires.
+	_, _, err = key.Verify(signed, nil, now.Add(time.Hour))
+	require.ErrorContains(t, err, "expired")
+
+	// The message cannot be changed.
+	tampered := append([]byte{}, signed...)
+	tampered[2] ^= 0x01
+	_, _, err = key.Verify(tampered, nil, now)
+	require.ErrorContains(t, err, "does not match")
+
+	// Other keys cannot verify the signature.
+	otherSecret := &api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-sha256", Secret: []byte("another secret")}
+	_, _, err = otherSecret.Verify(signed, nil, now)
+	require.ErrorContains(t, err, "does not match")
+	otherAlgorithm := &api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-sha512", Secret: key.Secret}
+	_, _, err = otherAlgorithm.Verify(signed, nil, now)
+	require.ErrorContains(t, err, "does not match")
+
+	// Unsigned messages are rejected.
+	_, _, err = key.Verify(msg, nil, now)
+	require.ErrorContains(t, err, "not signed")
+
+	_, _, err = (&api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-md5", Secret: key.Secret}).Sign(msg, nil, now)
+	require.ErrorContains(t, err, "unsupported TSIG algorithm")
+}
+
+func TestIsTSIGAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	for algorithm, ok := range map[string]bool{
+		"hmac-sha1":    true,
+		"hmac-sha224":  true,
+		"hmac-sha256":  true,
+		"HMAC-SHA256.": true,
+		"hmac-sha384":  true,
+		"hmac-sha512":  true,
+		"hmac-md5":     false,
+		"":             false,
+	} {
+		require.Equal(t, ok, api.IsTSIGAlgorithm(algorithm), algorithm)
+	}
+}
+
+func TestRFC2136AuthNew(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		auth          api.RFC2136Auth
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"unsupported-algorithm": {
+			api.RFC2136Auth{Server: "127.0.0.1:53", Key: &api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-md5", Secret: []byte("secret")}, AllowUnsigned: false},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "The TSIG algorithm %q is not supported", "hmac-md5")
+			},
+		},
+		"unsigned-not-allowed": {
+			api.RFC2136Auth{Server: "127.0.0.1:53", Key: nil, AllowUnsigned: false},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "A TSIG key is required to sign the DNS updates")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			tc.prepareMockPP(mockPP)
+
+			h, ok := tc.auth.New(mockPP, api.HandleOptions{CacheExpiration: time.Minute, ManagedRecordsCommentRegex: nil})
+			require.False(t, ok)
+			require.Nil(t, h)
+		})
+	}
+}
+
+func TestRFC2136ListRecords(t *testing.T) {
+	t.Parallel()
+
+	server := newDNSServer(t, mockTSIGKey())
+	server.set("www.example.org.", mustIP("192.0.2.1"), 60)
+	server.set("www.example.org.", mustIP("192.0.2.2"), 60)
+	server.set("www.example.org.", mustIP("2001:db8::1"), 300)
+	h := newRFC2136Handle(t, server, mockTSIGKey())
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	gomock.InOrder(
+		mockPP.EXPECT().NoticeOncef(pp.MessageProxiedOnlyWithCloudflare, pp.EmojiUserWarning, "DNS records cannot be proxied because proxying is only available with Cloudflare; PROXIED is ignored"),
+		mockPP.EXPECT().NoticeOncef(pp.MessageRecordCommentsUnsupported, pp.EmojiUserWarning, "DNS records cannot have comments because DNS servers do not keep comments; RECORD_COMMENT is ignored"),
+		// The records in an RRset share the same TTL, so the mismatch is reported once.
+		mockPP.EXPECT().Noticef(pp.EmojiUserWarning, "The TTL for the %s records of %s is %s. However, it is expected to be %s. You can either change the TTL on %s or change the expected TTL with TTL=%d.", "A", "www.example.org", "60", "300", "the DNS server", 60),
+	)
+	params := api.RecordParams{TTL: api.TTLAuto, Proxied: true, Comment: "hello"}
+
+	// DNS records do not have comments.
+	rs, cached, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), params)
+	require.True(t, ok)
+	require.False(t, cached)
+	require.ElementsMatch(t, []api.Record{
+		{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 60, Proxied: false, Comment: ""}},
+		{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 60, Proxied: false, Comment: ""}},
+	}, rs)
+
+	_, cached, ok = h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), params)
+	require.True(t, ok)
+	require.True(t, cached)
+}
+
+func TestRFC2136ListRecordsUnmanaged(t *testing.T) {
+	t.Parallel()
+
+	server := newDNSServer(t, mockTSIGKey())
+	server.set("www.example.org.", mustIP("192.0.2.1"), 300)
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	h, ok := api.RFC2136Auth{Server: server.addr(), Key: mockTSIGKey(), AllowUnsigned: false}.New(mockPP, api.HandleOptions{
+		CacheExpiration:            time.Minute,
+		ManagedRecordsCommentRegex: regexp.MustCompile("^managed$"),
+	})
+	require.True(t, ok)
+
+	// DNS records do not have comments, so the empty comment has to match MANAGED_RECORDS_COMMENT_REGEX.
+	rs, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+	require.True(t, ok)
+	require.Empty(t, rs)
+}
+
+func TestRFC2136ZoneNotFound(t *testing.T) {
+	t.Parallel()
+
+	server := newDNSServer(t, mockTSIGKey())
+	h := newRFC2136Handle(t, server, mockTSIGKey())
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to find the zone of %s on %s", "www.example.com", server.addr())
+
+	rs, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.com"),
+		api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+	require.False(t, ok)
+	require.Nil(t, rs)
+}
+
+// rfc2136Change describes one record in the update section of an UPDATE message.
+type rfc2136Change struct {
+	class dnsmessage.Class
+	ttl   uint32
+	ip    netip.Addr
+}
+
+// rfc2136Changes extracts the update section of an UPDATE message to the zone example.org.
+func rfc2136Changes(t *testing.T, m dnsmessage.Message) []rfc2136Change {
+	t.Helper()
+
+	require.Equal(t, []dnsmessage.Question{
+		{Name: dnsmessage.MustNewName(dnsZone), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET},
+	}, m.Questions)
+
+	changes := make([]rfc2136Change, 0, len(m.Authorities))
+	for _, r := range m.Authorities {
+		require.Equal(t, "www.example.org.", r.Header.Name.String())
+
+		var ip netip.Addr
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			require.Equal(t, dnsmessage.TypeA, r.Header.Type)
+			ip = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			require.Equal(t, dnsmessage.TypeAAAA, r.Header.Type)
+			ip = netip.AddrFrom16(body.AAAA)
+		default:
+			require.FailNow(t, "unexpected record", "%v", r)
+		}
+		changes = append(changes, rfc2136Change{class: r.Header.Class, ttl: r.Header.TTL, ip: ip})
+	}
+	return changes
+}
+
+// TestRFC2136Updates checks the UPDATE messages (RFC 2136, Section 2.5). Records are added
+// with the class IN and deleted one by one with the class NONE and TTL 0, so that other
+// records of the same name and type are never touched.
+func TestRFC2136Updates(t *testing.T) {
+	t.Parallel()
+
+	const classNONE dnsmessage.Class = 254
+	dom := domain.FQDN("www.example.org")
+
+	for name, tc := range map[string]struct {
+		change   func(context.Context, *mocks.MockPP, api.Handle) bool
+		updates  [][]rfc2136Change
+		records  map[netip.Addr]uint32
+		expected []api.Record
+	}{
+		"create": {
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				id, ok := h.CreateRecord(ctx, ppfmt, ipnet.IP4, dom, mustIP("192.0.2.3"), api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: ""})
+				require.Equal(t, api.ID("192.0.2.3"), id)
+				return ok
+			},
+			[][]rfc2136Change{{{dnsmessage.ClassINET, 300, mustIP("192.0.2.3")}}},
+			map[netip.Addr]uint32{mustIP("192.0.2.1"): 600, mustIP("192.0.2.2"): 600, mustIP("192.0.2.3"): 300},
+			[]api.Record{
+				{ID: "192.0.2.3", IP: mustIP("192.0.2.3"), RecordParams: api.RecordParams{TTL: 300, Proxied: false, Comment: ""}},
+				{ID: "192.0.2.1", IP: mustIP("192.0.2.1"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: ""}},
+				{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: ""}},
+			},
+		},
+		"create/ipv6": {
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				_, ok := h.CreateRecord(ctx, ppfmt, ipnet.IP6, dom, mustIP("2001:db8::1"), api.RecordParams{TTL: 120, Proxied: false, Comment: ""})
+				return ok
+			},
+			[][]rfc2136Change{{{dnsmessage.ClassINET, 120, mustIP("2001:db8::1")}}},
+			map[netip.Addr]uint32{mustIP("192.0.2.1"): 600, mustIP("192.0.2.2"): 600, mustIP("2001:db8::1"): 120},
+			nil,
+		},
+		"update": {
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				return h.UpdateRecord(ctx, ppfmt, ipnet.IP4, dom, "192.0.2.1", mustIP("192.0.2.3"),
+					api.RecordParams{TTL: 600, Proxied: false, Comment: ""}, api.RecordParams{TTL: api.TTLAuto, Proxied: false, Comment: ""})
+			},
+			// One message, so that the change is atomic; the TTL of the RRset is kept.
+			[][]rfc2136Change{{{classNONE, 0, mustIP("192.0.2.1")}, {dnsmessage.ClassINET, 600, mustIP("192.0.2.3")}}},
+			map[netip.Addr]uint32{mustIP("192.0.2.2"): 600, mustIP("192.0.2.3"): 600},
+			[]api.Record{
+				{ID: "192.0.2.3", IP: mustIP("192.0.2.3"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: ""}},
+				{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: ""}},
+			},
+		},
+		"delete": {
+			func(ctx context.Context, ppfmt *mocks.MockPP, h api.Handle) bool {
+				return h.DeleteRecord(ctx, ppfmt, ipnet.IP4, dom, "192.0.2.1", api.RegularDelitionMode)
+			},
+			[][]rfc2136Change{{{classNONE, 0, mustIP("192.0.2.1")}}},
+			map[netip.Addr]uint32{mustIP("192.0.2.2"): 600},
+			[]api.Record{
+				{ID: "192.0.2.2", IP: mustIP("192.0.2.2"), RecordParams: api.RecordParams{TTL: 600, Proxied: false, Comment: ""}},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newDNSServer(t, mockTSIGKey())
+			server.set("www.example.org.", mustIP("192.0.2.1"), 600)
+			server.set("www.example.org.", mustIP("192.0.2.2"), 600)
+			h := newRFC2136Handle(t, server, mockTSIGKey())
+			ctx := context.Background()
+			params := api.RecordParams{TTL: 600, Proxied: false, Comment: ""}
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+
+			_, _, ok := h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+			require.True(t, ok)
+
+			require.True(t, tc.change(ctx, mockPP, h))
+
+			updates := server.acceptedUpdates()
+			require.Len(t, updates, len(tc.updates))
+			for i, update := range updates {
+				require.Equal(t, tc.updates[i], rfc2136Changes(t, update))
+			}
+			require.Equal(t, tc.records, server.snapshot("www.example.org."))
+
+			// The cache follows the changes.
+			if tc.expected != nil {
+				rs, cached, ok := h.ListRecords(ctx, mockPP, ipnet.IP4, dom, params)
+				require.True(t, ok)
+				require.True(t, cached)
+				require.ElementsMatch(t, tc.expected, rs)
+			}
+		})
+	}
+}
+
+// TestRFC2136TSIGErrors checks how the TSIG signatures of the responses are verified.
+// Unsigned responses are only accepted when they report errors other than NXDOMAIN.
+func TestRFC2136TSIGErrors(t *testing.T) {
+	t.Parallel()
+
+	const hint = "Double check the TSIG key. Make sure the DNS server allows the key to update the zone"
+	otherKey := &api.TSIGKey{Name: dnsKeyName, Algorithm: "hmac-sha256", Secret: []byte("another secret")}
+	errorContaining := func(s string) gomock.Matcher {
+		return gomock.Cond(func(err error) bool { return err != nil && strings.Contains(err.Error(), s) })
+	}
+
+	for name, tc := range map[string]struct {
+		prepareServer func(*dnsServer)
+		clientKey     *api.TSIGKey
+		prepareMockPP func(*mocks.MockPP, string)
+	}{
+		"wrong-key": {
+			nil,
+			otherKey,
+			func(m *mocks.MockPP, server string) {
+				gomock.InOrder(
+					m.EXPECT().Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s: %s responded with %s", "www.example.org", server, "NotAuth"),
+					m.EXPECT().NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint, hint),
+				)
+			},
+		},
+		"unsigned-success": {
+			func(s *dnsServer) { s.key = nil },
+			mockTSIGKey(),
+			func(m *mocks.MockPP, server string) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s on %s: %v", "www.example.org", server, errorContaining("the response is not signed"))
+			},
+		},
+		"signed-by-another-key": {
+			func(s *dnsServer) { s.responseKey = otherKey },
+			mockTSIGKey(),
+			func(m *mocks.MockPP, server string) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to check the existence of a zone named %s on %s: %v", "www.example.org", server, errorContaining("does not match"))
+			},
+		},
+		"unsigned-refused": {
+			func(s *dnsServer) { s.unsignedErrors, s.updateRCode = true, dnsmessage.RCodeRefused },
+			mockTSIGKey(),
+			func(m *mocks.MockPP, server string) {
+				gomock.InOrder(
+					m.EXPECT().Noticef(pp.EmojiError, "Failed to %s: %s responded with %s", "add a new A record of www.example.org", server, "Refused"),
+					m.EXPECT().NoticeOncef(pp.MessageRecordPermission, pp.EmojiHint, hint),
+				)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newDNSServer(t, mockTSIGKey())
+			if tc.prepareServer != nil {
+				tc.prepareServer(server)
+			}
+			h := newRFC2136Handle(t, server, tc.clientKey)
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			tc.prepareMockPP(mockPP, server.addr())
+
+			_, ok := h.CreateRecord(context.Background(), mockPP, ipnet.IP4, domain.FQDN("www.example.org"), mustIP("192.0.2.1"),
+				api.RecordParams{TTL: 300, Proxied: false, Comment: ""})
+			require.False(t, ok)
+			require.Empty(t, server.snapshot("www.example.org."))
+		})
+	}
+}
+
+func TestRFC2136UpdateRefused(t *testing.T) {
+	t.Parallel()
+
+	server := newDNSServer(t, mockTSIGKey())
+	server.set("www.example.org.", mustIP("192.0.2.1"), 300)
+	h := newRFC2136Handle(t, server, mockTSIGKey())
+	dom := domain.FQDN("www.example.org")
+	params := api.RecordParams{TTL: 300, Proxied: false, Comment: ""}
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	_, _, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+
+	server.mu.Lock()
+	server.updateRCode = 8 // NXRRSet
+	server.mu.Unlock()
+	mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to %s: %s responded with %s", "delete a stale A record of www.example.org (ID: 192.0.2.1)", server.addr(), "NXRRSet")
+	ok = h.DeleteRecord(context.Background(), mockPP, ipnet.IP4, dom, "192.0.2.1", api.RegularDelitionMode)
+	require.False(t, ok)
+
+	// The cache is flushed so that the records are read again.
+	_, cached, ok := h.ListRecords(context.Background(), mockPP, ipnet.IP4, dom, params)
+	require.True(t, ok)
+	require.False(t, cached)
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // HMAC-SHA1 is still allowed by RFC 8945
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// TSIGFudge is the allowed clock skew in seconds of signed messages.
+	TSIGFudge = 300
+
+	tsigType  dnsmessage.Type  = 250
+	tsigClass dnsmessage.Class = dnsmessage.ClassANY
+
+	// dnsHeaderLength is the length of the DNS message header.
+	dnsHeaderLength = 12
+
+	// tsigRCodeBadTime is the extended error code when the clocks are not in sync.
+	tsigRCodeBadTime = 18
+)
+
+var (
+	errTSIGMissing   = errors.New("the response is not signed with TSIG")
+	errTSIGMalformed = errors.New("malformed TSIG record")
+	errTSIGMismatch  = errors.New("the TSIG signature does not match")
+	errTSIGBadTime   = errors.New("the TSIG signature has expired; check the clocks")
+	errTSIGAlgorithm = errors.New("unsupported TSIG algorithm")
+)
+
+// tsigAlgorithms are the supported TSIG algorithms (RFC 8945, Section 6).
+//
+//nolint:gochecknoglobals
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1.":   sha1.New,
+	"hmac-sha224.": sha256.New224,
+	"hmac-sha256.": sha256.New,
+	"hmac-sha384.": sha512.New384,
+	"hmac-sha512.": sha512.New,
+}
+
+// CanonicalDNSName lowercases a domain name and adds the final dot.
+func CanonicalDNSName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// IsTSIGAlgorithm checks whether a TSIG algorithm, such as "hmac-sha256", is supported.
+func IsTSIGAlgorithm(algorithm string) bool {
+	_, found := tsigAlgorithms[CanonicalDNSName(algorithm)]
+	return found
+}
+
+// appendWireName appends the uncompressed wire format of a canonical domain name.
+func appendWireName(b []byte, name string) []byte {
+	for label := range strings.SplitSeq(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// readWireName reads an uncompressed domain name and returns it in the canonical form.
+func readWireName(b []byte) (string, []byte, bool) {
+	var labels []string
+	for {
+		if len(b) == 0 {
+			return "", nil, false
+		}
+		n := int(b[0])
+		b = b[1:]
+		if n == 0 {
+			return CanonicalDNSName(strings.Join(labels, ".")), b, true
+		}
+		if n > 63 || len(b) < n {
+			return "", nil, false // compressed or truncated
+		}
+		labels = append(labels, string(b[:n]))
+		b = b[n:]
+	}
+}
+
+// TSIGKey is a shared secret to sign DNS messages (RFC 8945).
+type TSIGKey struct {
+	Name      string // name of the key, such as "ddns-key."
+	Algorithm string // name of the algorithm, such as "hmac-sha256."
+	Secret    []byte // the shared secret
+}
+
+// tsigRecord holds the fields of a TSIG record.
+type tsigRecord struct {
+	name       string
+	algorithm  string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	originalID uint16
+	errorCode  uint16
+	otherData  []byte
+}
+
+// appendVariables appends the TSIG variables covered by the MAC (RFC 8945, Section 4.3.3).
+func (r tsigRecord) appendVariables(b []byte) []byte {
+	b = appendWireName(b, r.name)
+	b = binary.BigEndian.AppendUint16(b, uint16(tsigClass))
+	b = binary.BigEndian.AppendUint32(b, 0) // TTL
+	b = appendWireName(b, r.algorithm)
+	b = binary.BigEndian.AppendUint16(b, uint16(r.timeSigned>>32)) //nolint:gosec // 48-bit time
+	b = binary.BigEndian.AppendUint32(b, uint32(r.timeSigned))     //nolint:gosec // 48-bit time
+	b = binary.BigEndian.AppendUint16(b, r.fudge)
+	b = binary.BigEndian.AppendUint16(b, r.errorCode)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(r.otherData))) //nolint:gosec // short data
+	return append(b, r.otherData...)
+}
+
+// appendRecord appends the wire format of the TSIG record.
+func (r tsigRecord) appendRecord(b []byte) []byte {
+	rdata := appendWireName(nil, r.algorithm)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(r.timeSigned>>32)) //nolint:gosec // 48-bit time
+	rdata = binary.BigEndian.AppendUint32(rdata, uint32(r.timeSigned))     //nolint:gosec // 48-bit time
+	rdata = binary.BigEndian.AppendUint16(rdata, r.fudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(r.mac))) //nolint:gosec // short MAC
+	rdata = append(rdata, r.mac...)
+	rdata = binary.BigEndian.AppendUint16(rdata, r.originalID)
+	rdata = binary.BigEndian.AppendUint16(rdata, r.errorCode)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(r.otherData))) //nolint:gosec // short data
+	rdata = append(rdata, r.otherData...)
+
+	b = appendWireName(b, r.name)
+	b = binary.BigEndian.AppendUint16(b, uint16(tsigType))
+	b = binary.BigEndian.AppendUint16(b, uint16(tsigClass))
+	b = binary.BigEndian.AppendUint32(b, 0)                  // TTL
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata))) //nolint:gosec // short record
+	return append(b, rdata...)
+}
+
+// parseTSIGRData parses the RDATA of a TSIG record.
+func parseTSIGRData(name string, rdata []byte) (tsigRecord, bool) {
+	algorithm, rest, ok := readWireName(rdata)
+	if !ok || len(rest) < 10 {
+		return tsigRecord{}, false
+	}
+	r := tsigRecord{ //nolint:exhaustruct // filled below
+		name:       name,
+		algorithm:  algorithm,
+		timeSigned: uint64(binary.BigEndian.Uint16(rest[0:2]))<<32 | uint64(binary.BigEndian.Uint32(rest[2:6])),
+		fudge:      binary.BigEndian.Uint16(rest[6:8]),
+	}
+	macSize := int(binary.BigEndian.Uint16(rest[8:10]))
+	rest = rest[10:]
+	if len(rest) < macSize+6 {
+		return tsigRecord{}, false
+	}
+	r.mac, rest = rest[:macSize], rest[macSize:]
+	r.originalID = binary.BigEndian.Uint16(rest[0:2])
+	r.errorCode = binary.BigEndian.Uint16(rest[2:4])
+	otherLen := int(binary.BigEndian.Uint16(rest[4:6]))
+	rest = rest[6:]
+	if len(rest) != otherLen {
+		return tsigRecord{}, false
+	}
+	r.otherData = rest
+	return r, true
+}
+
+// mac computes the MAC of a message without its TSIG record.
+// The MAC of the request is prepended when signing or verifying a response.
+func (k TSIGKey) mac(unsigned []byte, requestMAC []byte, r tsigRecord) []byte {
+	h := hmac.New(tsigAlgorithms[CanonicalDNSName(k.Algorithm)], k.Secret)
+	if requestMAC != nil {
+		h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC)))) //nolint:gosec // short MAC
+		h.Write(requestMAC)
+	}
+	h.Write(unsigned)
+	h.Write(r.appendVariables(nil))
+	return h.Sum(nil)
+}
+
+// Sign appends a TSIG record to a packed DNS message. The MAC of the request
+// must be given when signing a response, and it must be nil when signing a request.
+// It returns the signed message and its MAC.
+func (k TSIGKey) Sign(msg []byte, requestMAC []byte, now time.Time) ([]byte, []byte, error) {
+	if len(msg) < dnsHeaderLength {
+		return nil, nil, errTSIGMalformed
+	}
+	if !IsTSIGAlgorithm(k.Algorithm) {
+		return nil, nil, fmt.Errorf("%w %q", errTSIGAlgorithm, k.Algorithm)
+	}
+
+	r := tsigRecord{
+		name:       CanonicalDNSName(k.Name),
+		algorithm:  CanonicalDNSName(k.Algorithm),
+		timeSigned: uint64(now.Unix()), //nolint:gosec // the time is after 1970
+		fudge:      TSIGFudge,
+		mac:        nil,
+		originalID: binary.BigEndian.Uint16(msg[0:2]),
+		errorCode:  0,
+		otherData:  nil,
+	}
+	r.mac = k.mac(msg, requestMAC, r)
+
+	signed := r.appendRecord(append([]byte{}, msg...))
+	binary.BigEndian.PutUint16(signed[10:12], binary.BigEndian.Uint16(msg[10:12])+1) // ARCOUNT
+	return signed, r.mac, nil
+}
+
+// Verify checks the TSIG record at the end of a packed DNS message. The MAC of
+// the request must be given when verifying a response, and it must be nil when
+// verifying a request. It returns the message without the TSIG record and its MAC.
+func (k TSIGKey) Verify(msg []byte, requestMAC []byte, now time.Time) ([]byte, []byte, error) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return nil, nil, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return nil, nil, err
+	}
+	var last dnsmessage.ResourceHeader
+	found := false
+	for {
+		rh, err := p.AdditionalHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return nil, nil, err
+		}
+		last, found = rh, true
+	}
+	if !found || last.Type != tsigType {
+		return nil, nil, errTSIGMissing
+	}
+
+	// The TSIG record must be the last one and its names must not be compressed.
+	name := CanonicalDNSName(last.Name.String())
+	rrStart := len(msg) - int(last.Length) - 10 - len(appendWireName(nil, name))
+	if rrStart < dnsHeaderLength {
+		return nil, nil, errTSIGMalformed
+	}
+	if wireName, _, ok := readWireName(msg[rrStart:]); !ok || wireName != name {
+		return nil, nil, errTSIGMalformed
+	}
+	r, ok := parseTSIGRData(name, msg[len(msg)-int(last.Length):])
+	if !ok {
+		return nil, nil, errTSIGMalformed
+	}
+	if r.name != CanonicalDNSName(k.Name) || r.algorithm != CanonicalDNSName(k.Algorithm) {
+		return nil, nil, fmt.Errorf("%w: signed with the key %q (%s)", errTSIGMismatch, r.name, r.algorithm)
+	}
+
+	switch {
+	case r.errorCode == tsigRCodeBadTime:
+		return nil, nil, errTSIGBadTime
+	case r.errorCode != 0:
+		return nil, nil, fmt.Errorf("%w: TSIG error %d", errTSIGMismatch, r.errorCode)
+	}
+
+	unsigned := append([]byte{}, msg[:rrStart]...)
+	binary.BigEndian.PutUint16(unsigned[0:2], r.originalID)
+	binary.BigEndian.PutUint16(unsigned[10:12], binary.BigEndian.Uint16(msg[10:12])-1)
+	if !hmac.Equal(r.mac, k.mac(unsigned, requestMAC, r)) {
+		return nil, nil, errTSIGMismatch
+	}
+
+	signedAt := time.Unix(int64(r.timeSigned), 0) //nolint:gosec // 48-bit time
+	if diff := now.Sub(signedAt).Abs(); diff > time.Duration(r.fudge)*time.Second {
+		return nil, nil, errTSIGBadTime
+	}
+
+	return unsigned, r.mac, nil
+}
@@ -3,6 +3,7 @@
 package config
 
 import (
+	"net/netip"
 	"time"
 
 	"github.com/favonia/cloudflare-ddns/internal/api"
@@ -24,10 +25,18 @@ type RawConfig struct {
 	Domains                    []domain.Domain
 	IP4Domains                 []domain.Domain
 	IP6Domains                 []domain.Domain
+	InterfaceIDs               map[domain.Domain]netip.Prefix
+	AllowedRanges              map[ipnet.Type][]netip.Prefix
+	DeniedRanges               map[ipnet.Type][]netip.Prefix
+	DetectNAT                  bool
 	WAFLists                   []api.WAFList
 	UpdateCron                 cron.Schedule
 	UpdateOnStart              bool
 	DeleteOnStop               bool
+	UpdateOnNetworkChange      bool
+	DryRun                     bool
+	IPChangeConfirmations      int
+	IPChangeStableFor          time.Duration
 	TTL                        api.TTL
 	ProxiedExpression          string
 	RecordComment              string
@@ -59,16 +68,21 @@ type HandleConfig struct {
 // and shutdown behavior.
 // (The timezone is handled directly by the standard library reading the TZ environment variable.)
 type LifecycleConfig struct {
-	UpdateCron    cron.Schedule
-	UpdateOnStart bool
-	DeleteOnStop  bool
+	UpdateCron            cron.Schedule
+	UpdateOnStart         bool
+	DeleteOnStop          bool
+	UpdateOnNetworkChange bool // also check the IP addresses when network addresses or routes change
 }
 
 // UpdateConfig holds the validated settings used during IP detection and
 // DNS/WAF reconciliation.
 type UpdateConfig struct {
 	Provider           map[ipnet.Type]provider.Provider
+	NATProbe           provider.Provider // a local provider to detect NAT of IPv4; nil to skip
 	Domains            map[ipnet.Type][]domain.Domain
+	InterfaceIDs       map[domain.Domain]netip.Prefix // IPv6 interface identifiers of domains
+	AllowedRanges      map[ipnet.Type][]netip.Prefix  // if nonempty, detected IPs must be in one of them
+	DeniedRanges       map[ipnet.Type][]netip.Prefix  // detected IPs must not be in any of them
 	WAFLists           []api.WAFList
 	TTL                api.TTL
 	Proxied            map[domain.Domain]bool
@@ -76,6 +90,12 @@ type UpdateConfig struct {
 	WAFListDescription string
 	DetectionTimeout   time.Duration
 	UpdateTimeout      time.Duration
+	DryRun             bool // the API handle only plans the changes; see [api.DryRunHandle]
+
+	// A change of detected IP addresses is published only after it is seen
+	// IPChangeConfirmations times in a row and stays the same for IPChangeStableFor.
+	IPChangeConfirmations int
+	IPChangeStableFor     time.Duration
 }
 
 // DefaultRaw gives the default raw updater configuration used before reading
@@ -90,10 +110,18 @@ func DefaultRaw() *RawConfig {
 		Domains:                    nil,
 		IP4Domains:                 nil,
 		IP6Domains:                 nil,
+		InterfaceIDs:               nil,
+		AllowedRanges:              nil,
+		DeniedRanges:               nil,
+		DetectNAT:                  true,
 		WAFLists:                   nil,
 		UpdateCron:                 cron.MustNew("@every 5m"),
 		UpdateOnStart:              true,
 		DeleteOnStop:               false,
+		UpdateOnNetworkChange:      false,
+		DryRun:                     false,
+		IPChangeConfirmations:      1,
+		IPChangeStableFor:          0,
 		TTL:                        api.TTLAuto,
 		ProxiedExpression:          "false",
 		RecordComment:              "",
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -103,6 +106,19 @@ func Print(ppfmt pp.PP, built *BuiltConfig, hb heartbeat.Heartbeat, nt notifier.
 			item(ipNet.Describe()+" provider:", "%s", provider.Name(p))
 		}
 	}
+	if len(update.InterfaceIDs) > 0 {
+		doms := slices.Collect(maps.Keys(update.InterfaceIDs))
+		domain.SortDomains(doms)
+		item("IPv6 interface identifiers:", "%s", pp.JoinMap(func(dom domain.Domain) string {
+			return dom.Describe() + "=" + update.InterfaceIDs[dom].String()
+		}, doms))
+	}
+	for ipNet, ranges := range ipnet.Bindings(update.AllowedRanges) {
+		item(ipNet.Describe()+" allowed ranges:", "%s", pp.JoinMap(netip.Prefix.String, ranges))
+	}
+	for ipNet, ranges := range ipnet.Bindings(update.DeniedRanges) {
+		item(ipNet.Describe()+" denied ranges:", "%s", pp.JoinMap(netip.Prefix.String, ranges))
+	}
 	item("WAF lists:", "%s", pp.JoinMap(api.WAFList.Describe, update.WAFLists))
 
 	managedRecordsCommentRegex := ""
@@ -122,7 +138,20 @@ func Print(ppfmt pp.PP, built *BuiltConfig, hb heartbeat.Heartbeat, nt notifier.
 	item("Update schedule:", "%s", cron.DescribeSchedule(lifecycle.UpdateCron))
 	item("Update on start?", "%t", lifecycle.UpdateOnStart)
 	item("Delete on stop?", "%t", lifecycle.DeleteOnStop)
+	// Hide the watcher of network changes unless it is enabled.
+	if lifecycle.UpdateOnNetworkChange {
+		item("Update on network change?", "%t", lifecycle.UpdateOnNetworkChange)
+	}
+	// Hide the dry-run mode unless it is enabled.
+	if update.DryRun {
+		item("Dry run?", "%t", update.DryRun)
+	}
 	item("Cache expiration:", "%v", handle.Options.CacheExpiration)
+	// Hide the confirmation of IP changes unless it is enabled.
+	if update.IPChangeConfirmations > 1 || update.IPChangeStableFor > 0 {
+		item("IP change confirmations:", "%d", max(update.IPChangeConfirmations, 1))
+		item("IP change stable for:", "%v", update.IPChangeStableFor)
+	}
 
 	section("Parameters of new DNS records and WAF lists:")
 	// These settings are defaults or targets for managed objects when creating or updating.
@@ -1,8 +1,10 @@
 package config_test
 
 import (
+	"net/netip"
 	"regexp"
 	"testing"
+	"time"
 
 	"go.uber.org/mock/gomock"
 
@@ -114,6 +116,9 @@ func TestPrintValues(t *testing.T) {
 		printItem(t, innerMockPP, "IPv4 provider:", "cloudflare.trace"),
 		printItem(t, innerMockPP, "IPv6-enabled domains:", "test6.org, *.test6.org"),
 		printItem(t, innerMockPP, "IPv6 provider:", "cloudflare.trace"),
+		printItem(t, innerMockPP, "IPv6 interface identifiers:", "*.test6.org=::1/56, test6.org=::1234:5678/64"),
+		printItem(t, innerMockPP, "IPv6 allowed ranges:", "2001:db8::/32"),
+		printItem(t, innerMockPP, "IPv4 denied ranges:", "10.0.0.0/8, 100.64.0.0/10"),
 		printItem(t, innerMockPP, "WAF lists:", "(none)"),
 		mockPP.EXPECT().Infof(pp.EmojiConfig, "%s", "Ownership filters:"),
 		printItem(t, innerMockPP, "DNS record comment regex:", "^Created by Cloudflare DDNS$"),
@@ -122,7 +127,11 @@ func TestPrintValues(t *testing.T) {
 		printItem(t, innerMockPP, "Update schedule:", "@every 5m"),
 		printItem(t, innerMockPP, "Update on start?", "true"),
 		printItem(t, innerMockPP, "Delete on stop?", "false"),
+		printItem(t, innerMockPP, "Update on network change?", "true"),
+		printItem(t, innerMockPP, "Dry run?", "true"),
 		printItem(t, innerMockPP, "Cache expiration:", "6h0m0s"),
+		printItem(t, innerMockPP, "IP change confirmations:", "3"),
+		printItem(t, innerMockPP, "IP change stable for:", "2m0s"),
 		mockPP.EXPECT().Infof(pp.EmojiConfig, "%s", "Parameters of new DNS records and WAF lists:"),
 		printItem(t, innerMockPP, "TTL:", "30000"),
 		printItem(t, innerMockPP, "Proxied domains:", "a, b"),
@@ -145,6 +154,20 @@ func TestPrintValues(t *testing.T) {
 	builtConfig := defaultPrintedConfig(raw)
 	builtConfig.Update.Domains[ipnet.IP4] = []domain.Domain{domain.FQDN("test4.org"), domain.Wildcard("test4.org")}
 	builtConfig.Update.Domains[ipnet.IP6] = []domain.Domain{domain.FQDN("test6.org"), domain.Wildcard("test6.org")}
+	builtConfig.Update.InterfaceIDs = map[domain.Domain]netip.Prefix{
+		domain.Wildcard("test6.org"): netip.MustParsePrefix("::1/56"),
+		domain.FQDN("test6.org"):     netip.MustParsePrefix("::1234:5678/64"),
+	}
+	builtConfig.Update.AllowedRanges = map[ipnet.Type][]netip.Prefix{
+		ipnet.IP6: {netip.MustParsePrefix("2001:db8::/32")},
+	}
+	builtConfig.Update.DeniedRanges = map[ipnet.Type][]netip.Prefix{
+		ipnet.IP4: {netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("100.64.0.0/10")},
+	}
+	builtConfig.Lifecycle.UpdateOnNetworkChange = true
+	builtConfig.Update.DryRun = true
+	builtConfig.Update.IPChangeConfirmations = 3
+	builtConfig.Update.IPChangeStableFor = 2 * time.Minute
 	builtConfig.Update.TTL = 30000
 	builtConfig.Update.Proxied[domain.FQDN("a")] = true
 	builtConfig.Update.Proxied[domain.FQDN("b")] = true
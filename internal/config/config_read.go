@@ -1,7 +1,10 @@
 package config
 
 import (
+	"maps"
+	"net/netip"
 	"regexp"
+	"slices"
 
 	"github.com/favonia/cloudflare-ddns/internal/api"
 	"github.com/favonia/cloudflare-ddns/internal/domain"
@@ -32,10 +35,18 @@ func (c *RawConfig) ReadEnv(ppfmt pp.PP) bool {
 		!ReadDomains(ppfmt, "DOMAINS", &c.Domains) ||
 		!ReadDomains(ppfmt, "IP4_DOMAINS", &c.IP4Domains) ||
 		!ReadDomains(ppfmt, "IP6_DOMAINS", &c.IP6Domains) ||
+		!ReadInterfaceIDs(ppfmt, "IP6_INTERFACE_IDS", &c.InterfaceIDs) ||
+		!ReadRangeMap(ppfmt, "ALLOWED_RANGES", &c.AllowedRanges) ||
+		!ReadRangeMap(ppfmt, "DENIED_RANGES", &c.DeniedRanges) ||
+		!ReadBool(ppfmt, "DETECT_NAT", &c.DetectNAT) ||
 		!ReadWAFListNames(ppfmt, "WAF_LISTS", &c.WAFLists) ||
 		!ReadCron(ppfmt, "UPDATE_CRON", &c.UpdateCron) ||
 		!ReadBool(ppfmt, "UPDATE_ON_START", &c.UpdateOnStart) ||
 		!ReadBool(ppfmt, "DELETE_ON_STOP", &c.DeleteOnStop) ||
+		!ReadBool(ppfmt, "UPDATE_ON_NETWORK_CHANGE", &c.UpdateOnNetworkChange) ||
+		!ReadBool(ppfmt, "DRY_RUN", &c.DryRun) ||
+		!ReadNonnegInt(ppfmt, "IP_CHANGE_CONFIRMATIONS", &c.IPChangeConfirmations) ||
+		!ReadNonnegDuration(ppfmt, "IP_CHANGE_STABLE_FOR", &c.IPChangeStableFor) ||
 		!ReadNonnegDuration(ppfmt, "CACHE_EXPIRATION", &c.CacheExpiration) ||
 		!ReadTTL(ppfmt, "TTL", &c.TTL) ||
 		!ReadString(ppfmt, "PROXIED", &c.ProxiedExpression) ||
@@ -67,6 +78,40 @@ func normalizeDomainMap(raw *RawConfig) map[ipnet.Type][]domain.Domain {
 	}
 }
 
+// keepEnabledRanges drops the IP ranges of disabled IP networks.
+func keepEnabledRanges(ppfmt pp.PP, suffix string,
+	providerMap map[ipnet.Type]provider.Provider, ranges map[ipnet.Type][]netip.Prefix,
+) map[ipnet.Type][]netip.Prefix {
+	var kept map[ipnet.Type][]netip.Prefix
+	for ipNet, r := range ipnet.Bindings(ranges) {
+		if len(r) == 0 {
+			continue
+		}
+		if providerMap[ipNet] == nil {
+			ppfmt.Noticef(pp.EmojiUserWarning, "IP%d_%s is ignored because %s is disabled",
+				ipNet.Int(), suffix, ipNet.Describe())
+			continue
+		}
+		if kept == nil {
+			kept = map[ipnet.Type][]netip.Prefix{}
+		}
+		kept[ipNet] = r
+	}
+	return kept
+}
+
+// otherBackend returns the value of API_BACKEND for DNS servers other than Cloudflare.
+func otherBackend(auth api.Auth) (string, bool) {
+	switch auth.(type) {
+	case *api.RFC2136Auth:
+		return "rfc2136", true
+	case *api.PowerDNSAuth:
+		return "powerdns", true
+	default:
+		return "", false
+	}
+}
+
 // BuildConfig checks and derives configuration invariants, including:
 // - provider and domain canonicalization
 // - [HandleConfig.Options]'s managed-record selector compilation
@@ -90,6 +135,14 @@ func (c *RawConfig) BuildConfig(ppfmt pp.PP) (*BuiltConfig, bool) {
 		return nil, false
 	}
 
+	// Step 1.1: WAF lists are only available with Cloudflare.
+	if backend, ok := otherBackend(c.Auth); ok && len(c.WAFLists) > 0 {
+		ppfmt.Noticef(pp.EmojiUserError,
+			"WAF_LISTS cannot be used with %s=%s because WAF lists are only available with Cloudflare",
+			BackendKey, backend)
+		return nil, false
+	}
+
 	// Part 2: check DELETE_ON_STOP and UpdateOnStart
 	if c.UpdateCron == nil {
 		if !c.UpdateOnStart {
@@ -106,6 +159,18 @@ func (c *RawConfig) BuildConfig(ppfmt pp.PP) (*BuiltConfig, bool) {
 		}
 	}
 
+	// Step 2.2: check if changes of IP addresses can be confirmed
+	if c.UpdateCron == nil && (c.IPChangeConfirmations > 1 || c.IPChangeStableFor > 0) {
+		ppfmt.Noticef(pp.EmojiUserWarning,
+			"IP_CHANGE_CONFIRMATIONS=%d and IP_CHANGE_STABLE_FOR=%v are ignored when UPDATE_CRON=@once",
+			c.IPChangeConfirmations, c.IPChangeStableFor)
+	}
+
+	// Step 2.3: check if network changes can be watched
+	if c.UpdateCron == nil && c.UpdateOnNetworkChange {
+		ppfmt.Noticef(pp.EmojiUserWarning, "UPDATE_ON_NETWORK_CHANGE=true is ignored when UPDATE_CRON=@once")
+	}
+
 	// Step 2.5: compile the ownership selector for managed DNS records.
 	managedRecordsCommentRegex, err := regexp.Compile(c.ManagedRecordsCommentRegex)
 	if err != nil {
@@ -165,6 +230,39 @@ func (c *RawConfig) BuildConfig(ppfmt pp.PP) (*BuiltConfig, bool) {
 		}
 	}
 
+	// Step 3.4: keep only the interface identifiers of IPv6-enabled domains.
+	var interfaceIDs map[domain.Domain]netip.Prefix
+	if len(c.InterfaceIDs) > 0 {
+		if providerMap[ipnet.IP6] == nil {
+			ppfmt.Noticef(pp.EmojiUserWarning, "IP6_INTERFACE_IDS is ignored because IPv6 is disabled")
+		} else {
+			interfaceIDs = map[domain.Domain]netip.Prefix{}
+			doms := slices.Collect(maps.Keys(c.InterfaceIDs))
+			domain.SortDomains(doms)
+			for _, dom := range doms {
+				if !slices.Contains(domains[ipnet.IP6], dom) {
+					ppfmt.Noticef(pp.EmojiUserWarning,
+						"IP6_INTERFACE_IDS contains %q, which is ignored because its IPv6 addresses are not updated",
+						dom.Describe())
+					continue
+				}
+				interfaceIDs[dom] = c.InterfaceIDs[dom]
+			}
+		}
+	}
+
+	// Step 3.5: keep only the allowed and denied ranges of enabled IP networks.
+	allowedRanges := keepEnabledRanges(ppfmt, "ALLOWED_RANGES", providerMap, c.AllowedRanges)
+	deniedRanges := keepEnabledRanges(ppfmt, "DENIED_RANGES", providerMap, c.DeniedRanges)
+
+	// Step 3.6: compare the local IPv4 address with the detected one to detect NAT.
+	// Only a remote provider sees the address from the outside; others, such as "tailscale",
+	// are expected to detect addresses different from the local one.
+	var natProbe provider.Provider
+	if c.DetectNAT {
+		natProbe = provider.NewNATProbe(providerMap[ipnet.IP4])
+	}
+
 	// Step 4: regenerate proxiedMap from the raw PROXIED expression.
 	proxiedMap := map[domain.Domain]bool{}
 	if len(activeDomainSet) > 0 {
@@ -204,21 +302,32 @@ func (c *RawConfig) BuildConfig(ppfmt pp.PP) (*BuiltConfig, bool) {
 		}
 	}
 
+	// Step 6: wrap the authentication so that the handle only plans the changes in the dry-run mode.
+	auth := c.Auth
+	if c.DryRun {
+		auth = api.DryRunAuth{Auth: auth}
+	}
+
 	handleConfig := &HandleConfig{
-		Auth: c.Auth,
+		Auth: auth,
 		Options: api.HandleOptions{
 			CacheExpiration:            c.CacheExpiration,
 			ManagedRecordsCommentRegex: managedRecordsCommentRegex,
 		},
 	}
 	lifecycleConfig := &LifecycleConfig{
-		UpdateCron:    c.UpdateCron,
-		UpdateOnStart: c.UpdateOnStart,
-		DeleteOnStop:  c.DeleteOnStop,
+		UpdateCron:            c.UpdateCron,
+		UpdateOnStart:         c.UpdateOnStart,
+		DeleteOnStop:          c.DeleteOnStop,
+		UpdateOnNetworkChange: c.UpdateOnNetworkChange,
 	}
 	updateConfig := &UpdateConfig{
 		Provider:           providerMap,
+		NATProbe:           natProbe,
 		Domains:            domains,
+		InterfaceIDs:       interfaceIDs,
+		AllowedRanges:      allowedRanges,
+		DeniedRanges:       deniedRanges,
 		WAFLists:           c.WAFLists,
 		TTL:                c.TTL,
 		Proxied:            proxiedMap,
@@ -226,6 +335,10 @@ func (c *RawConfig) BuildConfig(ppfmt pp.PP) (*BuiltConfig, bool) {
 		WAFListDescription: c.WAFListDescription,
 		DetectionTimeout:   c.DetectionTimeout,
 		UpdateTimeout:      c.UpdateTimeout,
+		DryRun:             c.DryRun,
+
+		IPChangeConfirmations: c.IPChangeConfirmations,
+		IPChangeStableFor:     c.IPChangeStableFor,
 	}
 
 	return &BuiltConfig{
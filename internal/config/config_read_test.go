@@ -3,6 +3,7 @@ package config_test
 // vim: nowrap
 
 import (
+	"net/netip"
 	"regexp"
 	"testing"
 	"time"
@@ -24,11 +25,20 @@ func unsetAll(t *testing.T) {
 	unset(t,
 		"CLOUDFLARE_API_TOKEN", "CLOUDFLARE_API_TOKEN_FILE",
 		"CF_API_TOKEN", "CF_API_TOKEN_FILE", "CF_ACCOUNT_ID",
+		"API_BACKEND", "RFC2136_SERVER", "RFC2136_TSIG_KEY_NAME", "RFC2136_TSIG_ALGORITHM",
+		"RFC2136_TSIG_SECRET", "RFC2136_TSIG_SECRET_FILE", "RFC2136_ALLOW_UNSIGNED",
+		"POWERDNS_API_URL", "POWERDNS_API_KEY", "POWERDNS_API_KEY_FILE", "POWERDNS_SERVER_ID",
 		"IP4_PROVIDER", "IP6_PROVIDER",
-		"DOMAINS", "IP4_DOMAINS", "IP6_DOMAINS", "WAF_LISTS",
+		"DOMAINS", "IP4_DOMAINS", "IP6_DOMAINS", "IP6_INTERFACE_IDS", "WAF_LISTS",
+		"IP4_ALLOWED_RANGES", "IP6_ALLOWED_RANGES", "IP4_DENIED_RANGES", "IP6_DENIED_RANGES",
+		"DETECT_NAT",
 		"UPDATE_CRON",
 		"UPDATE_ON_START",
 		"DELETE_ON_STOP",
+		"UPDATE_ON_NETWORK_CHANGE",
+		"DRY_RUN",
+		"IP_CHANGE_CONFIRMATIONS",
+		"IP_CHANGE_STABLE_FOR",
 		"CACHE_EXPIRATION",
 		"TTL",
 		"PROXIED",
@@ -59,9 +69,14 @@ func TestReadEnvWithOnlyToken(t *testing.T) {
 		mockPP.EXPECT().Indent().Return(innerMockPP),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%s", "IP4_PROVIDER", "none"),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%s", "IP6_PROVIDER", "none"),
+		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%t", "DETECT_NAT", false),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%s", "UPDATE_CRON", "@once"),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%t", "UPDATE_ON_START", false),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%t", "DELETE_ON_STOP", false),
+		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%t", "UPDATE_ON_NETWORK_CHANGE", false),
+		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%t", "DRY_RUN", false),
+		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%d", "IP_CHANGE_CONFIRMATIONS", 0),
+		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%v", "IP_CHANGE_STABLE_FOR", time.Duration(0)),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%v", "CACHE_EXPIRATION", time.Duration(0)),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%d", "TTL", api.TTL(0)),
 		innerMockPP.EXPECT().Infof(pp.EmojiBullet, "Use default %s=%v", "DETECTION_TIMEOUT", time.Duration(0)),
@@ -122,6 +137,38 @@ func TestBuildConfig(t *testing.T) {
 				)
 			},
 		},
+		"waf/rfc2136": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				Auth:     &api.RFC2136Auth{Server: "ns.example.org:53", Key: nil, AllowUnsigned: true},
+				WAFLists: []api.WAFList{{AccountID: "account", Name: "list"}},
+			},
+			ok:       false,
+			expected: nil,
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserError, "WAF_LISTS cannot be used with %s=%s because WAF lists are only available with Cloudflare", "API_BACKEND", "rfc2136"),
+				)
+			},
+		},
+		"waf/powerdns": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				Auth:     &api.PowerDNSAuth{BaseURL: "http://localhost:8081", APIKey: "key", ServerID: "localhost"},
+				WAFLists: []api.WAFList{{AccountID: "account", Name: "list"}},
+			},
+			ok:       false,
+			expected: nil,
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserError, "WAF_LISTS cannot be used with %s=%s because WAF lists are only available with Cloudflare", "API_BACKEND", "powerdns"),
+				)
+			},
+		},
 		"once/update-on-start": {
 			input: &config.RawConfig{ //nolint:exhaustruct
 				UpdateOnStart: false,
@@ -155,6 +202,52 @@ func TestBuildConfig(t *testing.T) {
 				)
 			},
 		},
+		"once/ip-change": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: nil,
+					ipnet.IP6: nil,
+				},
+				IP4Domains:            []domain.Domain{domain.FQDN("a.b.c")},
+				IPChangeConfirmations: 3,
+				ProxiedExpression:     "false",
+			},
+			ok:       false,
+			expected: nil,
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP_CHANGE_CONFIRMATIONS=%d and IP_CHANGE_STABLE_FOR=%v are ignored when UPDATE_CRON=@once", 3, time.Duration(0)),
+					m.EXPECT().Noticef(pp.EmojiUserError, "Nothing to update because both IP4_PROVIDER and IP6_PROVIDER are %q", "none"),
+				)
+			},
+		},
+		"once/network-change": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: nil,
+					ipnet.IP6: nil,
+				},
+				IP4Domains:            []domain.Domain{domain.FQDN("a.b.c")},
+				UpdateOnNetworkChange: true,
+				ProxiedExpression:     "false",
+			},
+			ok:       false,
+			expected: nil,
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "UPDATE_ON_NETWORK_CHANGE=true is ignored when UPDATE_CRON=@once"),
+					m.EXPECT().Noticef(pp.EmojiUserError, "Nothing to update because both IP4_PROVIDER and IP6_PROVIDER are %q", "none"),
+				)
+			},
+		},
 		"nilprovider": {
 			input: &config.RawConfig{ //nolint:exhaustruct
 				UpdateOnStart: true,
@@ -178,6 +271,7 @@ func TestBuildConfig(t *testing.T) {
 		},
 		"dns6empty": {
 			input: &config.RawConfig{ //nolint:exhaustruct
+				DetectNAT:        true,
 				UpdateOnStart:    true,
 				DetectionTimeout: 5 * time.Second,
 				Provider: map[ipnet.Type]provider.Provider{
@@ -200,6 +294,50 @@ func TestBuildConfig(t *testing.T) {
 					Provider: map[ipnet.Type]provider.Provider{
 						ipnet.IP4: provider.NewCloudflareTrace(),
 					},
+					NATProbe: provider.NewLocal(),
+					Domains: map[ipnet.Type][]domain.Domain{
+						ipnet.IP4: {domain.FQDN("a.b.c")},
+						ipnet.IP6: nil,
+					},
+					Proxied: map[domain.Domain]bool{
+						domain.FQDN("a.b.c"): false,
+					},
+				},
+			},
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP%d_PROVIDER was changed to %q because no domains or WAF lists use %s", 6, "none", "IPv6"),
+				)
+			},
+		},
+		"nat-probe/tailscale": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				DetectNAT:        true,
+				UpdateOnStart:    true,
+				DetectionTimeout: 5 * time.Second,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: provider.NewTailscale(),
+					ipnet.IP6: provider.NewTailscale(),
+				},
+				IP4Domains:        []domain.Domain{domain.FQDN("a.b.c")},
+				ProxiedExpression: "false",
+			},
+			ok: true,
+			expected: &builtConfig{
+				handle: &config.HandleConfig{ //nolint:exhaustruct
+					Options: api.HandleOptions{}, //nolint:exhaustruct
+				},
+				lifecycle: &config.LifecycleConfig{ //nolint:exhaustruct
+					UpdateOnStart: true,
+				},
+				update: &config.UpdateConfig{ //nolint:exhaustruct
+					DetectionTimeout: 5 * time.Second,
+					Provider: map[ipnet.Type]provider.Provider{
+						ipnet.IP4: provider.NewTailscale(),
+					},
 					Domains: map[ipnet.Type][]domain.Domain{
 						ipnet.IP4: {domain.FQDN("a.b.c")},
 						ipnet.IP6: nil,
@@ -218,6 +356,48 @@ func TestBuildConfig(t *testing.T) {
 				)
 			},
 		},
+		"dry-run": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				Auth:          &api.CloudflareAuth{Token: "deadbeaf"}, //nolint:exhaustruct
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP6: provider.NewCloudflareTrace(),
+				},
+				IP6Domains:        []domain.Domain{domain.FQDN("a.b.c")},
+				ProxiedExpression: "false",
+				DryRun:            true,
+			},
+			ok: true,
+			expected: &builtConfig{
+				handle: &config.HandleConfig{ //nolint:exhaustruct
+					Auth:    api.DryRunAuth{Auth: &api.CloudflareAuth{Token: "deadbeaf"}}, //nolint:exhaustruct
+					Options: api.HandleOptions{},                                          //nolint:exhaustruct
+				},
+				lifecycle: &config.LifecycleConfig{ //nolint:exhaustruct
+					UpdateOnStart: true,
+				},
+				update: &config.UpdateConfig{ //nolint:exhaustruct
+					Provider: map[ipnet.Type]provider.Provider{
+						ipnet.IP6: provider.NewCloudflareTrace(),
+					},
+					Domains: map[ipnet.Type][]domain.Domain{
+						ipnet.IP4: nil,
+						ipnet.IP6: {domain.FQDN("a.b.c")},
+					},
+					Proxied: map[domain.Domain]bool{
+						domain.FQDN("a.b.c"): false,
+					},
+					DryRun: true,
+				},
+			},
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+				)
+			},
+		},
 		"dns6empty-ip4none": {
 			input: &config.RawConfig{ //nolint:exhaustruct
 				UpdateOnStart: true,
@@ -378,6 +558,156 @@ func TestBuildConfig(t *testing.T) {
 				)
 			},
 		},
+		"interface-ids": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				DetectNAT:     true,
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: provider.NewCloudflareTrace(),
+					ipnet.IP6: provider.NewCloudflareTrace(),
+				},
+				IP4Domains: []domain.Domain{domain.FQDN("d.e.f")},
+				IP6Domains: []domain.Domain{domain.FQDN("a.b.c")},
+				InterfaceIDs: map[domain.Domain]netip.Prefix{
+					domain.FQDN("a.b.c"): netip.MustParsePrefix("::1/64"),
+					domain.FQDN("d.e.f"): netip.MustParsePrefix("::2/64"),
+					domain.FQDN("g.h.i"): netip.MustParsePrefix("::3/64"),
+				},
+				ProxiedExpression: "false",
+			},
+			ok: true,
+			expected: &builtConfig{
+				handle: &config.HandleConfig{ //nolint:exhaustruct
+					Options: api.HandleOptions{}, //nolint:exhaustruct
+				},
+				lifecycle: &config.LifecycleConfig{ //nolint:exhaustruct
+					UpdateOnStart: true,
+				},
+				update: &config.UpdateConfig{ //nolint:exhaustruct
+					Provider: map[ipnet.Type]provider.Provider{
+						ipnet.IP4: provider.NewCloudflareTrace(),
+						ipnet.IP6: provider.NewCloudflareTrace(),
+					},
+					NATProbe: provider.NewLocal(),
+					Domains: map[ipnet.Type][]domain.Domain{
+						ipnet.IP4: {domain.FQDN("d.e.f")},
+						ipnet.IP6: {domain.FQDN("a.b.c")},
+					},
+					InterfaceIDs: map[domain.Domain]netip.Prefix{
+						domain.FQDN("a.b.c"): netip.MustParsePrefix("::1/64"),
+					},
+					Proxied: map[domain.Domain]bool{
+						domain.FQDN("a.b.c"): false,
+						domain.FQDN("d.e.f"): false,
+					},
+				},
+			},
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP6_INTERFACE_IDS contains %q, which is ignored because its IPv6 addresses are not updated", "d.e.f"),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP6_INTERFACE_IDS contains %q, which is ignored because its IPv6 addresses are not updated", "g.h.i"),
+				)
+			},
+		},
+		"ranges": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				DetectNAT:     true,
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: provider.NewCloudflareTrace(),
+				},
+				IP4Domains: []domain.Domain{domain.FQDN("a.b.c")},
+				AllowedRanges: map[ipnet.Type][]netip.Prefix{
+					ipnet.IP6: {netip.MustParsePrefix("2001:db8::/32")},
+				},
+				DeniedRanges: map[ipnet.Type][]netip.Prefix{
+					ipnet.IP4: {netip.MustParsePrefix("100.64.0.0/10")},
+					ipnet.IP6: {netip.MustParsePrefix("fd00::/8")},
+				},
+				ProxiedExpression: "false",
+			},
+			ok: true,
+			expected: &builtConfig{
+				handle: &config.HandleConfig{ //nolint:exhaustruct
+					Options: api.HandleOptions{}, //nolint:exhaustruct
+				},
+				lifecycle: &config.LifecycleConfig{ //nolint:exhaustruct
+					UpdateOnStart: true,
+				},
+				update: &config.UpdateConfig{ //nolint:exhaustruct
+					Provider: map[ipnet.Type]provider.Provider{
+						ipnet.IP4: provider.NewCloudflareTrace(),
+					},
+					NATProbe: provider.NewLocal(),
+					Domains: map[ipnet.Type][]domain.Domain{
+						ipnet.IP4: {domain.FQDN("a.b.c")},
+						ipnet.IP6: nil,
+					},
+					DeniedRanges: map[ipnet.Type][]netip.Prefix{
+						ipnet.IP4: {netip.MustParsePrefix("100.64.0.0/10")},
+					},
+					Proxied: map[domain.Domain]bool{
+						domain.FQDN("a.b.c"): false,
+					},
+				},
+			},
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP%d_%s is ignored because %s is disabled", 6, "ALLOWED_RANGES", "IPv6"),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP%d_%s is ignored because %s is disabled", 6, "DENIED_RANGES", "IPv6"),
+				)
+			},
+		},
+		"interface-ids/ip6-disabled": {
+			input: &config.RawConfig{ //nolint:exhaustruct
+				DetectNAT:     true,
+				UpdateOnStart: true,
+				Provider: map[ipnet.Type]provider.Provider{
+					ipnet.IP4: provider.NewCloudflareTrace(),
+				},
+				IP4Domains: []domain.Domain{domain.FQDN("a.b.c")},
+				InterfaceIDs: map[domain.Domain]netip.Prefix{
+					domain.FQDN("a.b.c"): netip.MustParsePrefix("::1/64"),
+				},
+				ProxiedExpression: "false",
+			},
+			ok: true,
+			expected: &builtConfig{
+				handle: &config.HandleConfig{ //nolint:exhaustruct
+					Options: api.HandleOptions{}, //nolint:exhaustruct
+				},
+				lifecycle: &config.LifecycleConfig{ //nolint:exhaustruct
+					UpdateOnStart: true,
+				},
+				update: &config.UpdateConfig{ //nolint:exhaustruct
+					Provider: map[ipnet.Type]provider.Provider{
+						ipnet.IP4: provider.NewCloudflareTrace(),
+					},
+					NATProbe: provider.NewLocal(),
+					Domains: map[ipnet.Type][]domain.Domain{
+						ipnet.IP4: {domain.FQDN("a.b.c")},
+						ipnet.IP6: nil,
+					},
+					Proxied: map[domain.Domain]bool{
+						domain.FQDN("a.b.c"): false,
+					},
+				},
+			},
+			prepareMockPP: func(m *mocks.MockPP) {
+				gomock.InOrder(
+					m.EXPECT().IsShowing(pp.Info).Return(true),
+					m.EXPECT().Infof(pp.EmojiEnvVars, "Checking settings . . ."),
+					m.EXPECT().Indent().Return(m),
+					m.EXPECT().Noticef(pp.EmojiUserWarning, "IP6_INTERFACE_IDS is ignored because IPv6 is disabled"),
+				)
+			},
+		},
 		"managed-record-regex/invalid": {
 			input: &config.RawConfig{ //nolint:exhaustruct
 				UpdateOnStart: true,
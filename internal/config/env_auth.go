@@ -2,6 +2,7 @@ package config
 
 import (
 	"regexp"
+	"strings"
 
 	"github.com/favonia/cloudflare-ddns/internal/api"
 	"github.com/favonia/cloudflare-ddns/internal/file"
@@ -16,6 +17,7 @@ const (
 	TokenKey2     string = "CF_API_TOKEN"
 	TokenFileKey1 string = "CLOUDFLARE_API_TOKEN_FILE"
 	TokenFileKey2 string = "CF_API_TOKEN_FILE"
+	BackendKey    string = "API_BACKEND"
 )
 
 // HintAuthTokenNewPrefix contains the hint about the transition from
@@ -130,9 +132,9 @@ func readAuthToken(ppfmt pp.PP) (string, bool) {
 	return token, true
 }
 
-// ReadAuth reads environment variables CLOUDFLARE_API_TOKEN, CLOUDFLARE_API_TOKEN_FILE,
+// readCloudflareAuth reads environment variables CLOUDFLARE_API_TOKEN, CLOUDFLARE_API_TOKEN_FILE,
 // CF_API_TOKEN, CF_API_TOKEN_FILE, and CF_ACCOUNT_ID and creates an [api.CloudflareAuth].
-func ReadAuth(ppfmt pp.PP, field *api.Auth) bool {
+func readCloudflareAuth(ppfmt pp.PP, field *api.Auth) bool {
 	token, ok := readAuthToken(ppfmt)
 	if !ok {
 		return false
@@ -145,3 +147,20 @@ func ReadAuth(ppfmt pp.PP, field *api.Auth) bool {
 	*field = &api.CloudflareAuth{Token: token, BaseURL: ""}
 	return true
 }
+
+// ReadAuth reads the environment variable API_BACKEND and then the settings of the backend.
+// The default backend is Cloudflare; see [readCloudflareAuth], [readRFC2136Auth], and [readPowerDNSAuth].
+func ReadAuth(ppfmt pp.PP, field *api.Auth) bool {
+	switch backend := strings.ToLower(Getenv(BackendKey)); backend {
+	case "", "cloudflare":
+		return readCloudflareAuth(ppfmt, field)
+	case "rfc2136":
+		return readRFC2136Auth(ppfmt, field)
+	case "powerdns":
+		return readPowerDNSAuth(ppfmt, field)
+	default:
+		ppfmt.Noticef(pp.EmojiUserError, `%s (%q) is not a supported backend; it must be "cloudflare", "rfc2136", or "powerdns"`,
+			BackendKey, Getenv(BackendKey))
+		return false
+	}
+}
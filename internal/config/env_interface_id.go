@@ -0,0 +1,67 @@
+package config
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// parseInterfaceID parses an interface identifier such as "::1234:5678/64", where the
+// prefix length is the number of leading bits taken from the detected IPv6 address.
+func parseInterfaceID(ppfmt pp.PP, key, raw string) (netip.Prefix, bool) {
+	id, err := netip.ParsePrefix(raw)
+	if err != nil || !id.Addr().Is6() || id.Addr().Is4In6() {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`%s contains an invalid interface identifier %q; it should look like "::1234:5678/64"`, key, raw)
+		return netip.Prefix{}, false
+	}
+
+	if id.Masked().Addr() != netip.IPv6Unspecified() {
+		ppfmt.Noticef(pp.EmojiUserError,
+			"The interface identifier %q in %s has nonzero bits within the first %d bits", raw, key, id.Bits())
+		return netip.Prefix{}, false
+	}
+
+	return id, true
+}
+
+// ReadInterfaceIDs reads an environment variable as a comma-separated list of
+// "<domain>=<interface identifier>". The IPv6 addresses of each domain will be
+// the detected prefixes combined with the interface identifier.
+func ReadInterfaceIDs(ppfmt pp.PP, key string, field *map[domain.Domain]netip.Prefix) bool {
+	vals := GetenvAsList(key, ",")
+	if len(vals) == 0 {
+		return true
+	}
+
+	ids := make(map[domain.Domain]netip.Prefix, len(vals))
+	for _, val := range vals {
+		rawDomain, rawID, found := strings.Cut(val, "=")
+		if !found {
+			ppfmt.Noticef(pp.EmojiUserError, `%s contains %q, which is not of the form "<domain>=<interface identifier>"`,
+				key, val)
+			return false
+		}
+
+		d, err := domain.New(strings.TrimSpace(rawDomain))
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiUserError, "%s contains an ill-formed domain %q: %v", key, d.Describe(), err)
+			return false
+		}
+		if _, found := ids[d]; found {
+			ppfmt.Noticef(pp.EmojiUserError, "%s contains the domain %q more than once", key, d.Describe())
+			return false
+		}
+
+		id, ok := parseInterfaceID(ppfmt, key, strings.TrimSpace(rawID))
+		if !ok {
+			return false
+		}
+		ids[d] = id
+	}
+
+	*field = ids
+	return true
+}
@@ -0,0 +1,92 @@
+package config_test
+
+// vim: nowrap
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+//nolint:paralleltest // paralleltest should not be used because environment vars are global
+func TestReadInterfaceIDs(t *testing.T) {
+	key := keyPrefix + "IP6_INTERFACE_IDS"
+
+	type ids = map[domain.Domain]netip.Prefix
+
+	for name, tc := range map[string]struct {
+		set           bool
+		val           string
+		oldField      ids
+		newField      ids
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"unset": {false, "", ids{domain.FQDN("a.b.c"): netip.MustParsePrefix("::1/64")}, ids{domain.FQDN("a.b.c"): netip.MustParsePrefix("::1/64")}, true, nil},
+		"empty": {true, " ", nil, nil, true, nil},
+		"two": {
+			true, " a.b.c = ::1234:5678/64 , *.d.e.f=::ab:0:0:1/56 ", nil,
+			ids{
+				domain.FQDN("a.b.c"):     netip.MustParsePrefix("::1234:5678/64"),
+				domain.Wildcard("d.e.f"): netip.MustParsePrefix("::ab:0:0:1/56"),
+			},
+			true, nil,
+		},
+		"no-equal-sign": {
+			true, "a.b.c", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s contains %q, which is not of the form "<domain>=<interface identifier>"`, key, "a.b.c")
+			},
+		},
+		"ill-formed-domain": {
+			true, "localhost=::1/64", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains an ill-formed domain %q: %v", key, "localhost", domain.ErrNotFQDN)
+			},
+		},
+		"duplicate": {
+			true, "a.b.c=::1/64,a.b.c=::2/64", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains the domain %q more than once", key, "a.b.c")
+			},
+		},
+		"no-length": {
+			true, "a.b.c=::1", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s contains an invalid interface identifier %q; it should look like "::1234:5678/64"`, key, "::1")
+			},
+		},
+		"ip4": {
+			true, "a.b.c=0.0.0.1/24", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s contains an invalid interface identifier %q; it should look like "::1234:5678/64"`, key, "0.0.0.1/24")
+			},
+		},
+		"prefix-bits": {
+			true, "a.b.c=2001:db8::1/64", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "The interface identifier %q in %s has nonzero bits within the first %d bits", "2001:db8::1/64", key, 64)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			set(t, key, tc.set, tc.val)
+			field := tc.oldField
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ok := config.ReadInterfaceIDs(mockPP, key, &field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.newField, field)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"net/netip"
+	"net/url"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// Keys of environment variables for PowerDNS.
+const (
+	PowerDNSAPIURLKey     string = "POWERDNS_API_URL"
+	PowerDNSAPIKeyKey     string = "POWERDNS_API_KEY"
+	PowerDNSAPIKeyFileKey string = "POWERDNS_API_KEY_FILE"
+	PowerDNSServerIDKey   string = "POWERDNS_SERVER_ID"
+)
+
+// isLoopbackHost checks whether a host is "localhost" or a loopback address.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// readPowerDNSAPIKey reads the API key from POWERDNS_API_KEY or POWERDNS_API_KEY_FILE.
+func readPowerDNSAPIKey(ppfmt pp.PP) (string, bool) {
+	key := Getenv(PowerDNSAPIKeyKey)
+	if path := Getenv(PowerDNSAPIKeyFileKey); path != "" {
+		if key != "" {
+			ppfmt.Noticef(pp.EmojiUserError, "Cannot have both %s and %s set", PowerDNSAPIKeyKey, PowerDNSAPIKeyFileKey)
+			return "", false
+		}
+
+		var ok bool
+		if key, ok = file.ReadString(ppfmt, path); !ok {
+			return "", false
+		}
+		if key == "" {
+			ppfmt.Noticef(pp.EmojiUserError, "The file specified by %s does not contain an API key", PowerDNSAPIKeyFileKey)
+			return "", false
+		}
+	}
+
+	if key == "" {
+		ppfmt.Noticef(pp.EmojiUserError, "Needs either %s or %s", PowerDNSAPIKeyKey, PowerDNSAPIKeyFileKey)
+		return "", false
+	}
+
+	return key, true
+}
+
+// readPowerDNSAuth reads environment variables POWERDNS_API_URL, POWERDNS_API_KEY,
+// POWERDNS_API_KEY_FILE, and POWERDNS_SERVER_ID and creates an [api.PowerDNSAuth].
+func readPowerDNSAuth(ppfmt pp.PP, field *api.Auth) bool {
+	rawURL := Getenv(PowerDNSAPIURLKey)
+	if rawURL == "" {
+		ppfmt.Noticef(pp.EmojiUserError, "%s=powerdns needs %s", BackendKey, PowerDNSAPIURLKey)
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Opaque != "" ||
+		(u.Scheme != "http" && u.Scheme != "https") {
+		ppfmt.Noticef(pp.EmojiUserError, "%s (%q) does not look like a valid URL", PowerDNSAPIURLKey, rawURL)
+		return false
+	}
+	if u.Scheme == "http" && !isLoopbackHost(u.Hostname()) {
+		ppfmt.Noticef(pp.EmojiUserWarning,
+			"%s (%q) uses HTTP, and the API key will be sent unencrypted; please consider using HTTPS",
+			PowerDNSAPIURLKey, rawURL)
+	}
+
+	key, ok := readPowerDNSAPIKey(ppfmt)
+	if !ok {
+		return false
+	}
+
+	serverID := Getenv(PowerDNSServerIDKey)
+	if serverID == "" {
+		serverID = api.PowerDNSDefaultServerID
+	}
+
+	*field = &api.PowerDNSAuth{BaseURL: rawURL, APIKey: key, ServerID: serverID}
+	return true
+}
@@ -0,0 +1,134 @@
+package config_test
+
+// vim: nowrap
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+//nolint:paralleltest // environment vars and file system are global
+func TestReadAuthPowerDNS(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mapFS         map[string]string
+		url           string
+		key           string
+		keyFile       string
+		serverID      string
+		ok            bool
+		expected      api.Auth
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"success": {
+			nil, "http://127.0.0.1:8081", "secret", "", "",
+			true, &api.PowerDNSAuth{BaseURL: "http://127.0.0.1:8081", APIKey: "secret", ServerID: "localhost"},
+			nil,
+		},
+		"success/https": {
+			nil, "https://pdns.example.org/", "secret", "", "primary",
+			true, &api.PowerDNSAuth{BaseURL: "https://pdns.example.org/", APIKey: "secret", ServerID: "primary"},
+			nil,
+		},
+		"success/file": {
+			map[string]string{"key.txt": "  secret\n"}, "http://localhost:8081", "", "key.txt", "",
+			true, &api.PowerDNSAuth{BaseURL: "http://localhost:8081", APIKey: "secret", ServerID: "localhost"},
+			nil,
+		},
+		"http": {
+			nil, "http://pdns.example.org:8081", "secret", "", "",
+			true, &api.PowerDNSAuth{BaseURL: "http://pdns.example.org:8081", APIKey: "secret", ServerID: "localhost"},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserWarning, "%s (%q) uses HTTP, and the API key will be sent unencrypted; please consider using HTTPS", "POWERDNS_API_URL", "http://pdns.example.org:8081")
+			},
+		},
+		"no-url": {
+			nil, "", "secret", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s=powerdns needs %s", "API_BACKEND", "POWERDNS_API_URL")
+			},
+		},
+		"invalid-url": {
+			nil, "127.0.0.1:8081", "secret", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s (%q) does not look like a valid URL", "POWERDNS_API_URL", "127.0.0.1:8081")
+			},
+		},
+		"invalid-scheme": {
+			nil, "ftp://127.0.0.1", "secret", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s (%q) does not look like a valid URL", "POWERDNS_API_URL", "ftp://127.0.0.1")
+			},
+		},
+		"no-key": {
+			nil, "http://127.0.0.1:8081", "", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Needs either %s or %s", "POWERDNS_API_KEY", "POWERDNS_API_KEY_FILE")
+			},
+		},
+		"both-keys": {
+			map[string]string{"key.txt": "secret"}, "http://127.0.0.1:8081", "secret", "key.txt", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Cannot have both %s and %s set", "POWERDNS_API_KEY", "POWERDNS_API_KEY_FILE")
+			},
+		},
+		"key-file/empty": {
+			map[string]string{"key.txt": ""}, "http://127.0.0.1:8081", "", "key.txt", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "The file specified by %s does not contain an API key", "POWERDNS_API_KEY_FILE")
+			},
+		},
+		"key-file/wrong-path": {
+			nil, "http://127.0.0.1:8081", "", "wrong.txt", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to read %q: %v", "wrong.txt", gomock.Any())
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			unset(t, "CLOUDFLARE_API_TOKEN", "CLOUDFLARE_API_TOKEN_FILE", "CF_API_TOKEN", "CF_API_TOKEN_FILE", "CF_ACCOUNT_ID")
+			store(t, "API_BACKEND", "powerdns")
+			store(t, "POWERDNS_API_URL", tc.url)
+			store(t, "POWERDNS_API_KEY", tc.key)
+			store(t, "POWERDNS_API_KEY_FILE", tc.keyFile)
+			store(t, "POWERDNS_SERVER_ID", tc.serverID)
+
+			mapFS := fstest.MapFS{}
+			for path, content := range tc.mapFS {
+				mapFS[path] = &fstest.MapFile{
+					Data:    []byte(content),
+					Mode:    0o644,
+					ModTime: time.Unix(1234, 5678),
+					Sys:     nil,
+				}
+			}
+			useMemFS(mapFS)
+
+			var field api.Auth
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ok := config.ReadAuth(mockPP, &field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, field)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/favonia/cloudflare-ddns/internal/ipnet"
@@ -83,11 +85,249 @@ func ReadProvider(ppfmt pp.PP, key, keyDeprecated string, field *provider.Provid
 		return false
 	}
 
+	return parseProvider(ppfmt, key, val, field)
+}
+
+// providerKeywords are the names that can start a provider. They are used to tell
+// the commas separating the members of a composite provider from the commas
+// within a member, such as the ones in "literal:1.1.1.1,2.2.2.2".
+//
+//nolint:gochecknoglobals
+var providerKeywords = []string{
+	"cloudflare", "cloudflare.trace", "cloudflare.doh", "ipify", "local", "local.iface",
+	"url", "stun", "natpmp", "dns", "doh", "exec", "file", "json", "regex", "regex-all", "literal", "none",
+	"fallback", "quorum", "union", "cloud.aws", "cloud.gcp", "cloud.azure", "cloud.openstack",
+	"k8s.service", "k8s.node", "tailscale", "nat64",
+}
+
+// httpProviderKeywords are the providers accepting HTTP options, as in "url(<options>):<url>".
+//
+//nolint:gochecknoglobals
+var httpProviderKeywords = []string{"url", "json", "regex", "regex-all"}
+
+// cutHTTPOptions separates the options from a provider such as "url(<options>):<url>".
+// The options end at the first closing parenthesis followed by a colon.
+func cutHTTPOptions(val string) (string, string, bool) {
+	mode, rest, found := strings.Cut(val, "(")
+	if !found || strings.Contains(mode, ":") {
+		return val, "", false
+	}
+
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != ')' {
+			continue
+		}
+		if after, isOptions := strings.CutPrefix(strings.TrimLeft(rest[i+1:], " \t"), ":"); isOptions {
+			return strings.TrimSpace(mode) + ":" + after, rest[:i], true
+		}
+	}
+
+	return val, "", false
+}
+
+// cutBinding separates the binding from a provider such as "cloudflare.trace@eth1" or
+// "url@[2001:db8::1]:<url>". The binding follows the provider keyword and ends before
+// the options or the colon; an IPv6 address must be enclosed in brackets.
+func cutBinding(val string) (string, string, bool) {
+	keyword, rest, found := strings.Cut(val, "@")
+	if !found || !slices.Contains(providerKeywords, strings.TrimSpace(keyword)) {
+		return val, "", false
+	}
+
+	end := strings.IndexAny(rest, ":(")
+	if strings.HasPrefix(strings.TrimSpace(rest), "[") {
+		end = strings.Index(rest, "]") + 1
+		if end == 0 {
+			end = len(rest)
+		}
+	}
+	if end < 0 {
+		end = len(rest)
+	}
+	return strings.TrimSpace(keyword) + rest[end:], rest[:end], true
+}
+
+func startsProvider(raw string) bool {
+	keyword, _, _ := strings.Cut(raw, ":")
+	keyword, _, _ = strings.Cut(keyword, "(")
+	keyword, _, _ = strings.Cut(keyword, "@")
+	return slices.Contains(providerKeywords, strings.TrimSpace(keyword))
+}
+
+// splitProviderList splits the members of a composite provider at the commas
+// outside parentheses. Double-quoted option values and characters escaped by
+// a backslash, such as "\(" in a regular expression, are skipped. A piece that
+// does not start with a provider keyword is joined back to the previous member.
+func splitProviderList(raw string) ([]string, bool) {
+	var (
+		pieces  []string
+		depth   int
+		start   int
+		quoted  bool
+		escaped bool
+	)
+	for i, c := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			// Parentheses and commas in double-quoted values do not count.
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case c == ',' && depth == 0:
+			pieces = append(pieces, raw[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, false
+	}
+	pieces = append(pieces, raw[start:])
+
+	members := []string{pieces[0]}
+	for _, piece := range pieces[1:] {
+		if startsProvider(piece) {
+			members = append(members, piece)
+		} else {
+			members[len(members)-1] += "," + piece
+		}
+	}
+	for i := range members {
+		members[i] = strings.TrimSpace(members[i])
+	}
+	return members, true
+}
+
+// parseCompositeProvider parses the arguments of fallback(...), quorum(...), and union(...).
+func parseCompositeProvider(ppfmt pp.PP, key, mode, rawArgs string, field *provider.Provider) bool {
+	args, ok := splitProviderList(rawArgs)
+	if !ok {
+		ppfmt.Noticef(pp.EmojiUserError, `%s=%s(...) has unbalanced parentheses or double quotes`, key, mode)
+		return false
+	}
+
+	threshold := 0
+	if mode == "quorum" {
+		var err error
+		threshold, err = strconv.Atoi(args[0])
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiUserError,
+				`%s=quorum(...) must start with the number of agreeing providers, but got %q`, key, args[0])
+			return false
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 || (len(args) == 1 && args[0] == "") {
+		ppfmt.Noticef(pp.EmojiUserError, `%s=%s(...) must contain at least one provider`, key, mode)
+		return false
+	}
+
+	members := make([]provider.Provider, 0, len(args))
+	for _, arg := range args {
+		var member provider.Provider
+		if !parseProvider(ppfmt, key, arg, &member) {
+			return false
+		}
+		if member == nil {
+			ppfmt.Noticef(pp.EmojiUserError, `%s=%s(...) cannot contain the provider "none"`, key, mode)
+			return false
+		}
+		members = append(members, member)
+	}
+
+	switch mode {
+	case "fallback":
+		*field = provider.NewFallback(members...)
+	case "quorum":
+		p, ok := provider.NewQuorum(ppfmt, threshold, members...)
+		if !ok {
+			return false
+		}
+		*field = p
+	case "union":
+		*field = provider.NewUnion(members...)
+	}
+	return true
+}
+
+// checkIP4Only rejects a provider that only works for IPv4 in IP6_PROVIDER.
+func checkIP4Only(ppfmt pp.PP, key, name string) bool {
+	if key == "IP6_PROVIDER" {
+		ppfmt.Noticef(pp.EmojiUserError, `%s=%s is invalid because the provider only works for IPv4`, key, name)
+		return false
+	}
+	return true
+}
+
+// parseProvider parses a non-empty provider specification.
+func parseProvider(ppfmt pp.PP, key, val string, field *provider.Provider) bool {
+	if mode, rawArgs, found := strings.Cut(val, "("); found && strings.HasSuffix(rawArgs, ")") {
+		switch mode = strings.TrimSpace(mode); mode {
+		case "fallback", "quorum", "union":
+			return parseCompositeProvider(ppfmt, key, mode, strings.TrimSuffix(rawArgs, ")"), field)
+		}
+	}
+
+	if val, rawBinding, hasBinding := cutBinding(val); hasBinding {
+		binding, ok := provider.ParseBinding(ppfmt, rawBinding)
+		if !ok {
+			return false
+		}
+		var p provider.Provider
+		if !parseProvider(ppfmt, key, val, &p) {
+			return false
+		}
+		if p == nil {
+			ppfmt.Noticef(pp.EmojiUserError, `%s=none cannot be bound to a network interface or a source address`, key)
+			return false
+		}
+		if p, ok = provider.NewBound(ppfmt, p, binding); !ok {
+			return false
+		}
+		*field = p
+		return true
+	}
+
+	val, rawOptions, hasOptions := cutHTTPOptions(val)
+
 	parts := strings.SplitN(val, ":", 2) // len(parts) >= 1 because val is not empty
 	for i := range parts {
 		parts[i] = strings.TrimSpace(parts[i])
 	}
 
+	var options provider.HTTPOptions
+	var policy provider.InterfacePolicy
+	if hasOptions {
+		var ok bool
+		switch {
+		case len(parts) == 2 && parts[0] == "local.iface":
+			if policy, ok = provider.ParseInterfacePolicy(ppfmt, rawOptions); !ok {
+				return false
+			}
+		case len(parts) == 2 && slices.Contains(httpProviderKeywords, parts[0]):
+			if options, ok = provider.ParseHTTPOptions(ppfmt, parts[0], rawOptions); !ok {
+				return false
+			}
+		default:
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=%s(...) is invalid; only url:, json:, regex:, regex-all:, and local.iface: accept options`,
+				key, parts[0],
+			)
+			return false
+		}
+	}
+
 	switch {
 	case len(parts) == 1 && parts[0] == "cloudflare":
 		ppfmt.Noticef(
@@ -126,6 +366,21 @@ func ReadProvider(ppfmt pp.PP, key, keyDeprecated string, field *provider.Provid
 	case len(parts) == 1 && parts[0] == "local":
 		*field = provider.NewLocal()
 		return true
+	case len(parts) == 1 && parts[0] == "cloud.aws":
+		*field = provider.NewCloudAWS()
+		return true
+	case len(parts) == 1 && parts[0] == "cloud.gcp":
+		*field = provider.NewCloudGCP()
+		return true
+	case len(parts) == 1 && parts[0] == "cloud.azure":
+		if !checkIP4Only(ppfmt, key, "cloud.azure") {
+			return false
+		}
+		*field = provider.NewCloudAzure()
+		return true
+	case len(parts) == 1 && parts[0] == "cloud.openstack":
+		*field = provider.NewCloudOpenStack()
+		return true
 	case len(parts) == 2 && parts[0] == "local.iface":
 		if parts[1] == "" {
 			ppfmt.Noticef(
@@ -137,10 +392,196 @@ func ReadProvider(ppfmt pp.PP, key, keyDeprecated string, field *provider.Provid
 		}
 		ppfmt.InfoOncef(pp.MessageExperimentalLocalWithInterface, pp.EmojiHint,
 			`You are using the experimental "local.iface" provider added in version 1.15.0`)
-		*field = provider.NewLocalWithInterface(parts[1])
+		*field = provider.NewLocalWithInterfacePolicy(parts[1], policy)
 		return true
+	case len(parts) == 1 && parts[0] == "tailscale":
+		*field = provider.NewTailscale()
+		return true
+	case len(parts) == 2 && parts[0] == "tailscale":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=tailscale: must be followed by the path of the unix socket of tailscaled`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewTailscaleWithSocket(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "k8s.service":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=k8s.service: must be followed by a namespace and a service name, as in "k8s.service:<namespace>/<name>"`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewKubernetesService(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "k8s.node":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=k8s.node: must be followed by a node name`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewKubernetesNode(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
 	case len(parts) == 2 && parts[0] == "url":
-		p, ok := provider.NewCustomURL(ppfmt, parts[1])
+		p, ok := provider.NewCustomURLWithOptions(ppfmt, options, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "stun":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=stun: must be followed by a STUN server`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewSTUN(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 1 && parts[0] == "natpmp":
+		if !checkIP4Only(ppfmt, key, "natpmp") {
+			return false
+		}
+		*field = provider.NewNATPMP()
+		return true
+	case len(parts) == 2 && parts[0] == "natpmp":
+		if !checkIP4Only(ppfmt, key, "natpmp:") {
+			return false
+		}
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=natpmp: must be followed by a gateway address`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewNATPMPWithGateway(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 1 && parts[0] == "nat64":
+		if !checkIP4Only(ppfmt, key, "nat64") {
+			return false
+		}
+		*field = provider.NewNAT64()
+		return true
+	case len(parts) == 2 && parts[0] == "dns":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=dns: must be followed by a DNS server, a domain name, and a record type`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewDNS(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "doh":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=doh: must be followed by a URL, a domain name, and a record type`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewDOH(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "exec":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=exec: must be followed by a command`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewExec(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "file":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=file: must be followed by the path of a file`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewFile(ppfmt, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "json":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=json: must be followed by a URL and a path`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewJSONWithOptions(ppfmt, options, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "regex":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=regex: must be followed by a URL and a regular expression`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewRegexWithOptions(ppfmt, options, parts[1])
+		if ok {
+			*field = p
+		}
+		return ok
+	case len(parts) == 2 && parts[0] == "regex-all":
+		if parts[1] == "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`%s=regex-all: must be followed by a URL and a regular expression`,
+				key,
+			)
+			return false
+		}
+		p, ok := provider.NewRegexAllWithOptions(ppfmt, options, parts[1])
 		if ok {
 			*field = p
 		}
@@ -31,6 +31,17 @@ func TestReadProvider(t *testing.T) {
 		custom        = provider.MustNewCustomURL("https://url.io")
 		literal       = provider.MustNewLiteral("1.1.1.1")
 		literalMulti  = provider.MustNewLiteral("2.2.2.2,1.1.1.1,2.2.2.2")
+		stun          = provider.MustNewSTUN("stun.example.net:3478")
+		natpmp        = provider.NewNATPMP()
+		natpmpGateway = provider.MustNewNATPMPWithGateway("192.168.1.1")
+		nat64         = provider.NewNAT64()
+		dns           = provider.MustNewDNS("resolver1.opendns.com,myip.opendns.com,A")
+		dohCustom     = provider.MustNewDOH("https://dns.internal/dns-query,myip.internal,AAAA")
+		execWANIP     = provider.MustNewExec("/usr/local/bin/wan-ip --family auto")
+		fileWAN4      = provider.MustNewFile("/run/wan4")
+		jsonIP        = provider.MustNewJSON("https://ifconfig.co/json#ip")
+		regexWAN      = provider.MustNewRegex(`https://router.lan/status,WAN: (\S+)`)
+		regexAllWAN   = provider.MustNewRegexAll(`https://router.lan/status,addr=(\S+)`)
 	)
 
 	for name, tc := range map[string]struct {
@@ -140,13 +151,239 @@ func TestReadProvider(t *testing.T) {
 				m.EXPECT().InfoOncef(pp.MessageExperimentalLocalWithInterface, pp.EmojiHint, `You are using the experimental "local.iface" provider added in version 1.15.0`)
 			},
 		},
+		"local.iface/policy": {
+			true, " local.iface( stable , max=1 ):lo", false, "", trace,
+			provider.NewLocalWithInterfacePolicy("lo", provider.InterfacePolicy{StableOnly: true, LongestLifetime: false, MaxAddresses: 1}), true,
+			func(m *mocks.MockPP) {
+				m.EXPECT().InfoOncef(pp.MessageExperimentalLocalWithInterface, pp.EmojiHint, `You are using the experimental "local.iface" provider added in version 1.15.0`)
+			},
+		},
+		"local.iface/policy/invalid": {
+			true, "local.iface(max=0):lo", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The option "max" for "local.iface:" must be a positive integer, but got %q`, "0")
+			},
+		},
 		"local.iface:": {
 			true, "   local.iface: ", false, "", trace, trace, false,
 			func(m *mocks.MockPP) {
 				m.EXPECT().Noticef(pp.EmojiUserError, `%s=local.iface: must be followed by a network interface name`, key)
 			},
 		},
-		"custom": {true, "   url:https://url.io   ", false, "", trace, custom, true, nil},
+		"custom":                     {true, "   url:https://url.io   ", false, "", trace, custom, true, nil},
+		"stun:stun.example.net:3478": {true, "   stun   :  stun.example.net:3478 ", false, "", trace, stun, true, nil},
+		"stun:stun.example.net":      {true, "stun:stun.example.net", false, "", trace, stun, true, nil},
+		"stun:": {
+			true, "   stun: ", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=stun: must be followed by a STUN server`, key)
+			},
+		},
+		"stun:stun.example.net:0": {
+			true, "stun:stun.example.net:0", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the port %q for "%s:"`, "0", "stun")
+			},
+		},
+		"natpmp":             {true, "  natpmp ", false, "", trace, natpmp, true, nil},
+		"natpmp:192.168.1.1": {true, " natpmp : 192.168.1.1:5351 ", false, "", trace, natpmpGateway, true, nil},
+		"nat64":              {true, " nat64 ", false, "", trace, nat64, true, nil},
+		"natpmp:": {
+			true, "   natpmp: ", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=natpmp: must be followed by a gateway address`, key)
+			},
+		},
+		"dns": {true, " dns : resolver1.opendns.com, myip.opendns.com, A ", false, "", trace, dns, true, nil},
+		"dns:": {
+			true, "dns:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=dns: must be followed by a DNS server, a domain name, and a record type`, key)
+			},
+		},
+		"doh": {true, "doh: https://dns.internal/dns-query, myip.internal, AAAA", false, "", trace, dohCustom, true, nil},
+		"doh:": {
+			true, "doh:  ", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=doh: must be followed by a URL, a domain name, and a record type`, key)
+			},
+		},
+		"exec": {true, " exec : /usr/local/bin/wan-ip   --family auto ", false, "", trace, execWANIP, true, nil},
+		"exec:": {
+			true, "exec:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=exec: must be followed by a command`, key)
+			},
+		},
+		"file": {true, " file : /run/wan4 ", false, "", trace, fileWAN4, true, nil},
+		"file:": {
+			true, "file:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=file: must be followed by the path of a file`, key)
+			},
+		},
+		"file:relative": {
+			true, "file:wan4", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The path %q for "file:" is not absolute`, "wan4")
+			},
+		},
+		"json": {true, " json : https://ifconfig.co/json#ip ", false, "", trace, jsonIP, true, nil},
+		"json:": {
+			true, "json:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=json: must be followed by a URL and a path`, key)
+			},
+		},
+		"json:invalid-path": {
+			true, "json:https://ifconfig.co/json#.ip", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the JSON path %q for "json:"`, ".ip")
+			},
+		},
+		"regex": {true, ` regex : https://router.lan/status,WAN: (\S+)`, false, "", trace, regexWAN, true, nil},
+		"regex:": {
+			true, "regex:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=regex: must be followed by a URL and a regular expression`, key)
+			},
+		},
+		"regex-all": {true, `regex-all:https://router.lan/status,addr=(\S+)`, false, "", trace, regexAllWAN, true, nil},
+		"regex-all:": {
+			true, "regex-all:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=regex-all: must be followed by a URL and a regular expression`, key)
+			},
+		},
+		"regex-all:no-group": {
+			true, "regex-all:https://router.lan/status,addr=.*", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The regular expression %q for "%s:" must contain a capture group for the IP address`, "addr=.*", "regex-all")
+			},
+		},
+		"union/regex": {
+			true, `union(regex:https://router.lan/status,WAN: (\S+), regex-all:https://router.lan/status,addr=(\S+))`, false, "", none,
+			provider.NewUnion(regexWAN, regexAllWAN), true, nil,
+		},
+		"union/regex/escaped-parenthesis": {
+			true, `union(regex:https://router.lan/status,WAN\( (\S+), cloudflare.trace)`, false, "", none,
+			provider.NewUnion(provider.MustNewRegex(`https://router.lan/status,WAN\( (\S+)`), trace), true, nil,
+		},
+		"url/options": {
+			true, " url ( header=X-Token: secret ) : https://1.2.3.4 ", false, "", trace,
+			mustNewCustomURLWithOptions(t, "https://1.2.3.4", map[string]string{"X-Token": "secret"}), true, nil,
+		},
+		"json/options": {
+			true, "json(header=X-Token: secret):https://ifconfig.co/json#ip", false, "", trace,
+			mustNewJSONWithOptions(t, "https://ifconfig.co/json#ip", map[string]string{"X-Token": "secret"}), true, nil,
+		},
+		"url/options/invalid": {
+			true, "url(token=secret):https://1.2.3.4", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unknown option %q for "%s:"; use header, basic-auth-file, ca, client-cert, or client-key`, "token", "url")
+			},
+		},
+		"stun/options": {
+			true, "stun(header=X-Token: secret):stun.l.google.com:19302", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) is invalid; only url:, json:, regex:, regex-all:, and local.iface: accept options`, key, "stun")
+			},
+		},
+		"union/url/options": {
+			true, "union(url(header=X-Token: secret, header=X-User: me):https://1.2.3.4, cloudflare.trace)", false, "", none,
+			provider.NewUnion(mustNewCustomURLWithOptions(t, "https://1.2.3.4", map[string]string{"X-Token": "secret", "X-User": "me"}), trace), true, nil,
+		},
+		"union/url/options/quoted": {
+			true, `union(url(header="Accept: text/plain, */*"):https://1.2.3.4, cloudflare.trace)`, false, "", none,
+			provider.NewUnion(mustNewCustomURLWithOptions(t, "https://1.2.3.4", map[string]string{"Accept": "text/plain, */*"}), trace), true, nil,
+		},
+		"union/url/options/quoted-parenthesis": {
+			true, `union(url(header="X-Note: (a, b"):https://1.2.3.4, cloudflare.trace)`, false, "", none,
+			provider.NewUnion(mustNewCustomURLWithOptions(t, "https://1.2.3.4", map[string]string{"X-Note": "(a, b"}), trace), true, nil,
+		},
+		"fallback/dns": {
+			true, "fallback(dns:resolver1.opendns.com,myip.opendns.com,A, cloudflare.trace)", false, "", none,
+			provider.NewFallback(dns, trace), true, nil,
+		},
+		"cloud.aws": {
+			true, " cloud.aws ", false, "", none, provider.NewCloudAWS(), true, nil,
+		},
+		"cloud.gcp": {
+			true, "cloud.gcp", false, "", none, provider.NewCloudGCP(), true, nil,
+		},
+		"cloud.azure": {
+			true, "cloud.azure", false, "", none, provider.NewCloudAzure(), true, nil,
+		},
+		"fallback/cloud.openstack": {
+			true, "fallback(cloud.openstack, cloudflare.trace)", false, "", none,
+			provider.NewFallback(provider.NewCloudOpenStack(), trace), true, nil,
+		},
+		"tailscale": {
+			true, " tailscale ", false, "", none, provider.NewTailscale(), true, nil,
+		},
+		"tailscale:socket": {
+			true, "tailscale:/run/tailscale/tailscaled.sock", false, "", none,
+			provider.MustNewTailscaleWithSocket("/run/tailscale/tailscaled.sock"), true, nil,
+		},
+		"tailscale:": {
+			true, "tailscale:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=tailscale: must be followed by the path of the unix socket of tailscaled`, key)
+			},
+		},
+		"k8s.service": {
+			true, " k8s.service : metallb-system/ingress ", false, "", none,
+			provider.MustNewKubernetesService("metallb-system/ingress"), true, nil,
+		},
+		"k8s.service:": {
+			true, "k8s.service:", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=k8s.service: must be followed by a namespace and a service name, as in "k8s.service:<namespace>/<name>"`, key)
+			},
+		},
+		"k8s.node": {
+			true, "k8s.node:worker-1", false, "", none, provider.MustNewKubernetesNode("worker-1"), true, nil,
+		},
+		"k8s.node:": {
+			true, "k8s.node: ", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=k8s.node: must be followed by a node name`, key)
+			},
+		},
+		"cloudflare.trace@eth1": {
+			true, " cloudflare.trace @ eth1 ", false, "", none, mustNewBound(t, trace, "eth1"), true, nil,
+		},
+		"url@ipv6/options": {
+			true, "url@[2001:db8::1](header=X-Token: secret):https://1.2.3.4", false, "", none,
+			mustNewBound(t, mustNewCustomURLWithOptions(t, "https://1.2.3.4", map[string]string{"X-Token": "secret"}), "[2001:db8::1]"),
+			true, nil,
+		},
+		"dns@ipv4": {
+			true, "dns@192.0.2.1:resolver1.opendns.com,myip.opendns.com,A", false, "", none,
+			mustNewBound(t, dns, "192.0.2.1"), true, nil,
+		},
+		"fallback/bound": {
+			true, "fallback(cloudflare.trace@eth1, cloudflare.doh@eth2)", false, "", none,
+			provider.NewFallback(mustNewBound(t, trace, "eth1"), mustNewBound(t, doh, "eth2")), true, nil,
+		},
+		"local@eth1": {
+			true, "local@eth1", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s cannot be bound to a network interface or a source address; only the providers based on HTTP(S), DNS, or STUN can`, "local")
+			},
+		},
+		"none@eth1": {
+			true, "none@eth1", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=none cannot be bound to a network interface or a source address`, key)
+			},
+		},
+		"cloudflare.trace@invalid": {
+			true, "cloudflare.trace@[1.2.3.4]", false, "", trace, trace, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid IPv6 source address in "@[...]"`, "[1.2.3.4]")
+			},
+		},
 		"literal:1.1.1.1": {
 			true, "   literal   :  1.1.1.1 ", false, "", trace, literal, true,
 			nil,
@@ -160,8 +397,8 @@ func TestReadProvider(t *testing.T) {
 			func(m *mocks.MockPP) {
 				m.EXPECT().Noticef(
 					pp.EmojiUserError,
-					`Failed to parse the IP address %q for "literal:": zoned IP addresses are not allowed`,
-					"1::1%eth0",
+					`Failed to parse the IP address %q for "%s:": zoned IP addresses are not allowed`,
+					"1::1%eth0", "literal",
 				)
 			},
 		},
@@ -171,6 +408,70 @@ func TestReadProvider(t *testing.T) {
 				m.EXPECT().Noticef(pp.EmojiUserError, `%s=literal: must be followed by at least one IP address`, key)
 			},
 		},
+		"fallback": {
+			true, " fallback( cloudflare.trace , cloudflare.doh,literal:2.2.2.2, 1.1.1.1,2.2.2.2 ) ", false, "", none,
+			provider.NewFallback(trace, doh, literalMulti), true, nil,
+		},
+		"fallback/nested": {
+			true, "fallback(union(cloudflare.trace, literal:1.1.1.1), quorum(1, cloudflare.doh))", false, "", none,
+			provider.NewFallback(provider.NewUnion(trace, literal), provider.MustNewQuorum(1, doh)), true, nil,
+		},
+		"quorum": {
+			true, "quorum(2, cloudflare.trace, cloudflare.doh, url:https://url.io)", false, "", none,
+			provider.MustNewQuorum(2, trace, doh, custom), true, nil,
+		},
+		"quorum/threshold": {
+			true, "quorum(3, cloudflare.trace, cloudflare.doh)", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The number of agreeing providers for "quorum(...)" must be between 1 and %d, but got %d`, 2, 3)
+			},
+		},
+		"quorum/no-threshold": {
+			true, "quorum(cloudflare.trace, cloudflare.doh)", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=quorum(...) must start with the number of agreeing providers, but got %q`, key, "cloudflare.trace")
+			},
+		},
+		"quorum/empty": {
+			true, "quorum(1)", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) must contain at least one provider`, key, "quorum")
+			},
+		},
+		"union": {
+			true, "union(local, stun:stun.example.net:3478)", false, "", none,
+			provider.NewUnion(local, stun), true, nil,
+		},
+		"union/empty": {
+			true, "union( )", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) must contain at least one provider`, key, "union")
+			},
+		},
+		"union/none": {
+			true, "union(local, none)", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) cannot contain the provider "none"`, key, "union")
+			},
+		},
+		"union/invalid": {
+			true, "union(local, something-else)", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s (%q) is not a valid provider", key, "local, something-else")
+			},
+		},
+		"union/unbalanced": {
+			true, "union(local, union(local)))", false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) has unbalanced parentheses or double quotes`, key, "union")
+			},
+		},
+		"union/unbalanced-quotes": {
+			true, `union(url(header="X-Note: a):https://1.2.3.4, cloudflare.trace)`, false, "", none, none, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s(...) has unbalanced parentheses or double quotes`, key, "union")
+			},
+		},
 		"ipify": {
 			true, "     ipify  ", false, "", trace, ipify, true,
 			func(m *mocks.MockPP) {
@@ -310,6 +611,54 @@ func TestReadProviderMap(t *testing.T) {
 				)
 			},
 		},
+		"6/natpmp": {
+			true,
+			"natpmp", "natpmp",
+			map[ipnet.Type]provider.Provider{
+				ipnet.IP4: none,
+				ipnet.IP6: local,
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s is invalid because the provider only works for IPv4`, "IP6_PROVIDER", "natpmp")
+			},
+		},
+		"6/fallback/natpmp": {
+			true,
+			"natpmp", "fallback(local, natpmp:192.168.1.1)",
+			map[ipnet.Type]provider.Provider{
+				ipnet.IP4: none,
+				ipnet.IP6: local,
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s is invalid because the provider only works for IPv4`, "IP6_PROVIDER", "natpmp:")
+			},
+		},
+		"6/cloud.azure": {
+			true,
+			"cloud.azure", "cloud.azure",
+			map[ipnet.Type]provider.Provider{
+				ipnet.IP4: none,
+				ipnet.IP6: local,
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s is invalid because the provider only works for IPv4`, "IP6_PROVIDER", "cloud.azure")
+			},
+		},
+		"6/nat64": {
+			true,
+			"nat64", "nat64",
+			map[ipnet.Type]provider.Provider{
+				ipnet.IP4: none,
+				ipnet.IP6: local,
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s=%s is invalid because the provider only works for IPv4`, "IP6_PROVIDER", "nat64")
+			},
+		},
 		"illformed": {
 			false,
 			" flare", "   ",
@@ -340,3 +689,32 @@ func TestReadProviderMap(t *testing.T) {
 		})
 	}
 }
+
+func mustNewBound(t *testing.T, p provider.Provider, rawBinding string) provider.Provider {
+	t.Helper()
+
+	mockPP := mocks.NewMockPP(gomock.NewController(t))
+	b, ok := provider.ParseBinding(mockPP, rawBinding)
+	require.True(t, ok)
+	p, ok = provider.NewBound(mockPP, p, b)
+	require.True(t, ok)
+	return p
+}
+
+func mustNewCustomURLWithOptions(t *testing.T, rawURL string, header map[string]string) provider.Provider {
+	t.Helper()
+
+	options := provider.HTTPOptions{Header: header, BasicAuth: nil, TLSConfig: nil}
+	p, ok := provider.NewCustomURLWithOptions(mocks.NewMockPP(gomock.NewController(t)), options, rawURL)
+	require.True(t, ok)
+	return p
+}
+
+func mustNewJSONWithOptions(t *testing.T, raw string, header map[string]string) provider.Provider {
+	t.Helper()
+
+	options := provider.HTTPOptions{Header: header, BasicAuth: nil, TLSConfig: nil}
+	p, ok := provider.NewJSONWithOptions(mocks.NewMockPP(gomock.NewController(t)), options, raw)
+	require.True(t, ok)
+	return p
+}
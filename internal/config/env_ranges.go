@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/netip"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// ReadRanges reads an environment variable as a comma-separated list of IP ranges
+// in CIDR notation, such as "10.0.0.0/8,100.64.0.0/10". All ranges must be in ipNet.
+func ReadRanges(ppfmt pp.PP, key string, ipNet ipnet.Type, field *[]netip.Prefix) bool {
+	vals := GetenvAsList(key, ",")
+	if len(vals) == 0 {
+		return true
+	}
+
+	ranges := make([]netip.Prefix, 0, len(vals))
+	for _, val := range vals {
+		r, err := netip.ParsePrefix(val)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiUserError, "%s contains %q, which is not an IP range in CIDR notation: %v", key, val, err)
+			return false
+		}
+		if !ipNet.Matches(r.Addr()) || r.Addr().Is4In6() {
+			ppfmt.Noticef(pp.EmojiUserError, "%s contains %q, which is not an %s range", key, val, ipNet.Describe())
+			return false
+		}
+		ranges = append(ranges, r.Masked())
+	}
+
+	*field = ranges
+	return true
+}
+
+// ReadRangeMap reads the allowed or denied IP ranges of both IP networks
+// from the environment variables IP4_<suffix> and IP6_<suffix>.
+func ReadRangeMap(ppfmt pp.PP, suffix string, field *map[ipnet.Type][]netip.Prefix) bool {
+	ip4Ranges := (*field)[ipnet.IP4]
+	ip6Ranges := (*field)[ipnet.IP6]
+
+	if !ReadRanges(ppfmt, "IP4_"+suffix, ipnet.IP4, &ip4Ranges) ||
+		!ReadRanges(ppfmt, "IP6_"+suffix, ipnet.IP6, &ip6Ranges) {
+		return false
+	}
+
+	if len(ip4Ranges) == 0 && len(ip6Ranges) == 0 {
+		*field = nil
+		return true
+	}
+
+	*field = map[ipnet.Type][]netip.Prefix{}
+	if len(ip4Ranges) > 0 {
+		(*field)[ipnet.IP4] = ip4Ranges
+	}
+	if len(ip6Ranges) > 0 {
+		(*field)[ipnet.IP6] = ip6Ranges
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package config_test
+
+// vim: nowrap
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+//nolint:paralleltest // paralleltest should not be used because environment vars are global
+func TestReadRanges(t *testing.T) {
+	key := keyPrefix + "RANGES"
+
+	for name, tc := range map[string]struct {
+		set           bool
+		val           string
+		ipNet         ipnet.Type
+		oldField      []netip.Prefix
+		newField      []netip.Prefix
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"unset": {false, "", ipnet.IP4, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, true, nil},
+		"empty": {true, " ", ipnet.IP4, nil, nil, true, nil},
+		"4": {
+			true, " 10.0.0.0/8 , 100.64.1.2/10 ", ipnet.IP4, nil,
+			[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("100.64.0.0/10")},
+			true, nil,
+		},
+		"6": {true, "fd00::/8", ipnet.IP6, nil, []netip.Prefix{netip.MustParsePrefix("fd00::/8")}, true, nil},
+		"no-length": {
+			true, "10.0.0.1", ipnet.IP4, nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains %q, which is not an IP range in CIDR notation: %v", key, "10.0.0.1", gomock.Any())
+			},
+		},
+		"wrong-family": {
+			true, "fd00::/8", ipnet.IP4, nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains %q, which is not an %s range", key, "fd00::/8", "IPv4")
+			},
+		},
+		"4in6": {
+			true, "::ffff:10.0.0.0/104", ipnet.IP6, nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains %q, which is not an %s range", key, "::ffff:10.0.0.0/104", "IPv6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			set(t, key, tc.set, tc.val)
+			field := tc.oldField
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ok := config.ReadRanges(mockPP, key, tc.ipNet, &field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.newField, field)
+		})
+	}
+}
+
+//nolint:paralleltest // paralleltest should not be used because environment vars are global
+func TestReadRangeMap(t *testing.T) {
+	suffix := keyPrefix + "RANGES"
+
+	for name, tc := range map[string]struct {
+		set4, set6    bool
+		val4, val6    string
+		oldField      map[ipnet.Type][]netip.Prefix
+		newField      map[ipnet.Type][]netip.Prefix
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"unset": {false, false, "", "", nil, nil, true, nil},
+		"4": {
+			true, false, "100.64.0.0/10", "", nil,
+			map[ipnet.Type][]netip.Prefix{ipnet.IP4: {netip.MustParsePrefix("100.64.0.0/10")}},
+			true, nil,
+		},
+		"both": {
+			true, true, "100.64.0.0/10", "fc00::/7", nil,
+			map[ipnet.Type][]netip.Prefix{
+				ipnet.IP4: {netip.MustParsePrefix("100.64.0.0/10")},
+				ipnet.IP6: {netip.MustParsePrefix("fc00::/7")},
+			},
+			true, nil,
+		},
+		"invalid": {
+			true, true, "100.64.0.0/10", "10.0.0.0/8", nil, nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s contains %q, which is not an %s range", "IP6_"+suffix, "10.0.0.0/8", "IPv6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			set(t, "IP4_"+suffix, tc.set4, tc.val4)
+			set(t, "IP6_"+suffix, tc.set6, tc.val6)
+			field := tc.oldField
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ok := config.ReadRangeMap(mockPP, suffix, &field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.newField, field)
+		})
+	}
+}
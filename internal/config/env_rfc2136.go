@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/base64"
+	"net"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// Keys of environment variables for RFC 2136.
+const (
+	RFC2136ServerKey         string = "RFC2136_SERVER"
+	RFC2136TSIGKeyNameKey    string = "RFC2136_TSIG_KEY_NAME"
+	RFC2136TSIGAlgorithmKey  string = "RFC2136_TSIG_ALGORITHM"
+	RFC2136TSIGSecretKey     string = "RFC2136_TSIG_SECRET"
+	RFC2136TSIGSecretFileKey string = "RFC2136_TSIG_SECRET_FILE"
+	RFC2136AllowUnsignedKey  string = "RFC2136_ALLOW_UNSIGNED"
+)
+
+// RFC2136DefaultPort is the default port of the DNS server.
+const RFC2136DefaultPort = "53"
+
+// RFC2136DefaultTSIGAlgorithm is the default TSIG algorithm.
+const RFC2136DefaultTSIGAlgorithm = "hmac-sha256"
+
+// readRFC2136TSIGSecret reads the base64-encoded secret from RFC2136_TSIG_SECRET or RFC2136_TSIG_SECRET_FILE.
+func readRFC2136TSIGSecret(ppfmt pp.PP) ([]byte, bool) {
+	encoded, key := Getenv(RFC2136TSIGSecretKey), RFC2136TSIGSecretKey
+	if path := Getenv(RFC2136TSIGSecretFileKey); path != "" {
+		if encoded != "" {
+			ppfmt.Noticef(pp.EmojiUserError, "Cannot have both %s and %s set", RFC2136TSIGSecretKey, RFC2136TSIGSecretFileKey)
+			return nil, false
+		}
+
+		var ok bool
+		if encoded, ok = file.ReadString(ppfmt, path); !ok {
+			return nil, false
+		}
+		key = RFC2136TSIGSecretFileKey
+	}
+
+	if encoded == "" {
+		ppfmt.Noticef(pp.EmojiUserError, "Needs either %s or %s when %s is set",
+			RFC2136TSIGSecretKey, RFC2136TSIGSecretFileKey, RFC2136TSIGKeyNameKey)
+		return nil, false
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiUserError, "The TSIG secret in %s is not valid base64: %v", key, err)
+		return nil, false
+	}
+
+	return secret, true
+}
+
+// readRFC2136Auth reads environment variables RFC2136_SERVER, RFC2136_TSIG_KEY_NAME,
+// RFC2136_TSIG_ALGORITHM, RFC2136_TSIG_SECRET, RFC2136_TSIG_SECRET_FILE, and RFC2136_ALLOW_UNSIGNED
+// and creates an [api.RFC2136Auth]. Unsigned updates are only allowed with RFC2136_ALLOW_UNSIGNED=true.
+func readRFC2136Auth(ppfmt pp.PP, field *api.Auth) bool {
+	server := Getenv(RFC2136ServerKey)
+	if server == "" {
+		ppfmt.Noticef(pp.EmojiUserError, "%s=rfc2136 needs %s", BackendKey, RFC2136ServerKey)
+		return false
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, RFC2136DefaultPort)
+	}
+
+	keyName := Getenv(RFC2136TSIGKeyNameKey)
+	if keyName == "" {
+		if Getenv(RFC2136TSIGSecretKey) != "" || Getenv(RFC2136TSIGSecretFileKey) != "" {
+			ppfmt.Noticef(pp.EmojiUserError, "Needs %s to use the TSIG secret", RFC2136TSIGKeyNameKey)
+			return false
+		}
+
+		allowUnsigned := false
+		if Getenv(RFC2136AllowUnsignedKey) != "" && !ReadBool(ppfmt, RFC2136AllowUnsignedKey, &allowUnsigned) {
+			return false
+		}
+		if !allowUnsigned {
+			ppfmt.Noticef(pp.EmojiUserError,
+				"%s=rfc2136 needs %s to sign the DNS updates (or %s=true to send unsigned updates)",
+				BackendKey, RFC2136TSIGKeyNameKey, RFC2136AllowUnsignedKey)
+			return false
+		}
+
+		ppfmt.Noticef(pp.EmojiUserWarning,
+			"%s is not set; the DNS updates will not be signed, and anyone who can reach %s might be able to change your records",
+			RFC2136TSIGKeyNameKey, server)
+		*field = &api.RFC2136Auth{Server: server, Key: nil, AllowUnsigned: true}
+		return true
+	}
+
+	algorithm := Getenv(RFC2136TSIGAlgorithmKey)
+	if algorithm == "" {
+		algorithm = RFC2136DefaultTSIGAlgorithm
+	}
+	if !api.IsTSIGAlgorithm(algorithm) {
+		ppfmt.Noticef(pp.EmojiUserError,
+			"%s (%q) is not a supported TSIG algorithm; it must be hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384, or hmac-sha512",
+			RFC2136TSIGAlgorithmKey, algorithm)
+		return false
+	}
+
+	secret, ok := readRFC2136TSIGSecret(ppfmt)
+	if !ok {
+		return false
+	}
+
+	*field = &api.RFC2136Auth{
+		Server:        server,
+		Key:           &api.TSIGKey{Name: keyName, Algorithm: algorithm, Secret: secret},
+		AllowUnsigned: false,
+	}
+	return true
+}
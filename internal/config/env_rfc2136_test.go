@@ -0,0 +1,172 @@
+package config_test
+
+// vim: nowrap
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+//nolint:paralleltest // environment vars and file system are global
+func TestReadAuthRFC2136(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mapFS         map[string]string
+		backend       string
+		server        string
+		keyName       string
+		algorithm     string
+		secret        string
+		secretFile    string
+		allowUnsigned string
+		ok            bool
+		expected      api.Auth
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"success": {
+			nil, "rfc2136", "ns.example.org", "ddns-key", "", "c2VjcmV0", "", "",
+			true, &api.RFC2136Auth{Server: "ns.example.org:53", Key: &api.TSIGKey{Name: "ddns-key", Algorithm: "hmac-sha256", Secret: []byte("secret")}, AllowUnsigned: false},
+			nil,
+		},
+		"success/upper-case": {
+			nil, " RFC2136 ", "[2001:db8::53]:5353", "ddns-key", "hmac-sha512", "c2VjcmV0", "", "",
+			true, &api.RFC2136Auth{Server: "[2001:db8::53]:5353", Key: &api.TSIGKey{Name: "ddns-key", Algorithm: "hmac-sha512", Secret: []byte("secret")}, AllowUnsigned: false},
+			nil,
+		},
+		"success/ipv6": {
+			nil, "rfc2136", "2001:db8::53", "ddns-key", "", "c2VjcmV0", "", "",
+			true, &api.RFC2136Auth{Server: "[2001:db8::53]:53", Key: &api.TSIGKey{Name: "ddns-key", Algorithm: "hmac-sha256", Secret: []byte("secret")}, AllowUnsigned: false},
+			nil,
+		},
+		"success/file": {
+			map[string]string{"secret.txt": "  c2VjcmV0\n"}, "rfc2136", "ns.example.org:53", "ddns-key", "", "", "secret.txt", "",
+			true, &api.RFC2136Auth{Server: "ns.example.org:53", Key: &api.TSIGKey{Name: "ddns-key", Algorithm: "hmac-sha256", Secret: []byte("secret")}, AllowUnsigned: false},
+			nil,
+		},
+		"unsigned": {
+			nil, "rfc2136", "ns.example.org", "", "", "", "", "true",
+			true, &api.RFC2136Auth{Server: "ns.example.org:53", Key: nil, AllowUnsigned: true},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserWarning, "%s is not set; the DNS updates will not be signed, and anyone who can reach %s might be able to change your records", "RFC2136_TSIG_KEY_NAME", "ns.example.org:53")
+			},
+		},
+		"unsigned/not-allowed": {
+			nil, "rfc2136", "ns.example.org", "", "", "", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s=rfc2136 needs %s to sign the DNS updates (or %s=true to send unsigned updates)", "API_BACKEND", "RFC2136_TSIG_KEY_NAME", "RFC2136_ALLOW_UNSIGNED")
+			},
+		},
+		"unsigned/false": {
+			nil, "rfc2136", "ns.example.org", "", "", "", "", "false",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s=rfc2136 needs %s to sign the DNS updates (or %s=true to send unsigned updates)", "API_BACKEND", "RFC2136_TSIG_KEY_NAME", "RFC2136_ALLOW_UNSIGNED")
+			},
+		},
+		"unsigned/invalid": {
+			nil, "rfc2136", "ns.example.org", "", "", "", "", "maybe",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s (%q) is not a boolean: %v", "RFC2136_ALLOW_UNSIGNED", "maybe", gomock.Any())
+			},
+		},
+		"no-server": {
+			nil, "rfc2136", "", "ddns-key", "", "c2VjcmV0", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s=rfc2136 needs %s", "API_BACKEND", "RFC2136_SERVER")
+			},
+		},
+		"no-key-name": {
+			nil, "rfc2136", "ns.example.org", "", "", "c2VjcmV0", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Needs %s to use the TSIG secret", "RFC2136_TSIG_KEY_NAME")
+			},
+		},
+		"no-secret": {
+			nil, "rfc2136", "ns.example.org", "ddns-key", "", "", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Needs either %s or %s when %s is set", "RFC2136_TSIG_SECRET", "RFC2136_TSIG_SECRET_FILE", "RFC2136_TSIG_KEY_NAME")
+			},
+		},
+		"both-secrets": {
+			map[string]string{"secret.txt": "c2VjcmV0"}, "rfc2136", "ns.example.org", "ddns-key", "", "c2VjcmV0", "secret.txt", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Cannot have both %s and %s set", "RFC2136_TSIG_SECRET", "RFC2136_TSIG_SECRET_FILE")
+			},
+		},
+		"secret-file/wrong-path": {
+			nil, "rfc2136", "ns.example.org", "ddns-key", "", "", "wrong.txt", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to read %q: %v", "wrong.txt", gomock.Any())
+			},
+		},
+		"invalid-secret": {
+			nil, "rfc2136", "ns.example.org", "ddns-key", "", "not base64!", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "The TSIG secret in %s is not valid base64: %v", "RFC2136_TSIG_SECRET", gomock.Any())
+			},
+		},
+		"invalid-algorithm": {
+			nil, "rfc2136", "ns.example.org", "ddns-key", "hmac-md5", "c2VjcmV0", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s (%q) is not a supported TSIG algorithm; it must be hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384, or hmac-sha512", "RFC2136_TSIG_ALGORITHM", "hmac-md5")
+			},
+		},
+		"invalid-backend": {
+			nil, "bind", "ns.example.org", "", "", "", "", "",
+			false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%s (%q) is not a supported backend; it must be "cloudflare", "rfc2136", or "powerdns"`, "API_BACKEND", "bind")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			unset(t, "CLOUDFLARE_API_TOKEN", "CLOUDFLARE_API_TOKEN_FILE", "CF_API_TOKEN", "CF_API_TOKEN_FILE", "CF_ACCOUNT_ID")
+			store(t, "API_BACKEND", tc.backend)
+			store(t, "RFC2136_SERVER", tc.server)
+			store(t, "RFC2136_TSIG_KEY_NAME", tc.keyName)
+			store(t, "RFC2136_TSIG_ALGORITHM", tc.algorithm)
+			store(t, "RFC2136_TSIG_SECRET", tc.secret)
+			store(t, "RFC2136_TSIG_SECRET_FILE", tc.secretFile)
+			store(t, "RFC2136_ALLOW_UNSIGNED", tc.allowUnsigned)
+
+			mapFS := fstest.MapFS{}
+			for path, content := range tc.mapFS {
+				mapFS[path] = &fstest.MapFile{
+					Data:    []byte(content),
+					Mode:    0o644,
+					ModTime: time.Unix(1234, 5678),
+					Sys:     nil,
+				}
+			}
+			useMemFS(mapFS)
+
+			var field api.Auth
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ok := config.ReadAuth(mockPP, &field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, field)
+		})
+	}
+}
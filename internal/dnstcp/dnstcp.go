@@ -0,0 +1,51 @@
+// Package dnstcp implements the exchange of DNS messages over TCP (RFC 7766).
+package dnstcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+)
+
+var errMessageTooLong = errors.New("the DNS message is too long")
+
+// Exchange sends one message over the connection and reads one response.
+// Each message is prefixed with its length in two bytes. The connection is closed
+// when the context is done, and the cause of the context is then returned.
+func Exchange(ctx context.Context, conn net.Conn, q []byte) ([]byte, error) {
+	if len(q) > math.MaxUint16 {
+		return nil, errMessageTooLong
+	}
+
+	// Closing the connection unblocks any pending I/O when the context is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := binary.BigEndian.AppendUint16(nil, uint16(len(q)))
+	if _, err := conn.Write(append(req, q...)); err != nil {
+		return nil, wrapContextError(ctx, err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, wrapContextError(ctx, err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, wrapContextError(ctx, err)
+	}
+
+	return resp, nil
+}
+
+// wrapContextError prefers the context cause when the context is done,
+// because the real error was then caused by closing the connection.
+func wrapContextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
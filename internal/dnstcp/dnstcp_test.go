@@ -0,0 +1,83 @@
+package dnstcp_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/favonia/cloudflare-ddns/internal/dnstcp"
+)
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		var length [2]byte
+		if _, err := io.ReadFull(server, length[:]); err != nil {
+			return
+		}
+		q := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(server, q); err != nil {
+			return
+		}
+		resp := append([]byte("re:"), q...)
+		_, _ = server.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+	}()
+
+	resp, err := dnstcp.Exchange(context.Background(), client, []byte("query"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("re:query"), resp)
+}
+
+func TestExchangeTooLong(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	_, err := dnstcp.Exchange(context.Background(), client, make([]byte, 1<<16))
+	require.EqualError(t, err, "the DNS message is too long")
+}
+
+func TestExchangeShortResponse(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		_, _ = io.ReadFull(server, make([]byte, 7))
+		_, _ = server.Write([]byte{0, 10, 'x'}) // promising 10 bytes but sending only 1
+		server.Close()
+	}()
+
+	_, err := dnstcp.Exchange(context.Background(), client, []byte("query"))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestExchangeCanceled(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	cause := errors.New("canceled for testing")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		_, _ = io.ReadFull(server, make([]byte, 7))
+		cancel(cause) // never responding
+	}()
+
+	_, err := dnstcp.Exchange(ctx, client, []byte("query"))
+	require.ErrorIs(t, err, cause)
+}
@@ -197,3 +197,39 @@ func (c *MockSetterSetWAFListCall) DoAndReturn(f func(context.Context, pp.PP, ap
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// StartRound mocks base method.
+func (m *MockSetter) StartRound() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StartRound")
+}
+
+// StartRound indicates an expected call of StartRound.
+func (mr *MockSetterMockRecorder) StartRound() *MockSetterStartRoundCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartRound", reflect.TypeOf((*MockSetter)(nil).StartRound))
+	return &MockSetterStartRoundCall{Call: call}
+}
+
+// MockSetterStartRoundCall wrap *gomock.Call
+type MockSetterStartRoundCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockSetterStartRoundCall) Return() *MockSetterStartRoundCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockSetterStartRoundCall) Do(f func()) *MockSetterStartRoundCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockSetterStartRoundCall) DoAndReturn(f func()) *MockSetterStartRoundCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
@@ -0,0 +1,179 @@
+// Package netwatch watches changes of network addresses and routes,
+// so that the updater can check the IP addresses without waiting for the next scheduled update.
+package netwatch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// Constants from the Linux headers <linux/netlink.h> and <linux/rtnetlink.h>.
+// They are copied here so that the parser can be compiled and tested on all platforms.
+const (
+	nlmsgHeaderLen   = 16
+	netlinkAlignment = 4
+	rtmNewAddr       = 20
+	rtmDelAddr       = 21
+	rtmNewRoute      = 24
+	rtmDelRoute      = 25
+)
+
+// DefaultDebounce is how long the network should be quiet before the updater is woken up.
+// A reconnection usually changes addresses and routes several times within a few seconds.
+const DefaultDebounce = 3 * time.Second
+
+// addrKey identifies an address of a network interface.
+type addrKey struct {
+	index int
+	addr  netip.Addr
+}
+
+// Tracker remembers the addresses of network interfaces and their flags.
+// The kernel sends RTM_NEWADDR again whenever the lifetimes of an address are refreshed,
+// for example after every IPv6 router advertisement, and such refreshes are not changes.
+type Tracker struct {
+	addrs map[addrKey]uint32
+}
+
+// NewTracker creates a [Tracker] that knows the addresses.
+func NewTracker(addrs []protocol.InterfaceAddr) *Tracker {
+	t := &Tracker{addrs: make(map[addrKey]uint32, len(addrs))}
+	for _, a := range addrs {
+		t.addrs[addrKey{index: a.Index, addr: a.Addr}] = a.Flags
+	}
+	return t
+}
+
+// IsNetworkChange checks whether the netlink messages contain any changes of addresses or routes,
+// and remembers the new addresses. A new address is a change only if it is unknown or its flags changed,
+// such as when it becomes deprecated. Netlink messages use the native byte order.
+func (t *Tracker) IsNetworkChange(data []byte) bool {
+	changed := false
+	for len(data) >= nlmsgHeaderLen {
+		msgLen := int(binary.NativeEndian.Uint32(data[0:4]))
+		msgType := binary.NativeEndian.Uint16(data[4:6])
+		if msgLen < nlmsgHeaderLen || msgLen > len(data) {
+			return changed
+		}
+
+		switch msgType {
+		case rtmNewAddr:
+			changed = t.add(data[:msgLen]) || changed
+		case rtmDelAddr:
+			t.remove(data[:msgLen])
+			changed = true
+		case rtmNewRoute, rtmDelRoute:
+			changed = true
+		}
+
+		data = data[min((msgLen+netlinkAlignment-1)&^(netlinkAlignment-1), len(data)):]
+	}
+	return changed
+}
+
+// parseAddr parses a single RTM_NEWADDR or RTM_DELADDR message.
+func parseAddr(msg []byte) (protocol.InterfaceAddr, bool) {
+	a, ok, err := protocol.ParseIfAddrMsg(msg[nlmsgHeaderLen:])
+	return a, ok && err == nil
+}
+
+// add remembers the address in an RTM_NEWADDR message and checks whether it is a change.
+// A message that cannot be parsed is treated as a change.
+func (t *Tracker) add(msg []byte) bool {
+	a, ok := parseAddr(msg)
+	if !ok {
+		return true
+	}
+	key := addrKey{index: a.Index, addr: a.Addr}
+	if flags, known := t.addrs[key]; known && flags == a.Flags {
+		return false
+	}
+	t.addrs[key] = a.Flags
+	return true
+}
+
+// remove forgets the address in an RTM_DELADDR message.
+func (t *Tracker) remove(msg []byte) {
+	if a, ok := parseAddr(msg); ok {
+		delete(t.addrs, addrKey{index: a.Index, addr: a.Addr})
+	}
+}
+
+// readChanges reads the netlink messages and sends to events when addresses or routes change.
+// It owns the tracker and replaces it with a fresh one from relist when messages were dropped.
+// It stops at the first other read error, which is sent to errs unless the context is canceled.
+// The channel events is closed when it stops.
+func readChanges(ctx context.Context, r io.Reader, bufSize int, tracker *Tracker, relist func() *Tracker,
+	events chan<- struct{}, errs chan<- error,
+) {
+	defer close(events)
+	buf := make([]byte, bufSize)
+	for {
+		n, err := r.Read(buf)
+		switch {
+		case errors.Is(err, syscall.ENOBUFS):
+			// Some messages were dropped; assume they were about changes and list the addresses again.
+			tracker = relist()
+		case err != nil:
+			if ctx.Err() == nil {
+				errs <- err
+			}
+			return
+		case !tracker.IsNetworkChange(buf[:n]):
+			continue
+		}
+
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Debounce sends to the returned channel after the input channel has been quiet for the duration d.
+// The returned channel has a buffer of one, and extra wake-ups are dropped when the buffer is full.
+// It stops when the context is canceled or the input channel is closed. In the latter case,
+// it wakes up one last time (unless the context is canceled) so that the caller can notice.
+func Debounce(ctx context.Context, in <-chan struct{}, d time.Duration) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	go debounce(ctx, in, out, d, time.After)
+	return out
+}
+
+// debounce runs the loop of [Debounce] until the context is canceled or the input channel is closed.
+// The function after starts a timer; it is [time.After] except in testing.
+func debounce(ctx context.Context, in <-chan struct{}, out chan<- struct{}, d time.Duration,
+	after func(time.Duration) <-chan time.Time,
+) {
+	var quiet <-chan time.Time // nil (never fires) until the first change
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-in:
+			if !ok {
+				if ctx.Err() == nil {
+					select {
+					case out <- struct{}{}:
+					default:
+					}
+				}
+				return
+			}
+			quiet = after(d)
+		case <-quiet:
+			quiet = nil
+			select {
+			case out <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package netwatch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock hands the timers started by [debounce] to the test, which fires them by hand.
+type fakeClock struct {
+	timers chan chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{timers: make(chan chan time.Time)}
+}
+
+func (c *fakeClock) after(time.Duration) <-chan time.Time {
+	timer := make(chan time.Time, 1)
+	c.timers <- timer
+	return timer
+}
+
+// startDebounce runs [debounce] and returns the input, the output, and a channel closed when it stops.
+func startDebounce(ctx context.Context, clock *fakeClock) (chan<- struct{}, <-chan struct{}, <-chan struct{}) {
+	in := make(chan struct{})
+	out := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		debounce(ctx, in, out, time.Second, clock.after)
+	}()
+	return in, out, done
+}
+
+func requireNoWakeUp(t *testing.T, out <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-out:
+		t.Fatal("woken up unexpectedly")
+	default:
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	in, out, _ := startDebounce(t.Context(), clock)
+
+	// A burst of changes restarts the timer each time; only the last timer counts.
+	var timers []chan time.Time
+	for range 5 {
+		in <- struct{}{}
+		timers = append(timers, <-clock.timers)
+	}
+	for _, timer := range timers[:4] {
+		timer <- time.Time{}
+	}
+	requireNoWakeUp(t, out)
+
+	timers[4] <- time.Time{}
+	<-out
+
+	// Another change proves that the last timer has been handled without a second wake-up.
+	in <- struct{}{}
+	<-clock.timers
+	requireNoWakeUp(t, out)
+}
+
+func TestDebounceClosed(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	in, out, done := startDebounce(t.Context(), clock)
+	in <- struct{}{}
+	timer := <-clock.timers
+	close(in)
+	<-done
+
+	// The closing wakes up the caller once; the pending timer no longer counts.
+	<-out
+	timer <- time.Time{}
+	requireNoWakeUp(t, out)
+}
+
+func TestDebounceCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	clock := newFakeClock()
+	_, out, done := startDebounce(ctx, clock)
+	cancel()
+	<-done
+
+	requireNoWakeUp(t, out)
+}
+
+// fakeSocket hands each read to the test, which answers it through the channel.
+type fakeSocket struct {
+	reads chan func([]byte) (int, error)
+}
+
+func (s fakeSocket) Read(buf []byte) (int, error) { return (<-s.reads)(buf) }
+
+// send answers a read with the data.
+func (s fakeSocket) send(data []byte) {
+	s.reads <- func(buf []byte) (int, error) { return copy(buf, data), nil }
+}
+
+// fail answers a read with the error.
+func (s fakeSocket) fail(err error) {
+	s.reads <- func([]byte) (int, error) { return 0, err }
+}
+
+// newAddrMessage builds an RTM_NEWADDR message without attributes.
+func newAddrMessage() []byte {
+	msg := make([]byte, 16+8)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:6], 20)
+	return msg
+}
+
+// startReadChanges runs [readChanges] and returns the socket, the events, the errors, and a channel
+// receiving a value each time the addresses are listed again.
+func startReadChanges(ctx context.Context) (fakeSocket, <-chan struct{}, <-chan error, <-chan struct{}) {
+	socket := fakeSocket{reads: make(chan func([]byte) (int, error))}
+	events := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	relisted := make(chan struct{}, 1)
+	relist := func() *Tracker {
+		relisted <- struct{}{}
+		return NewTracker(nil)
+	}
+	go readChanges(ctx, socket, 4096, NewTracker(nil), relist, events, errs)
+	return socket, events, errs, relisted
+}
+
+func TestReadChanges(t *testing.T) {
+	t.Parallel()
+
+	socket, events, errs, relisted := startReadChanges(t.Context())
+
+	socket.send(newAddrMessage())
+	<-events
+
+	// A truncated message is not a change; the next read proves that it has been handled.
+	socket.send(newAddrMessage()[:4])
+	socket.fail(syscall.ENOBUFS)
+	<-relisted
+	<-events
+
+	errFailed := errors.New("failed")
+	socket.fail(errFailed)
+	require.ErrorIs(t, <-errs, errFailed)
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestReadChangesCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	socket, events, errs, _ := startReadChanges(ctx)
+
+	// Closing the socket after the cancellation fails the read, which is not an error to report.
+	cancel()
+	socket.fail(errors.New("closed"))
+	_, ok := <-events
+	require.False(t, ok)
+	require.Empty(t, errs)
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// Multicast groups from <linux/rtnetlink.h>.
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+
+	groups = rtmgrpIPv4IfAddr | rtmgrpIPv4Route | rtmgrpIPv6IfAddr | rtmgrpIPv6Route
+)
+
+// Watch subscribes to the changes of addresses and routes via netlink. The first returned channel receives
+// a value after the network has been quiet for the duration debounce. The second one receives the error
+// that stopped the watching, if any; the caller should report it. It stops when the context is canceled.
+func Watch(ctx context.Context, ppfmt pp.PP, debounce time.Duration) (<-chan struct{}, <-chan error, bool) {
+	// The addresses are listed before subscribing; an unknown address only causes an extra wake-up.
+	tracker := newTracker()
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_ROUTE)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to open a netlink socket to watch network changes: %v", err)
+		return nil, nil, false
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups} //nolint:exhaustruct
+	if err := syscall.Bind(fd, sa); err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to subscribe to network changes via netlink: %v", err)
+		syscall.Close(fd)
+		return nil, nil, false
+	}
+
+	// The file is non-blocking, so reading it uses the runtime poller and closing it stops the reading.
+	socket := os.NewFile(uintptr(fd), "netlink")
+	go func() {
+		<-ctx.Done()
+		socket.Close()
+	}()
+
+	events := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	go readChanges(ctx, socket, os.Getpagesize()*8, tracker, newTracker, events, errs)
+
+	return Debounce(ctx, events, debounce), errs, true
+}
+
+// newTracker creates a [Tracker] that knows the current addresses. The addresses are only
+// used to skip refreshes, so a failure to list them is not worth reporting.
+func newTracker() *Tracker {
+	addrs, _ := protocol.DumpInterfaceAddrs()
+	return NewTracker(addrs)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package netwatch
+
+import (
+	"context"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// Watch is only implemented on Linux because it needs netlink.
+func Watch(_ context.Context, ppfmt pp.PP, _ time.Duration) (<-chan struct{}, <-chan error, bool) {
+	ppfmt.Noticef(pp.EmojiUserError, "Watching network changes is only supported on Linux")
+	return nil, nil, false
+}
@@ -0,0 +1,97 @@
+package netwatch_test
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/favonia/cloudflare-ddns/internal/netwatch"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// message builds a netlink message of the given type with an empty body of n bytes.
+func message(msgType uint16, n int) []byte {
+	msg := make([]byte, 16+n)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	return msg
+}
+
+// addrMessage builds an RTM_NEWADDR or RTM_DELADDR message of the address.
+func addrMessage(msgType uint16, index int, flags uint8, addr netip.Addr) []byte {
+	attr := make([]byte, 4, 4+addr.BitLen()/8)
+	binary.NativeEndian.PutUint16(attr[0:2], uint16(cap(attr)))
+	binary.NativeEndian.PutUint16(attr[2:4], 1) // IFA_ADDRESS
+	attr = append(attr, addr.AsSlice()...)
+
+	msg := message(msgType, 8)
+	msg[16+2] = flags
+	binary.NativeEndian.PutUint32(msg[16+4:16+8], uint32(index))
+	msg = append(msg, attr...)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	return msg
+}
+
+func TestIsNetworkChange(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		input    []byte
+		expected bool
+	}{
+		"empty":       {nil, false},
+		"newaddr":     {message(20, 8), true},
+		"deladdr":     {message(21, 8), true},
+		"newroute":    {message(24, 12), true},
+		"delroute":    {message(25, 12), true},
+		"newlink":     {message(16, 16), false},
+		"second":      {append(message(16, 16), message(21, 8)...), true},
+		"unaligned":   {append(message(16, 13), append([]byte{0, 0, 0}, message(20, 8)...)...), true},
+		"truncated":   {message(20, 8)[:20], false},
+		"short":       {[]byte{1, 2, 3}, false},
+		"bad-length":  {append([]byte{4, 0, 0, 0}, message(20, 8)[4:]...), false},
+		"only-others": {append(message(3, 4), message(16, 16)...), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, netwatch.NewTracker(nil).IsNetworkChange(tc.input))
+		})
+	}
+}
+
+func TestTrackerRefresh(t *testing.T) {
+	t.Parallel()
+
+	known := netip.MustParseAddr("2001:db8::1")
+	other := netip.MustParseAddr("192.0.2.1")
+
+	tracker := netwatch.NewTracker([]protocol.InterfaceAddr{
+		{Index: 2, Addr: known, Flags: 0x00, PreferredLifetime: 3600, ValidLifetime: 7200},
+	})
+
+	steps := []struct {
+		input    []byte
+		expected bool
+	}{
+		// A router advertisement only refreshes the lifetimes of the known address.
+		{addrMessage(20, 2, 0x00, known), false},
+		// The same address on another interface is new.
+		{addrMessage(20, 3, 0x00, known), true},
+		// The address becomes deprecated.
+		{addrMessage(20, 2, 0x20, known), true},
+		{addrMessage(20, 2, 0x20, known), false},
+		{addrMessage(20, 2, 0x00, other), true},
+		{append(addrMessage(20, 2, 0x00, other), addrMessage(20, 2, 0x20, known)...), false},
+		// A deleted address is new again when it comes back.
+		{addrMessage(21, 2, 0x00, other), true},
+		{addrMessage(20, 2, 0x00, other), true},
+		// Later messages are remembered even after a change was found.
+		{append(message(24, 12), addrMessage(20, 4, 0x00, other)...), true},
+		{addrMessage(20, 4, 0x00, other), false},
+	}
+	for i, step := range steps {
+		require.Equal(t, step.expected, tracker.IsNetworkChange(step.input), "step %d", i)
+	}
+}
@@ -20,6 +20,7 @@ const (
 	EmojiDeletion Emoji = "💀" // deleting DNS records
 	EmojiUpdate   Emoji = "📡" // updating DNS records
 	EmojiClear    Emoji = "🧹" // clearing DNS records when exiting
+	EmojiDryRun   Emoji = "📝" // changes that would be made without the dry-run mode
 
 	EmojiPing   Emoji = "🔔" // pinging and health checks
 	EmojiNotify Emoji = "📣" // notifications
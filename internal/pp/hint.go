@@ -18,4 +18,9 @@ const (
 	MessageExperimentalWAF                                     // New feature introduced in 1.14.0 on 2024/8/25
 	MessageExperimentalLocalWithInterface                      // New feature introduced in 1.15.0
 	MessageUndocumentedCustomCloudflareTraceProvider           // Undocumented feature
+	MessageCarrierGradeNAT                                     // Inbound connections cannot pass carrier-grade NAT
+	MessageNATPortForwarding                                   // Inbound connections need port forwarding
+	MessageCloudflareWARP                                      // Cloudflare WARP or Zero Trust Gateway hides the address
+	MessageProxiedOnlyWithCloudflare                           // Other DNS servers cannot proxy records
+	MessageRecordCommentsUnsupported                           // DNS servers do not keep comments
 )
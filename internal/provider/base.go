@@ -37,6 +37,69 @@ func Name(p Provider) string {
 	return p.Name()
 }
 
+// remoteBinding checks whether the provider asks a server on the Internet which address the request
+// came from, as opposed to reading the address from the machine, a file, the router, the cloud
+// metadata, or the network of a VPN. It also returns the binding of the requests. A composite provider
+// is remote if all its members are remote with the same binding. It returns false for nil.
+func remoteBinding(p Provider) (Binding, bool) {
+	switch q := p.(type) {
+	case protocol.HTTP:
+		return q.Options.Bind, true
+	case protocol.JSON:
+		return q.Options.Bind, true
+	case protocol.Regexp:
+		return q.Options.Bind, true
+	case protocol.CloudflareTrace:
+		return q.Options.Bind, true
+	case protocol.DNSOverHTTPS:
+		return q.Bind, true
+	case protocol.DNS:
+		return q.Bind, true
+	case protocol.STUN:
+		return q.Bind, true
+	case protocol.NAT64:
+		return Binding{}, true //nolint:exhaustruct
+	case fallback:
+		return membersRemoteBinding(q.members)
+	case quorum:
+		return membersRemoteBinding(q.members)
+	case union:
+		return membersRemoteBinding(q.members)
+	default:
+		return Binding{}, false //nolint:exhaustruct
+	}
+}
+
+func membersRemoteBinding(members []Provider) (Binding, bool) {
+	var b Binding
+	for i, member := range members {
+		memberBinding, ok := remoteBinding(member)
+		if !ok || (i > 0 && memberBinding != b) {
+			return Binding{}, false //nolint:exhaustruct
+		}
+		b = memberBinding
+	}
+	return b, len(members) > 0
+}
+
+// NewNATProbe gives a provider of the local addresses to compare with the ones detected by p,
+// so that NAT can be detected, or nil if the comparison makes no sense. Only a remote provider
+// sees the addresses from the outside, and the local addresses must be the ones of the same
+// uplink: the default route, the network interface, or the source address of the requests.
+func NewNATProbe(p Provider) Provider {
+	b, ok := remoteBinding(p)
+	switch {
+	case !ok:
+		return nil
+	case b.Interface != "":
+		return NewLocalWithInterface(b.Interface)
+	case b.Source.IsValid():
+		return protocol.Static{ProviderName: "literal:" + b.Source.String(), IPs: []netip.Addr{b.Source}}
+	default:
+		return NewLocal()
+	}
+}
+
 // CloseIdleConnections closes all idle (keep-alive) connections after the detection.
 // This is to prevent some lingering TCP connections from disturbing the IP detection.
 func CloseIdleConnections() {
@@ -0,0 +1,74 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func mustNewBound(t *testing.T, p provider.Provider, b provider.Binding) provider.Provider {
+	t.Helper()
+
+	p, ok := provider.NewBound(pp.NewDefault(io.Discard), p, b)
+	require.True(t, ok)
+	return p
+}
+
+func TestNewNATProbe(t *testing.T) {
+	t.Parallel()
+
+	eth1 := provider.Binding{Interface: "eth1", Source: netip.Addr{}}
+	eth2 := provider.Binding{Interface: "eth2", Source: netip.Addr{}}
+	source := provider.Binding{Interface: "", Source: netip.MustParseAddr("192.0.2.1")}
+
+	trace := provider.NewCloudflareTrace()
+	local := provider.NewLocal()
+	onEth1 := provider.NewLocalWithInterface("eth1")
+
+	for name, tc := range map[string]struct {
+		provider provider.Provider
+		expected provider.Provider
+	}{
+		"nil":              {nil, nil},
+		"cloudflare.trace": {trace, local},
+		"cloudflare.doh":   {provider.NewCloudflareDOH(), local},
+		"ipify":            {provider.NewIpify(), local},
+		"url":              {provider.MustNewCustomURL("https://1.2.3.4"), local},
+		"stun":             {provider.MustNewSTUN("stun.example.net"), local},
+		"dns":              {provider.MustNewDNS("1.1.1.1,whoami.cloudflare,TXT,CH"), local},
+		"regex-all":        {provider.MustNewRegexAll(`https://1.2.3.4,(\d+\.\d+\.\d+\.\d+)`), local},
+		"nat64":            {provider.NewNAT64(), local},
+		"custom-name":      {protocol.HTTP{ProviderName: "echo", URL: nil, Options: provider.HTTPOptions{}}, local}, //nolint:exhaustruct
+		"misleading-name":  {protocol.Static{ProviderName: "url:(redacted)", IPs: nil}, nil},
+		"local":            {local, nil},
+		"local.iface":      {provider.NewLocalWithInterface("eth0"), nil},
+		"tailscale":        {provider.NewTailscale(), nil},
+		"literal":          {provider.MustNewLiteral("1.1.1.1"), nil},
+		"file":             {provider.MustNewFile("/ip.txt"), nil},
+		"cloud.aws":        {provider.NewCloudAWS(), nil},
+		"natpmp":           {provider.NewNATPMP(), nil},
+		"k8s.node":         {provider.MustNewKubernetesNode("node1"), nil},
+		"bound/interface":  {mustNewBound(t, trace, eth1), onEth1},
+		"bound/source":     {mustNewBound(t, provider.MustNewDNS("1.1.1.1,whoami.cloudflare,TXT,CH"), source), protocol.Static{ProviderName: "literal:192.0.2.1", IPs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}}},
+		"fallback/remote":  {provider.NewFallback(trace, provider.NewCloudflareDOH()), local},
+		"fallback/local":   {provider.NewFallback(trace, local), nil},
+		"fallback/bound":   {provider.NewFallback(mustNewBound(t, trace, eth1), mustNewBound(t, provider.NewCloudflareDOH(), eth1)), onEth1},
+		"fallback/uplinks": {provider.NewFallback(mustNewBound(t, trace, eth1), mustNewBound(t, trace, eth2)), nil},
+		"fallback/mixed":   {provider.NewFallback(trace, mustNewBound(t, trace, eth1)), nil},
+		"quorum/remote":    {provider.MustNewQuorum(1, trace), local},
+		"union/local":      {provider.NewUnion(local), nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, provider.NewNATProbe(tc.provider))
+		})
+	}
+}
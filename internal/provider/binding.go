@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// Binding restricts the traffic of a provider to a network interface or a source address.
+type Binding = protocol.Binding
+
+// maxInterfaceNameLength is IFNAMSIZ in <linux/if.h> minus the terminating null byte.
+const maxInterfaceNameLength = 15
+
+// ParseBinding parses the target after "@" in a provider such as "cloudflare.trace@eth1".
+// It is either a network interface name or a source address; IPv6 addresses may be enclosed in brackets.
+func ParseBinding(ppfmt pp.PP, raw string) (Binding, bool) {
+	raw = strings.TrimSpace(raw)
+
+	if inner, ok := strings.CutPrefix(raw, "["); ok {
+		inner, ok = strings.CutSuffix(inner, "]")
+		if ip, err := netip.ParseAddr(inner); ok && err == nil && ip.Is6() && !ip.Is4In6() && ip.Zone() == "" {
+			return Binding{Interface: "", Source: ip}, true
+		}
+		ppfmt.Noticef(pp.EmojiUserError, `%q is not a valid IPv6 source address in "@[...]"`, raw)
+		return Binding{}, false //nolint:exhaustruct
+	}
+
+	if ip, err := netip.ParseAddr(raw); err == nil {
+		if ip.Zone() != "" || ip.Is4In6() {
+			ppfmt.Noticef(pp.EmojiUserError, `%q is not a valid source address after "@"`, raw)
+			return Binding{}, false //nolint:exhaustruct
+		}
+		return Binding{Interface: "", Source: ip}, true
+	}
+
+	if raw == "" || len(raw) > maxInterfaceNameLength || strings.ContainsAny(raw, "/:@()[], \t") {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`%q after "@" is neither a network interface name nor an IP address`, raw)
+		return Binding{}, false //nolint:exhaustruct
+	}
+	return Binding{Interface: raw, Source: netip.Addr{}}, true
+}
+
+// bindName inserts "@<binding>" after the keyword of the provider name,
+// for example "url:(redacted)" becomes "url@eth1:(redacted)".
+func bindName(name string, b Binding) string {
+	keyword, rest, found := strings.Cut(name, ":")
+	if !found {
+		return keyword + "@" + b.String()
+	}
+	return keyword + "@" + b.String() + ":" + rest
+}
+
+// NewBound returns a copy of the provider whose traffic is bound to the network interface
+// or the source address. Only the providers based on HTTP(S), DNS, and STUN can be bound.
+func NewBound(ppfmt pp.PP, p Provider, b Binding) (Provider, bool) {
+	switch q := p.(type) {
+	case protocol.HTTP:
+		q.ProviderName, q.Options.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.JSON:
+		q.ProviderName, q.Options.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.Regexp:
+		q.ProviderName, q.Options.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.CloudflareTrace:
+		q.ProviderName, q.Options.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.DNSOverHTTPS:
+		q.ProviderName, q.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.DNS:
+		q.ProviderName, q.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	case protocol.STUN:
+		q.ProviderName, q.Bind = bindName(q.ProviderName, b), b
+		return q, true
+	default:
+		ppfmt.Noticef(pp.EmojiUserError,
+			`The provider %s cannot be bound to a network interface or a source address; `+
+				`only the providers based on HTTP(S), DNS, or STUN can`, Name(p))
+		return nil, false
+	}
+}
@@ -0,0 +1,122 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestParseBinding(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		input         string
+		ok            bool
+		expected      provider.Binding
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"interface": {" eth1 ", true, provider.Binding{Interface: "eth1", Source: netip.Addr{}}, nil},
+		"ipv4":      {"192.0.2.1", true, provider.Binding{Interface: "", Source: netip.MustParseAddr("192.0.2.1")}, nil},
+		"ipv6":      {"[2001:db8::1]", true, provider.Binding{Interface: "", Source: netip.MustParseAddr("2001:db8::1")}, nil},
+		"ipv6/bare": {"2001:db8::1", true, provider.Binding{Interface: "", Source: netip.MustParseAddr("2001:db8::1")}, nil},
+		"ipv6/ipv4": {
+			"[1.2.3.4]", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid IPv6 source address in "@[...]"`, "[1.2.3.4]")
+			},
+		},
+		"ipv6/unclosed": {
+			"[2001:db8::1", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid IPv6 source address in "@[...]"`, "[2001:db8::1")
+			},
+		},
+		"zone": {
+			"fe80::1%eth0", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid source address after "@"`, "fe80::1%eth0")
+			},
+		},
+		"empty": {
+			"", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q after "@" is neither a network interface name nor an IP address`, "")
+			},
+		},
+		"too-long": {
+			"abcdefghijklmnop", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q after "@" is neither a network interface name nor an IP address`, "abcdefghijklmnop")
+			},
+		},
+		"slash": {
+			"eth0/1", false, provider.Binding{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q after "@" is neither a network interface name nor an IP address`, "eth0/1")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			b, ok := provider.ParseBinding(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, b)
+		})
+	}
+}
+
+func TestNewBound(t *testing.T) {
+	t.Parallel()
+
+	eth1 := provider.Binding{Interface: "eth1", Source: netip.Addr{}}
+	ipv6 := provider.Binding{Interface: "", Source: netip.MustParseAddr("2001:db8::1")}
+
+	for name, tc := range map[string]struct {
+		input         provider.Provider
+		binding       provider.Binding
+		ok            bool
+		expectedName  string
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"cloudflare.trace": {provider.NewCloudflareTrace(), eth1, true, "cloudflare.trace@eth1", nil},
+		"cloudflare.doh":   {provider.NewCloudflareDOH(), ipv6, true, "cloudflare.doh@[2001:db8::1]", nil},
+		"ipify":            {provider.NewIpify(), eth1, true, "ipify@eth1", nil},
+		"url":              {provider.MustNewCustomURL("https://1.2.3.4"), eth1, true, "url@eth1:(redacted)", nil},
+		"json":             {provider.MustNewJSON("https://1.2.3.4#ip"), eth1, true, "json@eth1:(redacted)#ip", nil},
+		"stun":             {provider.MustNewSTUN("stun.example.net"), eth1, true, "stun@eth1:stun.example.net:3478", nil},
+		"dns":              {provider.MustNewDNS("1.1.1.1,whoami.cloudflare,TXT,CH"), eth1, true, "dns@eth1:1.1.1.1:53,whoami.cloudflare.,TXT,CH", nil},
+		"local": {
+			provider.NewLocal(), eth1, false, "none",
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s cannot be bound to a network interface or a source address; only the providers based on HTTP(S), DNS, or STUN can`, "local")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			p, ok := provider.NewBound(mockPP, tc.input, tc.binding)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expectedName, provider.Name(p))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// The default endpoints of the instance metadata services.
+const (
+	awsMetadataURL       = "http://169.254.169.254"
+	gcpMetadataURL       = "http://metadata.google.internal"
+	azureMetadataURL     = "http://169.254.169.254"
+	openStackMetadataURL = "http://169.254.169.254"
+)
+
+// NewCloudAWS creates a provider that reads the public addresses from the
+// EC2 instance metadata service, using the session tokens of IMDSv2.
+func NewCloudAWS() Provider {
+	return NewCloudAWSCustom(awsMetadataURL)
+}
+
+// NewCloudAWSCustom creates a [NewCloudAWS] provider with a specific base URL.
+func NewCloudAWSCustom(baseURL string) Provider {
+	return protocol.Metadata{
+		ProviderName: "cloud.aws",
+		Token: &protocol.MetadataToken{
+			URL:    baseURL + "/latest/api/token",
+			Header: map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"},
+			Name:   "X-aws-ec2-metadata-token",
+		},
+		Header: nil,
+		Param: map[ipnet.Type]protocol.MetadataParam{
+			ipnet.IP4: {URL: baseURL + "/latest/meta-data/public-ipv4", Format: protocol.MetadataText},
+			ipnet.IP6: {URL: baseURL + "/latest/meta-data/ipv6", Format: protocol.MetadataText},
+		},
+	}
+}
+
+// NewCloudGCP creates a provider that reads the external addresses of the first
+// network interface from the Compute Engine metadata server.
+func NewCloudGCP() Provider {
+	return NewCloudGCPCustom(gcpMetadataURL)
+}
+
+// NewCloudGCPCustom creates a [NewCloudGCP] provider with a specific base URL.
+func NewCloudGCPCustom(baseURL string) Provider {
+	iface := baseURL + "/computeMetadata/v1/instance/network-interfaces/0"
+	return protocol.Metadata{
+		ProviderName: "cloud.gcp",
+		Token:        nil,
+		Header:       map[string]string{"Metadata-Flavor": "Google"},
+		Param: map[ipnet.Type]protocol.MetadataParam{
+			ipnet.IP4: {URL: iface + "/access-configs/0/external-ip", Format: protocol.MetadataText},
+			ipnet.IP6: {URL: iface + "/ipv6-access-configs/0/external-ipv6", Format: protocol.MetadataText},
+		},
+	}
+}
+
+// NewCloudAzure creates a provider that reads the public IPv4 address of the first
+// network interface from the Azure Instance Metadata Service. The service does not
+// know the public IPv6 addresses, so IPv6 is not supported.
+func NewCloudAzure() Provider {
+	return NewCloudAzureCustom(azureMetadataURL)
+}
+
+// NewCloudAzureCustom creates a [NewCloudAzure] provider with a specific base URL.
+func NewCloudAzureCustom(baseURL string) Provider {
+	return protocol.Metadata{
+		ProviderName: "cloud.azure",
+		Token:        nil,
+		Header:       map[string]string{"Metadata": "true"},
+		Param: map[ipnet.Type]protocol.MetadataParam{
+			ipnet.IP4: {
+				URL: baseURL + "/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress" +
+					"?api-version=2021-02-01&format=text",
+				Format: protocol.MetadataText,
+			},
+		},
+	}
+}
+
+// NewCloudOpenStack creates a provider that reads the floating IPv4 address from the
+// EC2-compatible metadata of OpenStack and the IPv6 addresses from its network_data.json.
+func NewCloudOpenStack() Provider {
+	return NewCloudOpenStackCustom(openStackMetadataURL)
+}
+
+// NewCloudOpenStackCustom creates a [NewCloudOpenStack] provider with a specific base URL.
+func NewCloudOpenStackCustom(baseURL string) Provider {
+	return protocol.Metadata{
+		ProviderName: "cloud.openstack",
+		Token:        nil,
+		Header:       nil,
+		Param: map[ipnet.Type]protocol.MetadataParam{
+			ipnet.IP4: {URL: baseURL + "/latest/meta-data/public-ipv4", Format: protocol.MetadataText},
+			ipnet.IP6: {URL: baseURL + "/openstack/latest/network_data.json", Format: protocol.MetadataOpenStackNetworks},
+		},
+	}
+}
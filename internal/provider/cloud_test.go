@@ -0,0 +1,116 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestCloudNames(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "cloud.aws", provider.Name(provider.NewCloudAWS()))
+	require.Equal(t, "cloud.gcp", provider.Name(provider.NewCloudGCP()))
+	require.Equal(t, "cloud.azure", provider.Name(provider.NewCloudAzure()))
+	require.Equal(t, "cloud.openstack", provider.Name(provider.NewCloudOpenStack()))
+}
+
+// newMetadataServer mimics the metadata services of all supported cloud platforms.
+func newMetadataServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	const awsToken = "AQAEAG-token"
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, awsToken)
+	})
+	awsHandler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// OpenStack does not use tokens, but AWS requires them.
+			if token := r.Header.Get("X-aws-ec2-metadata-token"); token != "" && token != awsToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, body)
+		}
+	}
+	mux.HandleFunc("GET /latest/meta-data/public-ipv4", awsHandler("203.0.113.10"))
+	mux.HandleFunc("GET /latest/meta-data/ipv6", awsHandler("2001:db8::10"))
+	googleHandler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, body)
+		}
+	}
+	mux.HandleFunc("GET /computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip",
+		googleHandler("203.0.113.20"))
+	mux.HandleFunc("GET /computeMetadata/v1/instance/network-interfaces/0/ipv6-access-configs/0/external-ipv6",
+		googleHandler("2001:db8::20"))
+	mux.HandleFunc("GET /metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("format") != "text" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "203.0.113.30")
+		})
+	mux.HandleFunc("GET /openstack/latest/network_data.json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"networks":[
+			{"id":"network0","type":"ipv4","ip_address":"10.0.0.5"},
+			{"id":"network1","type":"ipv6","ip_address":"2001:db8::40"},
+			{"id":"network2","type":"ipv6_slaac"}
+		]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCloudGetIPs(t *testing.T) {
+	t.Parallel()
+
+	server := newMetadataServer(t)
+
+	for name, tc := range map[string]struct {
+		provider provider.Provider
+		ipNet    ipnet.Type
+		expected string
+	}{
+		"aws/4":       {provider.NewCloudAWSCustom(server.URL), ipnet.IP4, "203.0.113.10"},
+		"aws/6":       {provider.NewCloudAWSCustom(server.URL), ipnet.IP6, "2001:db8::10"},
+		"gcp/4":       {provider.NewCloudGCPCustom(server.URL), ipnet.IP4, "203.0.113.20"},
+		"gcp/6":       {provider.NewCloudGCPCustom(server.URL), ipnet.IP6, "2001:db8::20"},
+		"azure/4":     {provider.NewCloudAzureCustom(server.URL), ipnet.IP4, "203.0.113.30"},
+		"openstack/4": {provider.NewCloudOpenStackCustom(server.URL), ipnet.IP4, "203.0.113.10"},
+		"openstack/6": {provider.NewCloudOpenStackCustom(server.URL), ipnet.IP6, "2001:db8::40"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockPP := mocks.NewMockPP(gomock.NewController(t))
+
+			ips, ok := tc.provider.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.True(t, ok)
+			require.Equal(t, []netip.Addr{netip.MustParseAddr(tc.expected)}, ips)
+		})
+	}
+}
@@ -15,12 +15,13 @@ func NewCloudflareDOH() Provider {
 		Param: map[ipnet.Type]protocol.DNSOverHTTPSParam{
 			ipnet.IP4: {
 				"https://cloudflare-dns.com/dns-query",
-				"whoami.cloudflare.", dnsmessage.ClassCHAOS,
+				"whoami.cloudflare.", dnsmessage.ClassCHAOS, dnsmessage.TypeTXT, false,
 			},
 			ipnet.IP6: {
 				"https://cloudflare-dns.com/dns-query",
-				"whoami.cloudflare.", dnsmessage.ClassCHAOS,
+				"whoami.cloudflare.", dnsmessage.ClassCHAOS, dnsmessage.TypeTXT, false,
 			},
 		},
+		Bind: protocol.Binding{}, //nolint:exhaustruct
 	}
 }
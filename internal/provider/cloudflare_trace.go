@@ -1,14 +1,10 @@
 package provider
 
 import (
-	"regexp"
-
 	"github.com/favonia/cloudflare-ddns/internal/ipnet"
 	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
 )
 
-var fieldIP = regexp.MustCompile(`(?m:^ip=(.*)$)`)
-
 // NewCloudflareTrace creates a specialized CloudflareTrace provider.
 // It parses https://api.cloudflare.com/cdn-cgi/trace.
 func NewCloudflareTrace() Provider {
@@ -18,11 +14,12 @@ func NewCloudflareTrace() Provider {
 // NewCloudflareTraceCustom creates a specialized CloudflareTrace provider
 // with a specific URL.
 func NewCloudflareTraceCustom(url string) Provider {
-	return protocol.Regexp{
+	return protocol.CloudflareTrace{
 		ProviderName: "cloudflare.trace",
-		Param: map[ipnet.Type]protocol.RegexpParam{
-			ipnet.IP4: {url, fieldIP},
-			ipnet.IP6: {url, fieldIP},
+		URL: map[ipnet.Type]string{
+			ipnet.IP4: url,
+			ipnet.IP6: url,
 		},
+		Options: protocol.HTTPOptions{}, //nolint:exhaustruct
 	}
 }
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// compositeName gives the name of a composite provider, such as "fallback(cloudflare.trace, cloudflare.doh)".
+func compositeName(mode string, args []string, members []Provider) string {
+	for _, member := range members {
+		args = append(args, Name(member))
+	}
+	return mode + "(" + strings.Join(args, ", ") + ")"
+}
+
+// withMemberDeadline gives the next member provider an equal share of the remaining time,
+// so that a member that hangs will not use up the time of the members after it.
+// Unused time is passed on to the remaining members.
+func withMemberDeadline(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, time.Now().Add(time.Until(deadline)/time.Duration(remaining)))
+}
+
+// getMemberIPs runs the i-th member provider. An empty result is treated as a failure.
+func getMemberIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, members []Provider, i int,
+) ([]netip.Addr, bool) {
+	ctx, cancel := withMemberDeadline(ctx, len(members)-i)
+	defer cancel()
+
+	ips, ok := members[i].GetIPs(ctx, ppfmt, ipNet)
+	if !ok || len(ips) == 0 {
+		ppfmt.Infof(pp.EmojiBullet, "The provider %s failed to detect any %s addresses",
+			Name(members[i]), ipNet.Describe())
+		return nil, false
+	}
+	return ips, true
+}
+
+// fallback tries the member providers in order and uses the first successful detection.
+type fallback struct {
+	members []Provider
+}
+
+// NewFallback creates a provider that tries the member providers in order
+// and uses the first one that detects any IP addresses.
+func NewFallback(members ...Provider) Provider {
+	return fallback{members: members}
+}
+
+// Name of the detection protocol.
+func (p fallback) Name() string {
+	return compositeName("fallback", nil, p.members)
+}
+
+// GetIPs returns the IPs detected by the first successful member provider.
+func (p fallback) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	for i := range p.members {
+		if ips, ok := getMemberIPs(ctx, ppfmt, ipNet, p.members, i); ok {
+			return ips, true
+		}
+	}
+	return nil, false
+}
+
+// quorum accepts a set of IPs only if enough member providers detected exactly the same set.
+type quorum struct {
+	threshold int
+	members   []Provider
+}
+
+// NewQuorum creates a provider that accepts a set of IP addresses only
+// if at least threshold member providers detected exactly the same set.
+func NewQuorum(ppfmt pp.PP, threshold int, members ...Provider) (Provider, bool) {
+	if threshold < 1 || threshold > len(members) {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`The number of agreeing providers for "quorum(...)" must be between 1 and %d, but got %d`,
+			len(members), threshold)
+		return nil, false
+	}
+	return quorum{threshold: threshold, members: members}, true
+}
+
+// MustNewQuorum creates a quorum provider and panics if it fails.
+func MustNewQuorum(threshold int, members ...Provider) Provider {
+	var buf strings.Builder
+	p, ok := NewQuorum(pp.NewDefault(&buf), threshold, members...)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
+
+// Name of the detection protocol.
+func (p quorum) Name() string {
+	return compositeName("quorum", []string{strconv.Itoa(p.threshold)}, p.members)
+}
+
+// GetIPs returns the set of IPs detected by at least p.threshold member providers.
+// It fails if no sets or more than one set reached the threshold.
+func (p quorum) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	type candidate struct {
+		ips   []netip.Addr
+		votes int
+	}
+	var candidates []candidate
+
+	for i := range p.members {
+		ips, ok := getMemberIPs(ctx, ppfmt, ipNet, p.members, i)
+		if !ok {
+			continue
+		}
+
+		j := slices.IndexFunc(candidates, func(c candidate) bool { return slices.Equal(c.ips, ips) })
+		if j < 0 {
+			candidates = append(candidates, candidate{ips: ips, votes: 0})
+			j = len(candidates) - 1
+		}
+		candidates[j].votes++
+
+		// Stop early if no other set (including a new one) can reach the threshold
+		// with the votes of the remaining members.
+		remaining := len(p.members) - i - 1
+		if candidates[j].votes >= p.threshold && remaining < p.threshold &&
+			!slices.ContainsFunc(candidates, func(c candidate) bool {
+				return !slices.Equal(c.ips, ips) && c.votes+remaining >= p.threshold
+			}) {
+			return ips, true
+		}
+	}
+
+	var winners []candidate
+	for _, c := range candidates {
+		if c.votes >= p.threshold {
+			winners = append(winners, c)
+		}
+	}
+
+	switch len(winners) {
+	case 1:
+		return winners[0].ips, true
+	case 0:
+		ppfmt.Noticef(pp.EmojiError,
+			"Fewer than %d providers agreed on the detected %s addresses", p.threshold, ipNet.Describe())
+	default:
+		ppfmt.Noticef(pp.EmojiError,
+			"Providers reached conflicting agreements on the detected %s addresses", ipNet.Describe())
+	}
+	return nil, false
+}
+
+// union merges the IPs detected by all member providers.
+type union struct {
+	members []Provider
+}
+
+// NewUnion creates a provider that merges the IP addresses detected by all member providers.
+// The detection fails if any member provider fails, so that a temporary failure of one member
+// will not remove the IP addresses detected by it from DNS.
+func NewUnion(members ...Provider) Provider {
+	return union{members: members}
+}
+
+// Name of the detection protocol.
+func (p union) Name() string {
+	return compositeName("union", nil, p.members)
+}
+
+// GetIPs returns the union of the IPs detected by all member providers.
+func (p union) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	var ips []netip.Addr
+	for i := range p.members {
+		memberIPs, ok := getMemberIPs(ctx, ppfmt, ipNet, p.members, i)
+		if !ok {
+			return nil, false
+		}
+		ips = append(ips, memberIPs...)
+	}
+
+	// Each member already returns normalized IPs; only the set semantics need to be restored.
+	slices.SortFunc(ips, netip.Addr.Compare)
+	return slices.Compact(ips), true
+}
@@ -0,0 +1,210 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestCompositeName(t *testing.T) {
+	t.Parallel()
+
+	trace := provider.NewCloudflareTrace()
+	doh := provider.NewCloudflareDOH()
+	literal := provider.MustNewLiteral("1.1.1.1,2.2.2.2")
+
+	require.Equal(t, "fallback(cloudflare.trace, cloudflare.doh)", provider.Name(provider.NewFallback(trace, doh)))
+	require.Equal(t, "quorum(2, cloudflare.trace, cloudflare.doh, literal:1.1.1.1,2.2.2.2)", provider.Name(provider.MustNewQuorum(2, trace, doh, literal)))
+	require.Equal(t, "union(cloudflare.trace, fallback(cloudflare.doh, literal:1.1.1.1,2.2.2.2))", provider.Name(provider.NewUnion(trace, provider.NewFallback(doh, literal))))
+}
+
+func TestNewQuorum(t *testing.T) {
+	t.Parallel()
+
+	trace := provider.NewCloudflareTrace()
+
+	for name, tc := range map[string]struct {
+		threshold int
+		ok        bool
+	}{
+		"0": {0, false},
+		"1": {1, true},
+		"2": {2, true},
+		"3": {3, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if !tc.ok {
+				mockPP.EXPECT().Noticef(pp.EmojiUserError, `The number of agreeing providers for "quorum(...)" must be between 1 and %d, but got %d`, 2, tc.threshold)
+			}
+
+			_, ok := provider.NewQuorum(mockPP, tc.threshold, trace, trace)
+			require.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+type memberResult struct {
+	ips []netip.Addr
+	ok  bool
+}
+
+//nolint:gochecknoglobals
+var (
+	compositeIPs1 = []netip.Addr{netip.MustParseAddr("1.1.1.1")}
+	compositeIPs2 = []netip.Addr{netip.MustParseAddr("2.2.2.2")}
+	compositeIPs3 = []netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("3.3.3.3")}
+)
+
+// newMembers creates one mock provider for each result. A nil result means the member should not be called.
+func newMembers(mockCtrl *gomock.Controller, mockPP *mocks.MockPP, results []*memberResult) []provider.Provider {
+	members := make([]provider.Provider, 0, len(results))
+	for i, r := range results {
+		m := mocks.NewMockProvider(mockCtrl)
+		name := "member" + string(rune('0'+i))
+		m.EXPECT().Name().Return(name).AnyTimes()
+		if r != nil {
+			m.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).Return(r.ips, r.ok)
+			if !r.ok || len(r.ips) == 0 {
+				mockPP.EXPECT().Infof(pp.EmojiBullet, "The provider %s failed to detect any %s addresses", name, "IPv4")
+			}
+		}
+		members = append(members, m)
+	}
+	return members
+}
+
+func TestCompositeGetIPs(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		newProvider   func(members []provider.Provider) provider.Provider
+		results       []*memberResult
+		expected      []netip.Addr
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"fallback/first": {
+			func(ms []provider.Provider) provider.Provider { return provider.NewFallback(ms...) },
+			[]*memberResult{{compositeIPs1, true}, nil},
+			compositeIPs1, true, nil,
+		},
+		"fallback/second": {
+			func(ms []provider.Provider) provider.Provider { return provider.NewFallback(ms...) },
+			[]*memberResult{{nil, false}, {[]netip.Addr{}, true}, {compositeIPs2, true}},
+			compositeIPs2, true, nil,
+		},
+		"fallback/none": {
+			func(ms []provider.Provider) provider.Provider { return provider.NewFallback(ms...) },
+			[]*memberResult{{nil, false}, {nil, false}},
+			nil, false, nil,
+		},
+		"quorum/agreed": {
+			func(ms []provider.Provider) provider.Provider { return provider.MustNewQuorum(2, ms...) },
+			[]*memberResult{{compositeIPs1, true}, {compositeIPs2, true}, {compositeIPs1, true}},
+			compositeIPs1, true, nil,
+		},
+		"quorum/early": {
+			func(ms []provider.Provider) provider.Provider { return provider.MustNewQuorum(2, ms...) },
+			[]*memberResult{{compositeIPs3, true}, {compositeIPs3, true}, nil},
+			compositeIPs3, true, nil,
+		},
+		"quorum/failures": {
+			func(ms []provider.Provider) provider.Provider { return provider.MustNewQuorum(2, ms...) },
+			[]*memberResult{{compositeIPs1, true}, {nil, false}, {compositeIPs1, true}},
+			compositeIPs1, true, nil,
+		},
+		"quorum/disagreed": {
+			func(ms []provider.Provider) provider.Provider { return provider.MustNewQuorum(2, ms...) },
+			[]*memberResult{{compositeIPs1, true}, {compositeIPs3, true}, {nil, false}},
+			nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Fewer than %d providers agreed on the detected %s addresses", 2, "IPv4")
+			},
+		},
+		"quorum/conflicting": {
+			func(ms []provider.Provider) provider.Provider { return provider.MustNewQuorum(1, ms...) },
+			[]*memberResult{{compositeIPs1, true}, {compositeIPs2, true}},
+			nil, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Providers reached conflicting agreements on the detected %s addresses", "IPv4")
+			},
+		},
+		"union/merged": {
+			func(ms []provider.Provider) provider.Provider { return provider.NewUnion(ms...) },
+			[]*memberResult{{compositeIPs3, true}, {compositeIPs2, true}, {compositeIPs1, true}},
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2.2.2.2"), netip.MustParseAddr("3.3.3.3")},
+			true, nil,
+		},
+		"union/failed": {
+			func(ms []provider.Provider) provider.Provider { return provider.NewUnion(ms...) },
+			[]*memberResult{{compositeIPs1, true}, {nil, false}, nil},
+			nil, false, nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p := tc.newProvider(newMembers(mockCtrl, mockPP, tc.results))
+
+			ips, ok := p.GetIPs(context.Background(), mockPP, ipnet.IP4)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+func TestCompositeDeadline(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	slow := mocks.NewMockProvider(mockCtrl)
+	slow.EXPECT().Name().Return("slow").AnyTimes()
+	slow.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).DoAndReturn(
+		func(ctx context.Context, _ pp.PP, _ ipnet.Type) ([]netip.Addr, bool) {
+			// The first of two members gets about half of the remaining time.
+			memberDeadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.Less(t, time.Until(memberDeadline), 3*time.Second)
+			return nil, false
+		})
+	mockPP.EXPECT().Infof(pp.EmojiBullet, "The provider %s failed to detect any %s addresses", "slow", "IPv4")
+
+	fast := mocks.NewMockProvider(mockCtrl)
+	fast.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).DoAndReturn(
+		func(ctx context.Context, _ pp.PP, _ ipnet.Type) ([]netip.Addr, bool) {
+			// The last member gets all the remaining time.
+			memberDeadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.Equal(t, deadline, memberDeadline)
+			return compositeIPs1, true
+		})
+
+	ips, ok := provider.NewFallback(slow, fast).GetIPs(ctx, mockPP, ipnet.IP4)
+	require.True(t, ok)
+	require.Equal(t, compositeIPs1, ips)
+}
@@ -9,28 +9,43 @@ import (
 	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
 )
 
-// NewCustomURL creates a HTTP provider.
-func NewCustomURL(ppfmt pp.PP, rawURL string) (Provider, bool) {
+// checkProviderURL checks the URL of a provider such as "url:" without revealing the URL,
+// which might contain secrets.
+func checkProviderURL(ppfmt pp.PP, prefix, rawURL string) bool {
 	u, err := url.Parse(rawURL)
 	if err != nil {
-		ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the provider url:(redacted)`)
-		return nil, false
+		ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the provider %s:(redacted)`, prefix)
+		return false
 	}
 
 	if !u.IsAbs() || u.Opaque != "" || u.Host == "" {
-		ppfmt.Noticef(pp.EmojiUserError, `The provider url:(redacted) does not contain a valid URL`)
-		return nil, false
+		ppfmt.Noticef(pp.EmojiUserError, `The provider %s:(redacted) does not contain a valid URL`, prefix)
+		return false
 	}
 
 	switch u.Scheme {
 	case "http":
-		ppfmt.Noticef(pp.EmojiUserWarning, "The provider url:(redacted) uses HTTP; consider using HTTPS instead")
+		ppfmt.Noticef(pp.EmojiUserWarning, "The provider %s:(redacted) uses HTTP; consider using HTTPS instead", prefix)
 
 	case "https":
 		// HTTPS is good!
 
 	default:
-		ppfmt.Noticef(pp.EmojiUserError, `The provider url:(redacted) only supports HTTP and HTTPS`)
+		ppfmt.Noticef(pp.EmojiUserError, `The provider %s:(redacted) only supports HTTP and HTTPS`, prefix)
+		return false
+	}
+
+	return true
+}
+
+// NewCustomURL creates a HTTP provider.
+func NewCustomURL(ppfmt pp.PP, rawURL string) (Provider, bool) {
+	return NewCustomURLWithOptions(ppfmt, HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}, rawURL)
+}
+
+// NewCustomURLWithOptions creates a HTTP provider with custom settings of HTTP(S) requests.
+func NewCustomURLWithOptions(ppfmt pp.PP, options HTTPOptions, rawURL string) (Provider, bool) {
+	if !checkProviderURL(ppfmt, "url", rawURL) {
 		return nil, false
 	}
 
@@ -40,6 +55,7 @@ func NewCustomURL(ppfmt pp.PP, rawURL string) (Provider, bool) {
 			ipnet.IP4: rawURL,
 			ipnet.IP6: rawURL,
 		},
+		Options: options,
 	}, true
 }
 
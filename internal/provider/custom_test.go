@@ -29,25 +29,25 @@ func TestNewCustom(t *testing.T) {
 		{
 			":::::", false,
 			func(m *mocks.MockPP) {
-				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to parse the provider url:(redacted)")
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to parse the provider %s:(redacted)", "url")
 			},
 		},
 		{
 			"http://1.2.3.4", true,
 			func(m *mocks.MockPP) {
-				m.EXPECT().Noticef(pp.EmojiUserWarning, "The provider url:(redacted) uses HTTP; consider using HTTPS instead")
+				m.EXPECT().Noticef(pp.EmojiUserWarning, "The provider %s:(redacted) uses HTTP; consider using HTTPS instead", "url")
 			},
 		},
 		{
 			"ftp://1.2.3.4", false,
 			func(m *mocks.MockPP) {
-				m.EXPECT().Noticef(pp.EmojiUserError, `The provider url:(redacted) only supports HTTP and HTTPS`)
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) only supports HTTP and HTTPS`, "url")
 			},
 		},
 		{
 			"", false,
 			func(m *mocks.MockPP) {
-				m.EXPECT().Noticef(pp.EmojiUserError, `The provider url:(redacted) does not contain a valid URL`)
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) does not contain a valid URL`, "url")
 			},
 		},
 	} {
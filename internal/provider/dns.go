@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// defaultDNSPort is the default port of DNS servers.
+const defaultDNSPort = 53
+
+// dnsQuestion is a parsed DNS question and its canonical description.
+type dnsQuestion struct {
+	name        string
+	class       dnsmessage.Class
+	qtype       dnsmessage.Type
+	description string
+}
+
+// parseDNSQuestion parses the domain name, the record type, and the optional class
+// (rawClass may be empty) of a DNS-based provider.
+func parseDNSQuestion(ppfmt pp.PP, prefix, rawName, rawType, rawClass string) (dnsQuestion, bool) {
+	name := rawName
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	if _, err := dnsmessage.NewName(name); name == "." || err != nil {
+		ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the domain name %q for "%s:"`, rawName, prefix)
+		return dnsQuestion{}, false
+	}
+
+	var qtype dnsmessage.Type
+	switch rawType = strings.ToUpper(rawType); rawType {
+	case "A":
+		qtype = dnsmessage.TypeA
+	case "AAAA":
+		qtype = dnsmessage.TypeAAAA
+	case "TXT":
+		qtype = dnsmessage.TypeTXT
+	default:
+		ppfmt.Noticef(pp.EmojiUserError,
+			`Unsupported DNS record type %q for "%s:"; use A, AAAA, or TXT`, rawType, prefix)
+		return dnsQuestion{}, false
+	}
+
+	description := name + "," + rawType
+	var class dnsmessage.Class
+	switch rawClass = strings.ToUpper(rawClass); rawClass {
+	case "", "IN":
+		class = dnsmessage.ClassINET
+	case "CH":
+		class = dnsmessage.ClassCHAOS
+		description += ",CH"
+	default:
+		ppfmt.Noticef(pp.EmojiUserError, `Unsupported DNS class %q for "%s:"; use IN or CH`, rawClass, prefix)
+		return dnsQuestion{}, false
+	}
+
+	return dnsQuestion{name: name, class: class, qtype: qtype, description: description}, true
+}
+
+// NewDNS creates a [protocol.DNS] provider. The parameter is given as
+// server,name,type[,class], where the port of the server defaults to 53,
+// the type is A, AAAA, or TXT, and the class is IN (the default) or CH.
+func NewDNS(ppfmt pp.PP, raw string) (Provider, bool) {
+	args := strings.Split(raw, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if len(args) < 3 || len(args) > 4 {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`Failed to parse %q for "dns:"; use "dns:<server>,<name>,<type>" or "dns:<server>,<name>,<type>,<class>"`,
+			raw)
+		return nil, false
+	}
+	args = append(args, "") // the class is optional
+
+	server, ok := parseServerAddress(ppfmt, "dns", args[0], defaultDNSPort)
+	if !ok {
+		return nil, false
+	}
+
+	q, ok := parseDNSQuestion(ppfmt, "dns", args[1], args[2], args[3])
+	if !ok {
+		return nil, false
+	}
+
+	// The server is usually a recursive resolver.
+	param := protocol.DNSParam{Server: server, Name: q.name, Class: q.class, Type: q.qtype, RecursionDesired: true}
+	return protocol.DNS{
+		ProviderName: "dns:" + server + "," + q.description,
+		Param: map[ipnet.Type]protocol.DNSParam{
+			ipnet.IP4: param,
+			ipnet.IP6: param,
+		},
+		Bind: protocol.Binding{}, //nolint:exhaustruct
+	}, true
+}
+
+// MustNewDNS creates a [protocol.DNS] provider and panics if it fails.
+func MustNewDNS(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewDNS(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
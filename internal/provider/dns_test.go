@@ -0,0 +1,104 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestDNSName(t *testing.T) {
+	t.Parallel()
+
+	for input, name := range map[string]string{
+		"resolver1.opendns.com,myip.opendns.com,A":          "dns:resolver1.opendns.com:53,myip.opendns.com.,A",
+		" resolver1.opendns.com , myip.opendns.com. , aaaa": "dns:resolver1.opendns.com:53,myip.opendns.com.,AAAA",
+		"ns1.google.com:53,o-o.myaddr.l.google.com,TXT,IN":  "dns:ns1.google.com:53,o-o.myaddr.l.google.com.,TXT",
+		"1.1.1.1,whoami.cloudflare,TXT,ch":                  "dns:1.1.1.1:53,whoami.cloudflare.,TXT,CH",
+		"::1,myip.test,A":                                   "dns:[::1]:53,myip.test.,A",
+	} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, name, provider.Name(provider.MustNewDNS(input)))
+		})
+	}
+}
+
+func TestNewDNS(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		input         string
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		{"resolver1.opendns.com,myip.opendns.com,A", true, nil},
+		{
+			"resolver1.opendns.com,myip.opendns.com", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse %q for "dns:"; use "dns:<server>,<name>,<type>" or "dns:<server>,<name>,<type>,<class>"`, "resolver1.opendns.com,myip.opendns.com")
+			},
+		},
+		{
+			"a,b,A,IN,more", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse %q for "dns:"; use "dns:<server>,<name>,<type>" or "dns:<server>,<name>,<type>,<class>"`, "a,b,A,IN,more")
+			},
+		},
+		{
+			":53,myip.opendns.com,A", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse %q as a server address for "%s:"`, ":53", "dns")
+			},
+		},
+		{
+			"resolver1.opendns.com,,A", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the domain name %q for "%s:"`, "", "dns")
+			},
+		},
+		{
+			"resolver1.opendns.com,myip.opendns.com,MX", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unsupported DNS record type %q for "%s:"; use A, AAAA, or TXT`, "MX", "dns")
+			},
+		},
+		{
+			"resolver1.opendns.com,myip.opendns.com,A,HS", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unsupported DNS class %q for "%s:"; use IN or CH`, "HS", "dns")
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p, ok := provider.NewDNS(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.NotNil(t, p)
+			} else {
+				require.Nil(t, p)
+			}
+		})
+	}
+}
+
+func TestMustNewDNS(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewDNS("resolver1.opendns.com,myip.opendns.com,A") })
+	require.Panics(t, func() { provider.MustNewDNS("resolver1.opendns.com") })
+}
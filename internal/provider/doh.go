@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewDOH creates a [protocol.DNSOverHTTPS] provider. The parameter is given as
+// url,name,type[,class], where the type is A, AAAA, or TXT, and the class is
+// IN (the default) or CH. The arguments are taken from the end so that
+// the URL itself may contain commas.
+func NewDOH(ppfmt pp.PP, raw string) (Provider, bool) {
+	args := strings.Split(raw, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+
+	rawClass := ""
+	if n := len(args); n >= 4 && (strings.EqualFold(args[n-1], "IN") || strings.EqualFold(args[n-1], "CH")) {
+		rawClass, args = args[n-1], args[:n-1]
+	}
+	if len(args) < 3 {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`Failed to parse the provider doh:(redacted); use "doh:<url>,<name>,<type>" or "doh:<url>,<name>,<type>,<class>"`)
+		return nil, false
+	}
+	n := len(args)
+	rawURL, rawName, rawType := strings.Join(args[:n-2], ","), args[n-2], args[n-1]
+
+	if !checkProviderURL(ppfmt, "doh", rawURL) {
+		return nil, false
+	}
+
+	q, ok := parseDNSQuestion(ppfmt, "doh", rawName, rawType, rawClass)
+	if !ok {
+		return nil, false
+	}
+
+	// The server is usually a recursive resolver.
+	param := protocol.DNSOverHTTPSParam{URL: rawURL, Name: q.name, Class: q.class, Type: q.qtype, RecursionDesired: true}
+	return protocol.DNSOverHTTPS{
+		ProviderName: "doh:(redacted)," + q.description,
+		Param: map[ipnet.Type]protocol.DNSOverHTTPSParam{
+			ipnet.IP4: param,
+			ipnet.IP6: param,
+		},
+		Bind: protocol.Binding{}, //nolint:exhaustruct
+	}, true
+}
+
+// MustNewDOH creates a [protocol.DNSOverHTTPS] provider and panics if it fails.
+func MustNewDOH(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewDOH(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
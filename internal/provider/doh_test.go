@@ -0,0 +1,91 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestDOHName(t *testing.T) {
+	t.Parallel()
+
+	for input, name := range map[string]string{
+		"https://cloudflare-dns.com/dns-query,whoami.cloudflare,TXT,CH": "doh:(redacted),whoami.cloudflare.,TXT,CH",
+		"https://dns.internal/dns-query, myip.internal., a":             "doh:(redacted),myip.internal.,A",
+		"https://dns.internal/q?a=1,2,myip.internal,AAAA,IN":            "doh:(redacted),myip.internal.,AAAA",
+	} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, name, provider.Name(provider.MustNewDOH(input)))
+		})
+	}
+}
+
+func TestNewDOH(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		input         string
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		{"https://dns.internal/dns-query,myip.internal,A", true, nil},
+		{"https://dns.internal/q?a=1,2,myip.internal,A", true, nil},
+		{
+			"http://dns.internal/dns-query,myip.internal,A", true,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserWarning, "The provider %s:(redacted) uses HTTP; consider using HTTPS instead", "doh")
+			},
+		},
+		{
+			"myip.internal,A", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the provider doh:(redacted); use "doh:<url>,<name>,<type>" or "doh:<url>,<name>,<type>,<class>"`)
+			},
+		},
+		{
+			"dns.internal,myip.internal,A", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) does not contain a valid URL`, "doh")
+			},
+		},
+		{
+			"https://dns.internal/dns-query,myip.internal,CNAME", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unsupported DNS record type %q for "%s:"; use A, AAAA, or TXT`, "CNAME", "doh")
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p, ok := provider.NewDOH(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.NotNil(t, p)
+			} else {
+				require.Nil(t, p)
+			}
+		})
+	}
+}
+
+func TestMustNewDOH(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewDOH("https://dns.internal/dns-query,myip.internal,A") })
+	require.Panics(t, func() { provider.MustNewDOH("https://dns.internal/dns-query") })
+}
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewExec creates a [protocol.Exec] provider. The command is split at spaces
+// without any shell processing. Only the program appears in the provider name
+// because the arguments might contain secrets.
+func NewExec(ppfmt pp.PP, raw string) (Provider, bool) {
+	command := strings.Fields(raw)
+	if len(command) == 0 {
+		ppfmt.Noticef(pp.EmojiUserError, `The provider "exec:" must be followed by a command`)
+		return nil, false
+	}
+
+	return protocol.Exec{
+		ProviderName: "exec:" + command[0],
+		Command:      command,
+	}, true
+}
+
+// MustNewExec creates a [protocol.Exec] provider and panics if it fails.
+func MustNewExec(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewExec(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
@@ -0,0 +1,38 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestExecName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "exec:/usr/local/bin/wan-ip", provider.Name(provider.MustNewExec("/usr/local/bin/wan-ip")))
+	require.Equal(t, "exec:ssh", provider.Name(provider.MustNewExec("  ssh  router  show wan ")))
+}
+
+func TestNewExec(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, `The provider "exec:" must be followed by a command`)
+
+	p, ok := provider.NewExec(mockPP, "  \t ")
+	require.False(t, ok)
+	require.Nil(t, p)
+}
+
+func TestMustNewExec(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewExec("wan-ip") })
+	require.Panics(t, func() { provider.MustNewExec("") })
+}
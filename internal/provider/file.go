@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewFile creates a [protocol.File] provider. The path must be absolute.
+func NewFile(ppfmt pp.PP, path string) (Provider, bool) {
+	if !filepath.IsAbs(path) {
+		ppfmt.Noticef(pp.EmojiUserError, `The path %q for "file:" is not absolute`, path)
+		return nil, false
+	}
+
+	path = filepath.Clean(path)
+	return protocol.File{
+		ProviderName: "file:" + path,
+		Path:         path,
+	}, true
+}
+
+// MustNewFile creates a [protocol.File] provider and panics if it fails.
+func MustNewFile(path string) Provider {
+	var buf strings.Builder
+	p, ok := NewFile(pp.NewDefault(&buf), path)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
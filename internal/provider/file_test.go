@@ -0,0 +1,38 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestFileName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "file:/run/wan4", provider.Name(provider.MustNewFile("/run/wan4")))
+	require.Equal(t, "file:/run/wan4", provider.Name(provider.MustNewFile("/run//ppp/../wan4")))
+}
+
+func TestNewFile(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, `The path %q for "file:" is not absolute`, "run/wan4")
+
+	p, ok := provider.NewFile(mockPP, "run/wan4")
+	require.False(t, ok)
+	require.Nil(t, p)
+}
+
+func TestMustNewFile(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewFile("/run/wan4") })
+	require.Panics(t, func() { provider.MustNewFile("wan4") })
+}
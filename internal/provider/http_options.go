@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// HTTPOptions are the user-specified settings of the HTTP(S) requests made by a provider.
+type HTTPOptions = protocol.HTTPOptions
+
+// ParseHTTPOptions parses the options of providers such as "url:", written as a list of
+// "key=value" separated by commas. A value containing commas can be written as a double-quoted
+// string with the escape sequences of Go, such as header="Accept: text/plain, */*".
+// The supported keys are:
+//
+//   - header: an additional request header "Name: value"; it can be used more than once
+//   - basic-auth-file: a file containing "username:password" for HTTP basic authentication
+//   - ca: a file containing PEM certificates to use instead of the system ones
+//   - client-cert and client-key: files containing the PEM client certificate and its key
+//
+// The header values and the file contents are never printed because they might contain secrets.
+func ParseHTTPOptions(ppfmt pp.PP, prefix, raw string) (HTTPOptions, bool) {
+	options := HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}
+	files := map[string]string{}
+
+	rawOptions, ok := splitHTTPOptions(raw)
+	if !ok {
+		ppfmt.Noticef(pp.EmojiUserError, `The options for "%s:" have an unterminated double-quoted value`, prefix)
+		return options, false
+	}
+
+	for _, rawOption := range rawOptions {
+		rawOption = strings.TrimSpace(rawOption)
+		if rawOption == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(rawOption, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || value == "" {
+			ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the options for "%s:"; use "<key>=<value>"`, prefix)
+			return options, false
+		}
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the double-quoted value of the option %q for "%s:"`,
+					key, prefix)
+				return options, false
+			}
+			value = unquoted
+		}
+
+		switch key {
+		case "header":
+			name, headerValue, _ := strings.Cut(value, ":")
+			name, headerValue = strings.TrimSpace(name), strings.TrimSpace(headerValue)
+			if !httpguts.ValidHeaderFieldName(name) {
+				ppfmt.Noticef(pp.EmojiUserError, `Invalid HTTP header name %q for "%s:"`, name, prefix)
+				return options, false
+			}
+			if !httpguts.ValidHeaderFieldValue(headerValue) {
+				ppfmt.Noticef(pp.EmojiUserError, `Invalid value of the HTTP header %s for "%s:"`, name, prefix)
+				return options, false
+			}
+			if options.Header == nil {
+				options.Header = map[string]string{}
+			}
+			options.Header[http.CanonicalHeaderKey(name)] = headerValue
+
+		case "basic-auth-file", "ca", "client-cert", "client-key":
+			if _, found := files[key]; found {
+				ppfmt.Noticef(pp.EmojiUserError, `The option %q for "%s:" is set more than once`, key, prefix)
+				return options, false
+			}
+			files[key] = value
+
+		default:
+			ppfmt.Noticef(pp.EmojiUserError,
+				`Unknown option %q for "%s:"; use header, basic-auth-file, ca, client-cert, or client-key`, key, prefix)
+			return options, false
+		}
+	}
+
+	if path, found := files["basic-auth-file"]; found {
+		credentials, ok := file.ReadString(ppfmt, path)
+		if !ok {
+			return options, false
+		}
+		username, password, found := strings.Cut(credentials, ":")
+		if !found {
+			ppfmt.Noticef(pp.EmojiUserError, `The file %q for "%s:" must contain "<username>:<password>"`, path, prefix)
+			return options, false
+		}
+		options.BasicAuth = url.UserPassword(username, password)
+	}
+
+	tlsConfig, ok := readTLSConfig(ppfmt, prefix, files)
+	if !ok {
+		return options, false
+	}
+	options.TLSConfig = tlsConfig
+
+	return options, true
+}
+
+// splitHTTPOptions splits the options at the commas outside double-quoted values.
+// A backslash within a double-quoted value escapes the next character.
+func splitHTTPOptions(raw string) ([]string, bool) {
+	var (
+		options []string
+		start   int
+		quoted  bool
+		escaped bool
+	)
+	for i, c := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ',':
+			options = append(options, raw[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, false
+	}
+	return append(options, raw[start:]), true
+}
+
+// readTLSConfig reads the custom CA and the client certificate. It returns nil when neither is used.
+func readTLSConfig(ppfmt pp.PP, prefix string, files map[string]string) (*tls.Config, bool) {
+	caPath, useCA := files["ca"]
+	certPath, useCert := files["client-cert"]
+	keyPath, useKey := files["client-key"]
+
+	if useCert != useKey {
+		ppfmt.Noticef(pp.EmojiUserError, `The options client-cert and client-key for "%s:" must be used together`, prefix)
+		return nil, false
+	}
+	if !useCA && !useCert {
+		return nil, true
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12} //nolint:exhaustruct
+
+	if useCA {
+		caPEM, ok := file.ReadString(ppfmt, caPath)
+		if !ok {
+			return nil, false
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(caPEM)) {
+			ppfmt.Noticef(pp.EmojiUserError, `Failed to find any PEM certificates in %q for "%s:"`, caPath, prefix)
+			return nil, false
+		}
+	}
+
+	if useCert {
+		certPEM, ok := file.ReadString(ppfmt, certPath)
+		if !ok {
+			return nil, false
+		}
+		keyPEM, ok := file.ReadString(ppfmt, keyPath)
+		if !ok {
+			return nil, false
+		}
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiUserError, `Failed to load the client certificate %q and its key %q for "%s:": %v`,
+				certPath, keyPath, prefix, err)
+			return nil, false
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, true
+}
@@ -0,0 +1,236 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+// newCertificatePEM generates a self-signed certificate and its key in PEM.
+func newCertificatePEM(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{ //nolint:exhaustruct
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"}, //nolint:exhaustruct
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Headers: nil, Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Headers: nil, Bytes: keyDER})
+}
+
+//nolint:paralleltest // changing the global file.FS
+func TestParseHTTPOptions(t *testing.T) {
+	certPEM, keyPEM := newCertificatePEM(t)
+	file.FS = fstest.MapFS{
+		"run/secrets/auth":     {Data: []byte("user:pass:word\n")},  //nolint:exhaustruct
+		"run/secrets/bad-auth": {Data: []byte("token\n")},           //nolint:exhaustruct
+		"etc/pki/ca.pem":       {Data: certPEM},                     //nolint:exhaustruct
+		"etc/pki/client.pem":   {Data: certPEM},                     //nolint:exhaustruct
+		"etc/pki/client.key":   {Data: keyPEM},                      //nolint:exhaustruct
+		"etc/pki/garbage.pem":  {Data: []byte("not a certificate")}, //nolint:exhaustruct
+	}
+	t.Cleanup(func() { file.FS = os.DirFS("/") })
+
+	for name, tc := range map[string]struct {
+		input         string
+		ok            bool
+		check         func(*testing.T, provider.HTTPOptions)
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"empty": {
+			"", true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.Equal(t, provider.HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}, o)
+			},
+			nil,
+		},
+		"headers": {
+			" header = authorization: Bearer abc , header=X-Extra:1 ", true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.Equal(t, map[string]string{"Authorization": "Bearer abc", "X-Extra": "1"}, o.Header)
+			},
+			nil,
+		},
+		"headers/quoted": {
+			`header="Accept: text/plain, */*", header = "X-Quote: say \"a, b\"" ,header=X-Extra:1`, true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.Equal(t, map[string]string{"Accept": "text/plain, */*", "X-Quote": `say "a, b"`, "X-Extra": "1"}, o.Header)
+			},
+			nil,
+		},
+		"basic-auth": {
+			"basic-auth-file=/run/secrets/auth", true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.Equal(t, url.UserPassword("user", "pass:word"), o.BasicAuth)
+				require.Nil(t, o.TLSConfig)
+			},
+			nil,
+		},
+		"tls": {
+			"ca=/etc/pki/ca.pem,client-cert=/etc/pki/client.pem,client-key=/etc/pki/client.key", true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.NotNil(t, o.TLSConfig)
+				require.NotNil(t, o.TLSConfig.RootCAs)
+				require.Len(t, o.TLSConfig.Certificates, 1)
+				require.Equal(t, uint16(tls.VersionTLS12), o.TLSConfig.MinVersion)
+			},
+			nil,
+		},
+		"ca-only": {
+			"ca=/etc/pki/ca.pem", true,
+			func(t *testing.T, o provider.HTTPOptions) {
+				t.Helper()
+				require.NotNil(t, o.TLSConfig.RootCAs)
+				require.Empty(t, o.TLSConfig.Certificates)
+			},
+			nil,
+		},
+		"no-equal-sign": {
+			"Authorization: Bearer abc", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the options for "%s:"; use "<key>=<value>"`, "url")
+			},
+		},
+		"quoted/unterminated": {
+			`header="Accept: a, b`, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The options for "%s:" have an unterminated double-quoted value`, "url")
+			},
+		},
+		"quoted/trailing": {
+			`header="Accept: a"b`, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the double-quoted value of the option %q for "%s:"`, "header", "url")
+			},
+		},
+		"unknown": {
+			"proxy=http://proxy", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unknown option %q for "%s:"; use header, basic-auth-file, ca, client-cert, or client-key`, "proxy", "url")
+			},
+		},
+		"header/invalid-name": {
+			"header=Bad Name: 1", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Invalid HTTP header name %q for "%s:"`, "Bad Name", "url")
+			},
+		},
+		"header/invalid-value": {
+			"header=X-Token: a\x00b", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Invalid value of the HTTP header %s for "%s:"`, "X-Token", "url")
+			},
+		},
+		"duplicate": {
+			"ca=/etc/pki/ca.pem,ca=/etc/pki/ca.pem", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The option %q for "%s:" is set more than once`, "ca", "url")
+			},
+		},
+		"basic-auth/missing": {
+			"basic-auth-file=/run/secrets/missing", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to read %q: %v", "run/secrets/missing", gomock.Any())
+			},
+		},
+		"basic-auth/illformed": {
+			"basic-auth-file=/run/secrets/bad-auth", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The file %q for "%s:" must contain "<username>:<password>"`, "/run/secrets/bad-auth", "url")
+			},
+		},
+		"client-cert-only": {
+			"client-cert=/etc/pki/client.pem", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The options client-cert and client-key for "%s:" must be used together`, "url")
+			},
+		},
+		"ca/garbage": {
+			"ca=/etc/pki/garbage.pem", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to find any PEM certificates in %q for "%s:"`, "/etc/pki/garbage.pem", "url")
+			},
+		},
+		"client-cert/mismatch": {
+			"client-cert=/etc/pki/client.pem,client-key=/etc/pki/ca.pem", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to load the client certificate %q and its key %q for "%s:": %v`, "/etc/pki/client.pem", "/etc/pki/ca.pem", "url", gomock.Any())
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			options, ok := provider.ParseHTTPOptions(mockPP, "url", tc.input)
+			require.Equal(t, tc.ok, ok)
+			if tc.check != nil {
+				tc.check(t, options)
+			}
+		})
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	t.Parallel()
+
+	options := provider.HTTPOptions{Header: map[string]string{"X-Token": "secret"}, BasicAuth: nil, TLSConfig: nil}
+	mockPP := mocks.NewMockPP(gomock.NewController(t))
+
+	p, ok := provider.NewCustomURLWithOptions(mockPP, options, "https://1.2.3.4")
+	require.True(t, ok)
+	require.Equal(t, "url:(redacted)", provider.Name(p))
+
+	p, ok = provider.NewJSONWithOptions(mockPP, options, "https://1.2.3.4#ip")
+	require.True(t, ok)
+	require.Equal(t, "json:(redacted)#ip", provider.Name(p))
+
+	p, ok = provider.NewRegexWithOptions(mockPP, options, "https://1.2.3.4,(.*)")
+	require.True(t, ok)
+	require.Equal(t, "regex:(redacted),(.*)", provider.Name(p))
+
+	p, ok = provider.NewRegexAllWithOptions(mockPP, options, "https://1.2.3.4,(.*)")
+	require.True(t, ok)
+	require.Equal(t, "regex-all:(redacted),(.*)", provider.Name(p))
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewJSON creates a provider that extracts IP addresses from a JSON document.
+// The argument is "<url>#<path>", where the path is a list of object keys and array indices
+// separated by dots. The special segment "*" selects all elements of an array, and an array
+// at the end of the path gives all of its elements.
+func NewJSON(ppfmt pp.PP, raw string) (Provider, bool) {
+	return NewJSONWithOptions(ppfmt, HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}, raw)
+}
+
+// NewJSONWithOptions creates a JSON provider with custom settings of HTTP(S) requests.
+func NewJSONWithOptions(ppfmt pp.PP, options HTTPOptions, raw string) (Provider, bool) {
+	rawURL, rawPath, _ := strings.Cut(strings.TrimSpace(raw), "#")
+	if !checkProviderURL(ppfmt, "json", rawURL) {
+		return nil, false
+	}
+
+	var path []string
+	if rawPath != "" {
+		path = strings.Split(rawPath, ".")
+		if slices.Contains(path, "") {
+			ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the JSON path %q for "json:"`, rawPath)
+			return nil, false
+		}
+	}
+
+	return protocol.JSON{
+		ProviderName: "json:(redacted)#" + rawPath,
+		Param: map[ipnet.Type]protocol.JSONParam{
+			ipnet.IP4: {URL: rawURL, Path: path},
+			ipnet.IP6: {URL: rawURL, Path: path},
+		},
+		Options: options,
+	}, true
+}
+
+// MustNewJSON creates a JSON provider and panics if it fails.
+func MustNewJSON(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewJSON(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
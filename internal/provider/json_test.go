@@ -0,0 +1,83 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestJSONName(t *testing.T) {
+	t.Parallel()
+
+	for input, name := range map[string]string{
+		"https://ifconfig.co/json#ip":             "json:(redacted)#ip",
+		"https://example.com/status#wan.ipv4s.*":  "json:(redacted)#wan.ipv4s.*",
+		" https://example.com/secret?token=abc# ": "json:(redacted)#",
+		"https://example.com/list":                "json:(redacted)#",
+	} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, name, provider.Name(provider.MustNewJSON(input)))
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		input         string
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		{"https://ifconfig.co/json#ip", true, nil},
+		{
+			"ftp://1.2.3.4#ip", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) only supports HTTP and HTTPS`, "json")
+			},
+		},
+		{
+			"#ip", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) does not contain a valid URL`, "json")
+			},
+		},
+		{
+			"https://ifconfig.co/json#wan..ip", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the JSON path %q for "json:"`, "wan..ip")
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p, ok := provider.NewJSON(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.NotNil(t, p)
+			} else {
+				require.Nil(t, p)
+			}
+		})
+	}
+}
+
+func TestMustNewJSON(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewJSON("https://ifconfig.co/json#ip") })
+	require.Panics(t, func() { provider.MustNewJSON("https://ifconfig.co/json#.") })
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// kubernetesNameRegex matches the names of Kubernetes objects (DNS subdomains in RFC 1123).
+var kubernetesNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// maxKubernetesNameLength is the maximum length of the names of Kubernetes objects.
+const maxKubernetesNameLength = 253
+
+func checkKubernetesName(ppfmt pp.PP, prefix, name string) bool {
+	if len(name) > maxKubernetesNameLength || !kubernetesNameRegex.MatchString(name) {
+		ppfmt.Noticef(pp.EmojiUserError, `%q is not a valid Kubernetes object name for "%s:"`, name, prefix)
+		return false
+	}
+	return true
+}
+
+// NewKubernetesService creates a [protocol.Kubernetes] provider that reads the ingress points
+// of a LoadBalancer service, such as the ones provided by MetalLB. The argument is "<namespace>/<name>".
+func NewKubernetesService(ppfmt pp.PP, raw string) (Provider, bool) {
+	namespace, name, found := strings.Cut(strings.TrimSpace(raw), "/")
+	if !found {
+		ppfmt.Noticef(pp.EmojiUserError, `The argument of "k8s.service:" must be "<namespace>/<name>", but got %q`, raw)
+		return nil, false
+	}
+	namespace, name = strings.TrimSpace(namespace), strings.TrimSpace(name)
+	if !checkKubernetesName(ppfmt, "k8s.service", namespace) || !checkKubernetesName(ppfmt, "k8s.service", name) {
+		return nil, false
+	}
+
+	return protocol.Kubernetes{
+		ProviderName: "k8s.service:" + namespace + "/" + name,
+		APIServer:    "",
+		Object:       protocol.KubernetesService,
+		Namespace:    namespace,
+		ObjectName:   name,
+	}, true
+}
+
+// MustNewKubernetesService creates a [protocol.Kubernetes] provider and panics if it fails.
+func MustNewKubernetesService(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewKubernetesService(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
+
+// NewKubernetesNode creates a [protocol.Kubernetes] provider that reads the ExternalIP addresses
+// of a node, or its InternalIP addresses if there are none.
+func NewKubernetesNode(ppfmt pp.PP, raw string) (Provider, bool) {
+	name := strings.TrimSpace(raw)
+	if !checkKubernetesName(ppfmt, "k8s.node", name) {
+		return nil, false
+	}
+
+	return protocol.Kubernetes{
+		ProviderName: "k8s.node:" + name,
+		APIServer:    "",
+		Object:       protocol.KubernetesNode,
+		Namespace:    "",
+		ObjectName:   name,
+	}, true
+}
+
+// MustNewKubernetesNode creates a [protocol.Kubernetes] provider and panics if it fails.
+func MustNewKubernetesNode(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewKubernetesNode(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestKubernetesName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "k8s.service:default/ingress", provider.Name(provider.MustNewKubernetesService(" default / ingress ")))
+	require.Equal(t, "k8s.node:worker-1.example", provider.Name(provider.MustNewKubernetesNode("worker-1.example")))
+}
+
+func TestNewKubernetesService(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		input         string
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"no-namespace": {
+			"ingress",
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The argument of "k8s.service:" must be "<namespace>/<name>", but got %q`, "ingress")
+			},
+		},
+		"invalid-namespace": {
+			"Default/ingress",
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid Kubernetes object name for "%s:"`, "Default", "k8s.service")
+			},
+		},
+		"invalid-name": {
+			"default/ingress/extra",
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid Kubernetes object name for "%s:"`, "ingress/extra", "k8s.service")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			tc.prepareMockPP(mockPP)
+
+			p, ok := provider.NewKubernetesService(mockPP, tc.input)
+			require.False(t, ok)
+			require.Nil(t, p)
+		})
+	}
+}
+
+func TestNewKubernetesNode(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, `%q is not a valid Kubernetes object name for "%s:"`, strings.Repeat("a", 254), "k8s.node")
+
+	p, ok := provider.NewKubernetesNode(mockPP, strings.Repeat("a", 254))
+	require.False(t, ok)
+	require.Nil(t, p)
+}
+
+func TestMustNewKubernetes(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewKubernetesService("default/ingress") })
+	require.Panics(t, func() { provider.MustNewKubernetesService("ingress") })
+	require.NotPanics(t, func() { provider.MustNewKubernetesNode("worker-1") })
+	require.Panics(t, func() { provider.MustNewKubernetesNode("-worker") })
+}
@@ -1,11 +1,77 @@
 package provider
 
-import "github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// InterfacePolicy restricts which addresses of a network interface are used.
+type InterfacePolicy = protocol.InterfacePolicy
 
 // NewLocalWithInterface creates a protocol.LocalWithInterface provider.
 func NewLocalWithInterface(iface string) Provider {
+	return NewLocalWithInterfacePolicy(iface, InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0})
+}
+
+// NewLocalWithInterfacePolicy creates a protocol.LocalWithInterface provider with an address policy.
+func NewLocalWithInterfacePolicy(iface string, policy InterfacePolicy) Provider {
+	name := "local.iface:" + iface
+	if !policy.IsZero() {
+		name = "local.iface(" + policy.String() + "):" + iface
+	}
 	return protocol.LocalWithInterface{
-		ProviderName:  "local.iface:" + iface,
+		ProviderName:  name,
 		InterfaceName: iface,
+		Policy:        policy,
+	}
+}
+
+// ParseInterfacePolicy parses the options of "local.iface:", written as a list separated by commas.
+// The supported options are:
+//
+//   - stable: ignore temporary addresses, such as IPv6 privacy addresses
+//   - longest: keep only the addresses with the longest preferred lifetime
+//   - max=<n>: keep at most n addresses, preferring longer preferred lifetimes
+func ParseInterfacePolicy(ppfmt pp.PP, raw string) (InterfacePolicy, bool) {
+	policy := InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}
+	seen := map[string]bool{}
+
+	for option := range strings.SplitSeq(raw, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(option, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if seen[key] {
+			ppfmt.Noticef(pp.EmojiUserError, `The option %q for "local.iface:" is set more than once`, key)
+			return policy, false
+		}
+		seen[key] = true
+
+		switch {
+		case key == "stable" && !hasValue:
+			policy.StableOnly = true
+		case key == "longest" && !hasValue:
+			policy.LongestLifetime = true
+		case key == "max" && hasValue:
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				ppfmt.Noticef(pp.EmojiUserError,
+					`The option "max" for "local.iface:" must be a positive integer, but got %q`, value)
+				return policy, false
+			}
+			policy.MaxAddresses = n
+		default:
+			ppfmt.Noticef(pp.EmojiUserError,
+				`Unknown option %q for "local.iface:"; use stable, longest, or max=<n>`, option)
+			return policy, false
+		}
 	}
+
+	return policy, true
 }
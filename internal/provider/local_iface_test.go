@@ -0,0 +1,74 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestNewLocalWithInterface(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "local.iface:eth0", provider.Name(provider.NewLocalWithInterface("eth0")))
+	require.Equal(t, "local.iface(stable,longest,max=2):eth0", provider.Name(provider.NewLocalWithInterfacePolicy("eth0",
+		provider.InterfacePolicy{StableOnly: true, LongestLifetime: true, MaxAddresses: 2})))
+}
+
+func TestParseInterfacePolicy(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		input         string
+		ok            bool
+		output        provider.InterfacePolicy
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"empty": {"", true, provider.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}, nil},
+		"all":   {" stable , longest, max = 3 ", true, provider.InterfacePolicy{StableOnly: true, LongestLifetime: true, MaxAddresses: 3}, nil},
+		"max/zero": {
+			"max=0", false, provider.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The option "max" for "local.iface:" must be a positive integer, but got %q`, "0")
+			},
+		},
+		"max/illformed": {
+			"max=many", false, provider.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The option "max" for "local.iface:" must be a positive integer, but got %q`, "many")
+			},
+		},
+		"duplicate": {
+			"stable,stable", false, provider.InterfacePolicy{StableOnly: true, LongestLifetime: false, MaxAddresses: 0},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The option %q for "local.iface:" is set more than once`, "stable")
+			},
+		},
+		"unknown": {
+			"stable=yes", false, provider.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Unknown option %q for "local.iface:"; use stable, longest, or max=<n>`, "stable=yes")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			output, ok := provider.ParseInterfacePolicy(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.output, output)
+		})
+	}
+}
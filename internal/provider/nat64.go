@@ -0,0 +1,13 @@
+package provider
+
+import "github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+
+// NewNAT64 creates a [protocol.NAT64] provider that discovers the NAT64 prefix
+// with the system resolver and reads https://1.1.1.1/cdn-cgi/trace through it.
+func NewNAT64() Provider {
+	return protocol.NAT64{
+		ProviderName: "nat64",
+		Resolver:     nil,
+		URL:          protocol.NAT64TraceURL,
+	}
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewNATPMP creates a [protocol.NATPMP] provider that asks the default gateway.
+func NewNATPMP() Provider {
+	return protocol.NATPMP{
+		ProviderName: "natpmp",
+		Gateway:      "",
+	}
+}
+
+// NewNATPMPWithGateway creates a [protocol.NATPMP] provider that asks a specific gateway.
+// The gateway is given as host:port, and the port defaults to 5351 if omitted.
+// IPv6 gateways are rejected because NAT-PMP only works over IPv4.
+func NewNATPMPWithGateway(ppfmt pp.PP, raw string) (Provider, bool) {
+	gateway, ok := parseServerAddress(ppfmt, "natpmp", raw, protocol.NATPMPPort)
+	if !ok {
+		return nil, false
+	}
+
+	host, _, _ := net.SplitHostPort(gateway)
+	if ip, err := netip.ParseAddr(host); err == nil && ip.Unmap().Is6() {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`The NAT-PMP gateway %q must be an IPv4 address or a host name because NAT-PMP only works over IPv4`, raw)
+		return nil, false
+	}
+
+	return protocol.NATPMP{
+		ProviderName: "natpmp:" + gateway,
+		Gateway:      gateway,
+	}, true
+}
+
+// MustNewNATPMPWithGateway creates a [protocol.NATPMP] provider and panics if it fails.
+func MustNewNATPMPWithGateway(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewNATPMPWithGateway(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
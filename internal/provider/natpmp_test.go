@@ -0,0 +1,45 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestNATPMPName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "natpmp", provider.Name(provider.NewNATPMP()))
+	require.Equal(t, "natpmp:192.168.1.1:5351", provider.Name(provider.MustNewNATPMPWithGateway("192.168.1.1")))
+	require.Equal(t, "natpmp:router.lan:5352", provider.Name(provider.MustNewNATPMPWithGateway("router.lan:5352")))
+}
+
+func TestNewNATPMPWithGateway(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the port %q for "%s:"`, "-1", "natpmp")
+
+	p, ok := provider.NewNATPMPWithGateway(mockPP, "192.168.1.1:-1")
+	require.False(t, ok)
+	require.Nil(t, p)
+
+	mockPP.EXPECT().Noticef(pp.EmojiUserError,
+		`The NAT-PMP gateway %q must be an IPv4 address or a host name because NAT-PMP only works over IPv4`, "[fe80::1]:5352")
+	p, ok = provider.NewNATPMPWithGateway(mockPP, "[fe80::1]:5352")
+	require.False(t, ok)
+	require.Nil(t, p)
+}
+
+func TestMustNewNATPMPWithGateway(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewNATPMPWithGateway("192.168.1.1") })
+	require.Panics(t, func() { provider.MustNewNATPMPWithGateway("[192.168.1.1") })
+}
@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+// Binding restricts the traffic of a provider to a network interface or a source address.
+// This is useful when there are multiple uplinks. The zero value does not restrict the traffic.
+type Binding struct {
+	Interface string     // the name of the network interface; empty if not used
+	Source    netip.Addr // the source address; invalid if not used
+}
+
+// IsZero checks whether the binding is the zero value.
+func (b Binding) IsZero() bool {
+	return b.Interface == "" && !b.Source.IsValid()
+}
+
+// String returns the binding in the syntax of "cloudflare.trace@...".
+// IPv6 source addresses are enclosed in brackets.
+func (b Binding) String() string {
+	switch {
+	case b.Interface != "":
+		return b.Interface
+	case b.Source.Is6():
+		return "[" + b.Source.String() + "]"
+	case b.Source.IsValid():
+		return b.Source.String()
+	default:
+		return ""
+	}
+}
+
+// bindControl adds the binding to a control function of [net.Dialer]. The filter runs first.
+func bindControl(filter func(context.Context, string, string, syscall.RawConn) error, b Binding,
+) func(context.Context, string, string, syscall.RawConn) error {
+	if b.IsZero() {
+		return filter
+	}
+
+	return func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		if err := filter(ctx, network, address, c); err != nil {
+			return err
+		}
+		if b.Source.IsValid() && b.Source.Is4() != isIP4Network(network) {
+			return fmt.Errorf("source address %v does not match the network %s", b.Source, network)
+		}
+		return bindSocket(c, b)
+	}
+}
+
+// isIP4Network tells whether the network (already checked by the filters) is IPv4.
+func isIP4Network(network string) bool {
+	switch network {
+	case "tcp4", "udp4", "ip4":
+		return true
+	default:
+		return false
+	}
+}
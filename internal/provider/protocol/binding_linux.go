@@ -0,0 +1,29 @@
+//go:build linux
+
+package protocol
+
+import (
+	"syscall"
+)
+
+// bindSocket binds the socket to the network interface (SO_BINDTODEVICE) or the source address.
+func bindSocket(c syscall.RawConn, b Binding) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		if b.Interface != "" {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, b.Interface)
+			return
+		}
+
+		var sa syscall.Sockaddr
+		if b.Source.Is4() {
+			sa = &syscall.SockaddrInet4{Port: 0, Addr: b.Source.As4()}
+		} else {
+			sa = &syscall.SockaddrInet6{Port: 0, ZoneId: 0, Addr: b.Source.As16()}
+		}
+		err = syscall.Bind(int(fd), sa)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package protocol_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestHTTPBinding(t *testing.T) {
+	t.Parallel()
+
+	// The server tells the source address by the last byte of the response.
+	server := newSplitServer(ipnet.IP4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "1.1.1.%d", netip.MustParseAddrPort(r.RemoteAddr).Addr().As4()[3])
+	}))
+	t.Cleanup(server.Close)
+
+	for name, tc := range map[string]struct {
+		binding       protocol.Binding
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"interface": {
+			protocol.Binding{Interface: "lo", Source: netip.Addr{}},
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1")}, nil,
+		},
+		"source": {
+			protocol.Binding{Interface: "", Source: netip.MustParseAddr("127.0.0.2")},
+			[]netip.Addr{netip.MustParseAddr("1.1.1.2")}, nil,
+		},
+		"source/mismatch": {
+			protocol.Binding{Interface: "", Source: netip.MustParseAddr("::1")},
+			nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q: %v", server.URL, gomock.Any())
+			},
+		},
+		"interface/missing": {
+			protocol.Binding{Interface: "no-such-iface0", Source: netip.Addr{}},
+			nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q: %v", server.URL, gomock.Any())
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			p := protocol.HTTP{
+				ProviderName: "",
+				URL:          map[ipnet.Type]string{ipnet.IP4: server.URL},
+				Options:      protocol.HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil, Bind: tc.binding},
+			}
+			// The timeout stops the retrying of failed requests.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ips, ok := p.GetIPs(ctx, mockPP, ipnet.IP4)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package protocol
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindSocket is only implemented on Linux.
+func bindSocket(_ syscall.RawConn, _ Binding) error {
+	return errors.New("binding to a network interface or a source address is only supported on Linux")
+}
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/netip"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// parseCloudflareTrace parses the "key=value" lines of /cdn-cgi/trace.
+// Lines without "=" are ignored, and later keys override earlier ones.
+func parseCloudflareTrace(body []byte) map[string]string {
+	fields := map[string]string{}
+	for line := range bytes.Lines(body) {
+		key, value, found := bytes.Cut(bytes.TrimRight(line, "\r\n"), []byte("="))
+		if !found {
+			continue
+		}
+		fields[string(key)] = string(value)
+	}
+	return fields
+}
+
+// isCloudflareEgress checks whether the request went through Cloudflare WARP
+// or Cloudflare Zero Trust Gateway, in which case the reported IP address
+// belongs to the egress of Cloudflare. The field "warp" can be "off", "on", or "plus".
+func isCloudflareEgress(fields map[string]string) bool {
+	warp := fields["warp"]
+	return (warp != "" && warp != "off") || fields["gateway"] == "on"
+}
+
+// describeTraceField gives a placeholder for missing fields.
+func describeTraceField(fields map[string]string, key string) string {
+	if value, found := fields[key]; found && value != "" {
+		return value
+	}
+	return "(unknown)"
+}
+
+// extractCloudflareTraceIPs extracts the IP address from the response of /cdn-cgi/trace
+// and refuses it when the request went through Cloudflare WARP or Zero Trust Gateway.
+func extractCloudflareTraceIPs(ppfmt pp.PP, url string, body []byte) ([]netip.Addr, bool) {
+	fields := parseCloudflareTrace(body)
+
+	ipString, found := fields["ip"]
+	if !found {
+		ppfmt.Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, url, body)
+		return nil, false
+	}
+	ip, err := netip.ParseAddr(ipString)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, ipString)
+		return nil, false
+	}
+
+	if isCloudflareEgress(fields) {
+		ppfmt.Noticef(pp.EmojiError,
+			"The response of %q says warp=%s and gateway=%s (data center %s, location %s); "+
+				"the reported IP address %s belongs to Cloudflare WARP or Zero Trust Gateway, not to this network, "+
+				"and will not be used",
+			url, describeTraceField(fields, "warp"), describeTraceField(fields, "gateway"),
+			describeTraceField(fields, "colo"), describeTraceField(fields, "loc"), ip)
+		ppfmt.NoticeOncef(pp.MessageCloudflareWARP, pp.EmojiHint,
+			"Exclude %q from WARP (for example, with split tunnels) or use another provider that does not go through Cloudflare", url)
+		return nil, false
+	}
+
+	return []netip.Addr{ip}, true
+}
+
+// CloudflareTrace detects the IP address by parsing /cdn-cgi/trace of Cloudflare.
+// Unlike the generic [Regexp] provider, it reads all the fields and refuses the result
+// when the request went through Cloudflare WARP or Zero Trust Gateway.
+type CloudflareTrace struct {
+	ProviderName string                // name of the detection protocol
+	URL          map[ipnet.Type]string // URL of the trace page
+	Options      HTTPOptions           // settings of the HTTP(S) requests
+}
+
+// Name of the detection protocol.
+func (p CloudflareTrace) Name() string { return p.ProviderName }
+
+// GetIPs detects the IP addresses by parsing /cdn-cgi/trace.
+func (p CloudflareTrace) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	url, found := p.URL[ipNet]
+	if !found {
+		ppfmt.Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", ipNet.Describe())
+		return nil, false
+	}
+
+	c := httpCore{
+		ipNet:             ipNet,
+		url:               url,
+		method:            http.MethodGet,
+		additionalHeaders: nil,
+		options:           p.Options,
+		requestBody:       nil,
+		extract: func(ppfmt pp.PP, body []byte) ([]netip.Addr, bool) {
+			return extractCloudflareTraceIPs(ppfmt, url, body)
+		},
+	}
+
+	ips, ok := c.getIPs(ctx, ppfmt)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
@@ -0,0 +1,143 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestCloudflareTraceName(t *testing.T) {
+	t.Parallel()
+
+	p := &protocol.CloudflareTrace{
+		ProviderName: "very secret name",
+		URL:          nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func TestCloudflareTraceGetIPs(t *testing.T) {
+	t.Parallel()
+
+	const (
+		trace4 = "fl=1f1\nh=api.cloudflare.com\nip=1.2.3.4\nts=1700000000.000\nvisit_scheme=https\ncolo=SJC\nloc=US\ntls=TLSv1.3\nwarp=off\ngateway=off\nkex=X25519\n"
+		trace6 = "fl=1f1\r\nip=::1:2:3:4:5:6\r\ncolo=SJC\r\nloc=US\r\nwarp=off\r\ngateway=off\r\n"
+	)
+
+	for name, tc := range map[string]struct {
+		ipNet         ipnet.Type
+		body          string
+		expected      []netip.Addr
+		ok            bool
+		prepareMockPP func(*mocks.MockPP, string)
+	}{
+		"4":            {ipnet.IP4, trace4, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, true, nil},
+		"6/crlf":       {ipnet.IP6, trace6, []netip.Addr{netip.MustParseAddr("::1:2:3:4:5:6")}, true, nil},
+		"no-warp-info": {ipnet.IP4, "ip=1.2.3.4\n", []netip.Addr{netip.MustParseAddr("1.2.3.4")}, true, nil},
+		"warp/on": {
+			ipnet.IP4, "ip=104.28.1.2\ncolo=SJC\nloc=US\nwarp=on\ngateway=off\n", nil, false,
+			func(m *mocks.MockPP, url string) {
+				gomock.InOrder(
+					m.EXPECT().Noticef(pp.EmojiError, "The response of %q says warp=%s and gateway=%s (data center %s, location %s); the reported IP address %s belongs to Cloudflare WARP or Zero Trust Gateway, not to this network, and will not be used", url, "on", "off", "SJC", "US", netip.MustParseAddr("104.28.1.2")),
+					m.EXPECT().NoticeOncef(pp.MessageCloudflareWARP, pp.EmojiHint, "Exclude %q from WARP (for example, with split tunnels) or use another provider that does not go through Cloudflare", url),
+				)
+			},
+		},
+		"warp/plus": {
+			ipnet.IP4, "ip=104.28.1.2\nwarp=plus\n", nil, false,
+			func(m *mocks.MockPP, url string) {
+				gomock.InOrder(
+					m.EXPECT().Noticef(pp.EmojiError, "The response of %q says warp=%s and gateway=%s (data center %s, location %s); the reported IP address %s belongs to Cloudflare WARP or Zero Trust Gateway, not to this network, and will not be used", url, "plus", "(unknown)", "(unknown)", "(unknown)", netip.MustParseAddr("104.28.1.2")),
+					m.EXPECT().NoticeOncef(pp.MessageCloudflareWARP, pp.EmojiHint, "Exclude %q from WARP (for example, with split tunnels) or use another provider that does not go through Cloudflare", url),
+				)
+			},
+		},
+		"gateway/on": {
+			ipnet.IP6, "ip=2a09:bac1::1\ncolo=NRT\nloc=JP\nwarp=off\ngateway=on\n", nil, false,
+			func(m *mocks.MockPP, url string) {
+				gomock.InOrder(
+					m.EXPECT().Noticef(pp.EmojiError, "The response of %q says warp=%s and gateway=%s (data center %s, location %s); the reported IP address %s belongs to Cloudflare WARP or Zero Trust Gateway, not to this network, and will not be used", url, "off", "on", "NRT", "JP", netip.MustParseAddr("2a09:bac1::1")),
+					m.EXPECT().NoticeOncef(pp.MessageCloudflareWARP, pp.EmojiHint, "Exclude %q from WARP (for example, with split tunnels) or use another provider that does not go through Cloudflare", url),
+				)
+			},
+		},
+		"no-ip": {
+			ipnet.IP4, "colo=SJC\nwarp=off\n", nil, false,
+			func(m *mocks.MockPP, url string) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, url, []byte("colo=SJC\nwarp=off\n"))
+			},
+		},
+		"illformed": {
+			ipnet.IP4, "ip=hello\n", nil, false,
+			func(m *mocks.MockPP, url string) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, "hello")
+			},
+		},
+		"6to4": {
+			ipnet.IP4, trace6, nil, false,
+			func(m *mocks.MockPP, _ string) {
+				m.EXPECT().Noticef(pp.EmojiError, "Detected IP address %s is not a valid IPv4 address", "::1:2:3:4:5:6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			server := newSplitServer(tc.ipNet, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, tc.body)
+			}))
+			t.Cleanup(server.Close)
+
+			provider := &protocol.CloudflareTrace{
+				ProviderName: "secret name",
+				URL:          map[ipnet.Type]string{tc.ipNet: server.URL},
+				Options:      protocol.HTTPOptions{}, //nolint:exhaustruct
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP, server.URL)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+func TestCloudflareTraceGetIPsNotHandled(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+
+	provider := &protocol.CloudflareTrace{
+		ProviderName: "secret name",
+		URL:          map[ipnet.Type]string{ipnet.IP4: "https://example.com"},
+		Options:      protocol.HTTPOptions{}, //nolint:exhaustruct
+	}
+
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", "IPv6")
+
+	ips, ok := provider.GetIPs(context.Background(), mockPP, ipnet.IP6)
+	require.False(t, ok)
+	require.Nil(t, ips)
+}
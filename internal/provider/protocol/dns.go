@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/dnstcp"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+const (
+	// dnsInitialRTO and dnsMaxTransmissions are the retransmission parameters of DNS over UDP.
+	// The retransmission timeout doubles after each attempt.
+	dnsInitialRTO       = time.Second
+	dnsMaxTransmissions = 3
+
+	// dnsHeaderLength is the length of the DNS message header.
+	dnsHeaderLength = 12
+)
+
+// isDNSResponseTo checks whether a UDP datagram is a DNS response with the transaction ID.
+func isDNSResponseTo(msg []byte, id uint16) bool {
+	return len(msg) >= dnsHeaderLength && binary.BigEndian.Uint16(msg[0:2]) == id && msg[2]&0x80 != 0
+}
+
+// isDNSTruncated checks whether the TC bit of a DNS response is set.
+func isDNSTruncated(msg []byte) bool {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	return err == nil && h.Truncated
+}
+
+// exchangeDNSOverTCP sends the query over TCP as described in RFC 7766.
+func exchangeDNSOverTCP(ctx context.Context, ipNet ipnet.Type, b Binding, server string, q []byte) ([]byte, error) {
+	conn, err := splitDialer(ipNet, b).DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return dnstcp.Exchange(ctx, conn, q)
+}
+
+func getIPFromPlainDNS(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, b Binding, param DNSParam,
+) (netip.Addr, bool) {
+	id := randUint16(ppfmt)
+
+	q, ok := newDNSQuery(ppfmt, id, param.Name, param.Class, param.Type, param.RecursionDesired)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	conn, err := splitDialer(ipNet, b).DialContext(ctx, "udp", param.Server)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to connect to the DNS server %s: %v", param.Server, err)
+		return netip.Addr{}, false
+	}
+	defer conn.Close()
+
+	resp, err := exchangeUDP(ctx, conn, q, dnsInitialRTO, dnsMaxTransmissions,
+		func(msg []byte) bool { return isDNSResponseTo(msg, id) })
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to receive the DNS response from %s: %v", param.Server, err)
+		return netip.Addr{}, false
+	}
+
+	if isDNSTruncated(resp) {
+		ppfmt.Infof(pp.EmojiBullet, "The DNS response from %s was truncated; retrying over TCP", param.Server)
+
+		resp, err = exchangeDNSOverTCP(ctx, ipNet, b, param.Server, q)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to receive the DNS response from %s over TCP: %v", param.Server, err)
+			return netip.Addr{}, false
+		}
+	}
+
+	return parseDNSResponse(ppfmt, resp, id, param.Name, param.Class, param.Type)
+}
+
+// DNSParam is the parameter of a plain DNS provider.
+type DNSParam struct {
+	Server string           // the DNS server in the form host:port
+	Name   string           // domain name to query
+	Class  dnsmessage.Class // DNS class to query
+	Type   dnsmessage.Type  // DNS type to query (A, AAAA, or TXT)
+
+	RecursionDesired bool // whether to set the RD bit
+}
+
+// DNS represents a generic detection protocol using plain DNS over UDP,
+// retrying over TCP when the UDP response is truncated.
+type DNS struct {
+	ProviderName string // name of the protocol
+	Param        map[ipnet.Type]DNSParam
+	Bind         Binding // the network interface or the source address of the queries
+}
+
+// Name of the detection protocol.
+func (p DNS) Name() string {
+	return p.ProviderName
+}
+
+// GetIPs detects the IP address by plain DNS.
+func (p DNS) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	param, found := p.Param[ipNet]
+	if !found {
+		ppfmt.Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", ipNet.Describe())
+		return nil, false
+	}
+
+	ip, ok := getIPFromPlainDNS(ctx, ppfmt, ipNet, p.Bind, param)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+}
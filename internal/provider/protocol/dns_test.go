@@ -0,0 +1,304 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestDNSName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.DNS{
+		ProviderName: "very secret name",
+		Param:        nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+// dnsReply builds a DNS response to the query with the answers.
+func dnsReply(t *testing.T, req []byte, header dnsmessage.Header, answers ...dnsmessage.Resource) []byte {
+	t.Helper()
+
+	var q dnsmessage.Message
+	require.NoError(t, q.Unpack(req))
+
+	header.ID = q.ID
+	header.Response = true
+	resp, err := (&dnsmessage.Message{
+		Header:      header,
+		Questions:   q.Questions,
+		Answers:     answers,
+		Authorities: []dnsmessage.Resource{},
+		Additionals: []dnsmessage.Resource{},
+	}).Pack()
+	require.NoError(t, err)
+	return resp
+}
+
+func dnsAnswer(name string, class dnsmessage.Class, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{ //nolint:exhaustruct
+			Name:  dnsmessage.MustNewName(name),
+			Class: class,
+		},
+		Body: body,
+	}
+}
+
+// newDNSServer starts UDP and TCP stand-in DNS servers on the same port.
+func newDNSServer(t *testing.T, ipNet ipnet.Type,
+	respondUDP func(req []byte) [][]byte, respondTCP func(req []byte) []byte,
+) string {
+	t.Helper()
+
+	host := map[ipnet.Type]string{ipnet.IP4: "127.0.0.1", ipnet.IP6: "::1"}[ipNet]
+	tcpNetwork := map[ipnet.Type]string{ipnet.IP4: "tcp4", ipnet.IP6: "tcp6"}[ipNet]
+
+	// The UDP port might be taken for TCP; try a few times.
+	for range 10 {
+		listener, err := net.Listen(tcpNetwork, net.JoinHostPort(host, "0")) //nolint:noctx
+		require.NoError(t, err)
+
+		conn, err := net.ListenPacket(ipNet.UDPNetwork(), listener.Addr().String()) //nolint:noctx
+		if err != nil {
+			listener.Close()
+			continue
+		}
+		t.Cleanup(func() { listener.Close() })
+
+		go func() {
+			for {
+				c, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer c.Close()
+					var length [2]byte
+					if _, err := io.ReadFull(c, length[:]); err != nil {
+						return
+					}
+					req := make([]byte, binary.BigEndian.Uint16(length[:]))
+					if _, err := io.ReadFull(c, req); err != nil {
+						return
+					}
+					resp := respondTCP(req)
+					_, _ = c.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}()
+			}
+		}()
+
+		return serveUDP(t, conn, respondUDP)
+	}
+
+	require.FailNow(t, "failed to find a port for both UDP and TCP")
+	return ""
+}
+
+func TestDNSGetIPs(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("1.2.3.4")
+	ip6 := netip.MustParseAddr("2606:4700:4700::1234")
+	invalidIP := netip.Addr{}
+	ok := dnsmessage.Header{} //nolint:exhaustruct
+	noTCP := func([]byte) []byte { return nil }
+
+	answerA := func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok, dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}))}
+	}
+	serverA := newDNSServer(t, ipnet.IP4, answerA, noTCP)
+	serverAAAA := newDNSServer(t, ipnet.IP6, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok,
+			dnsAnswer("other.test.", dnsmessage.ClassINET, &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr("::2").As16()}),
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AAAAResource{AAAA: ip6.As16()}),
+		)}
+	}, noTCP)
+	serverTXT := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok, dnsAnswer("myip.test.", dnsmessage.ClassCHAOS, &dnsmessage.TXTResource{TXT: []string{ip4.String()}}))}
+	}, noTCP)
+	serverTruncated := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, dnsmessage.Header{Truncated: true})} //nolint:exhaustruct
+	}, func(req []byte) []byte {
+		return answerA(req)[0]
+	})
+	serverNoisy := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		other := append([]byte{}, req...)
+		other[1]++
+		return [][]byte{[]byte("hello"), req, answerA(other)[0], answerA(req)[0]}
+	}, noTCP)
+	serverSilent := newDNSServer(t, ipnet.IP4, func([]byte) [][]byte { return nil }, noTCP)
+	serverDuplicate := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok,
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}),
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}),
+		)}
+	}, noTCP)
+	serverMultiple := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok,
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}),
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: [4]byte{5, 6, 7, 8}}),
+		)}
+	}, noTCP)
+	serverEmpty := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, ok)}
+	}, noTCP)
+	serverRefused := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{dnsReply(t, req, dnsmessage.Header{RCode: dnsmessage.RCodeRefused})} //nolint:exhaustruct
+	}, noTCP)
+
+	for name, tc := range map[string]struct {
+		server        string
+		ipNet         ipnet.Type
+		class         dnsmessage.Class
+		qtype         dnsmessage.Type
+		timeout       time.Duration
+		expected      netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"a":         {serverA, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, ip4, nil},
+		"aaaa":      {serverAAAA, ipnet.IP6, dnsmessage.ClassINET, dnsmessage.TypeAAAA, time.Second, ip6, nil},
+		"txt":       {serverTXT, ipnet.IP4, dnsmessage.ClassCHAOS, dnsmessage.TypeTXT, time.Second, ip4, nil},
+		"noisy":     {serverNoisy, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, ip4, nil},
+		"duplicate": {serverDuplicate, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, ip4, nil},
+		"truncated": {
+			serverTruncated, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, ip4,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Infof(pp.EmojiBullet, "The DNS response from %s was truncated; retrying over TCP", serverTruncated)
+			},
+		},
+		"timeout": {
+			serverSilent, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, 500 * time.Millisecond, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to receive the DNS response from %s: %v", serverSilent, context.DeadlineExceeded)
+			},
+		},
+		"4to6": {
+			serverA, ipnet.IP6, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to connect to the DNS server %s: %v", serverA, gomock.Any())
+			},
+		},
+		"multiple": {
+			serverMultiple, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Invalid DNS response: more than one %s record", "A")
+			},
+		},
+		"empty": {
+			serverEmpty, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Invalid DNS response: no %s records", "A")
+			},
+		},
+		"wrong-class": {
+			serverA, ipnet.IP4, dnsmessage.ClassCHAOS, dnsmessage.TypeA, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Invalid DNS response: no %s records", "A")
+			},
+		},
+		"refused": {
+			serverRefused, ipnet.IP4, dnsmessage.ClassINET, dnsmessage.TypeA, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Invalid DNS response: response code is %v", dnsmessage.RCodeRefused)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			param := protocol.DNSParam{Server: tc.server, Name: "myip.test.", Class: tc.class, Type: tc.qtype}
+			provider := protocol.DNS{
+				ProviderName: "secret name",
+				Param: map[ipnet.Type]protocol.DNSParam{
+					ipnet.IP4: param,
+					ipnet.IP6: param,
+				},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.expected.IsValid(), ok)
+			if tc.expected.IsValid() {
+				require.Equal(t, []netip.Addr{tc.expected}, ips)
+			} else {
+				require.Empty(t, ips)
+			}
+		})
+	}
+}
+
+func TestDNSGetIPsRecursionDesired(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("1.2.3.4")
+
+	// The stand-in recursive resolver refuses queries without RD and changes the case of the answers.
+	server := newDNSServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		var q dnsmessage.Message
+		require.NoError(t, q.Unpack(req))
+		if !q.RecursionDesired {
+			return [][]byte{dnsReply(t, req, dnsmessage.Header{RCode: dnsmessage.RCodeRefused})} //nolint:exhaustruct
+		}
+		return [][]byte{dnsReply(t, req, dnsmessage.Header{RecursionAvailable: true}, //nolint:exhaustruct
+			dnsAnswer("MyIP.Test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}))}
+	}, func([]byte) []byte { return nil })
+
+	for name, tc := range map[string]struct {
+		recursionDesired bool
+		expected         []netip.Addr
+		prepareMockPP    func(*mocks.MockPP)
+	}{
+		"rd": {true, []netip.Addr{ip4}, nil},
+		"no-rd": {false, nil, func(m *mocks.MockPP) {
+			m.EXPECT().Noticef(pp.EmojiImpossible, "Invalid DNS response: response code is %v", dnsmessage.RCodeRefused)
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := protocol.DNS{
+				ProviderName: "secret name",
+				Param: map[ipnet.Type]protocol.DNSParam{
+					ipnet.IP4: {Server: server, Name: "myip.test.", Class: dnsmessage.ClassINET, Type: dnsmessage.TypeA, RecursionDesired: tc.recursionDesired},
+				},
+				Bind: protocol.Binding{}, //nolint:exhaustruct
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ips, ok := provider.GetIPs(context.Background(), mockPP, ipnet.IP4)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
@@ -30,13 +30,17 @@ func randUint16(ppfmt pp.PP) uint16 {
 	return binary.BigEndian.Uint16(buf)
 }
 
-func newDNSQuery(ppfmt pp.PP, id uint16, name string, class dnsmessage.Class) ([]byte, bool) {
+// newDNSQuery packs a DNS query. Recursion should be desired unless the server answers the question itself,
+// as for whoami.cloudflare. in the CHAOS class; otherwise, recursive resolvers might refuse the query.
+func newDNSQuery(ppfmt pp.PP, id uint16, name string, class dnsmessage.Class, qtype dnsmessage.Type,
+	recursionDesired bool,
+) ([]byte, bool) {
 	msg, err := (&dnsmessage.Message{
 		Header: dnsmessage.Header{ //nolint:exhaustruct
 			ID:               id,
 			Response:         false, // query
 			OpCode:           0,     // query
-			RecursionDesired: false, // no, please
+			RecursionDesired: recursionDesired,
 
 			Authoritative:      false, // meaningless for queries
 			Truncated:          false, // meaningless for queries
@@ -46,7 +50,7 @@ func newDNSQuery(ppfmt pp.PP, id uint16, name string, class dnsmessage.Class) ([
 		Questions: []dnsmessage.Question{
 			{
 				Name:  dnsmessage.MustNewName(name),
-				Type:  dnsmessage.TypeTXT,
+				Type:  qtype,
 				Class: class,
 			},
 		},
@@ -63,13 +67,66 @@ func newDNSQuery(ppfmt pp.PP, id uint16, name string, class dnsmessage.Class) ([
 	return msg, true
 }
 
+// describeDNSType gives the usual name of a record type, such as "AAAA".
+func describeDNSType(qtype dnsmessage.Type) string {
+	return strings.TrimPrefix(qtype.String(), "Type")
+}
+
+// isDNSAnswerTo checks whether an answer is for the question. Domain names are compared
+// case-insensitively because resolvers may randomize or change the case of the names.
+func isDNSAnswerTo(ans dnsmessage.Resource, name string, class dnsmessage.Class, qtype dnsmessage.Type) bool {
+	return strings.EqualFold(ans.Header.Name.String(), name) && ans.Header.Type == qtype && ans.Header.Class == class
+}
+
+// parseDNSAddressAnswers reads the IP address in the A or AAAA records.
+func parseDNSAddressAnswers(ppfmt pp.PP, answers []dnsmessage.Resource, name string, class dnsmessage.Class,
+	qtype dnsmessage.Type,
+) (netip.Addr, bool) {
+	var ip netip.Addr
+
+	for _, ans := range answers {
+		if !isDNSAnswerTo(ans, name, class, qtype) {
+			continue
+		}
+
+		var found netip.Addr
+		switch body := ans.Body.(type) {
+		case *dnsmessage.AResource:
+			found = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			found = netip.AddrFrom16(body.AAAA)
+		default:
+			continue
+		}
+
+		if ip.IsValid() && ip != found {
+			ppfmt.Noticef(pp.EmojiImpossible, "Invalid DNS response: more than one %s record", describeDNSType(qtype))
+			return netip.Addr{}, false
+		}
+
+		ip = found
+	}
+
+	if !ip.IsValid() {
+		ppfmt.Noticef(pp.EmojiImpossible, "Invalid DNS response: no %s records", describeDNSType(qtype))
+		return netip.Addr{}, false
+	}
+
+	return ip, true
+}
+
 func parseDNSAnswers(ppfmt pp.PP, answers []dnsmessage.Resource, name string, class dnsmessage.Class,
+	qtype dnsmessage.Type,
 ) (netip.Addr, bool) {
+	if qtype != dnsmessage.TypeTXT {
+		return parseDNSAddressAnswers(ppfmt, answers, name, class, qtype)
+	}
+
 	var invalidIP netip.Addr
 	var ipString string
 
 	for _, ans := range answers {
-		if ans.Header.Name.String() != name || ans.Header.Type != dnsmessage.TypeTXT || ans.Header.Class != class {
+		if !isDNSAnswerTo(ans, name, class, dnsmessage.TypeTXT) {
 			continue
 		}
 
@@ -106,7 +163,8 @@ func parseDNSAnswers(ppfmt pp.PP, answers []dnsmessage.Resource, name string, cl
 	return ip, true
 }
 
-func parseDNSResponse(ppfmt pp.PP, r []byte, id uint16, name string, class dnsmessage.Class) (netip.Addr, bool) {
+func parseDNSResponse(ppfmt pp.PP, r []byte, id uint16, name string, class dnsmessage.Class, qtype dnsmessage.Type,
+) (netip.Addr, bool) {
 	var invalidIP netip.Addr
 
 	var msg dnsmessage.Message
@@ -133,36 +191,39 @@ func parseDNSResponse(ppfmt pp.PP, r []byte, id uint16, name string, class dnsme
 		return invalidIP, false
 	}
 
-	return parseDNSAnswers(ppfmt, msg.Answers, name, class)
+	return parseDNSAnswers(ppfmt, msg.Answers, name, class, qtype)
 }
 
-func getIPFromDNS(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, url string, name string, class dnsmessage.Class,
-) (netip.Addr, bool) {
-	var invalidIP netip.Addr
-
+func getIPsFromDNS(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, b Binding, param DNSOverHTTPSParam,
+) ([]netip.Addr, bool) {
 	// message ID for the DNS payloads
 	id := randUint16(ppfmt)
 
-	q, ok := newDNSQuery(ppfmt, id, name, class)
+	q, ok := newDNSQuery(ppfmt, id, param.Name, param.Class, param.Type, param.RecursionDesired)
 	if !ok {
-		return invalidIP, false
+		return nil, false
 	}
 
 	c := httpCore{
 		ipNet:  ipNet,
-		url:    url,
+		url:    param.URL,
 		method: http.MethodPost,
 		additionalHeaders: map[string]string{
 			"Content-Type": "application/dns-message",
 			"Accept":       "application/dns-message",
 		},
+		options:     HTTPOptions{Bind: b}, //nolint:exhaustruct
 		requestBody: bytes.NewReader(q),
-		extract: func(ppfmt pp.PP, body []byte) (netip.Addr, bool) {
-			return parseDNSResponse(ppfmt, body, id, name, class)
+		extract: func(ppfmt pp.PP, body []byte) ([]netip.Addr, bool) {
+			ip, ok := parseDNSResponse(ppfmt, body, id, param.Name, param.Class, param.Type)
+			if !ok {
+				return nil, false
+			}
+			return []netip.Addr{ip}, true
 		},
 	}
 
-	return c.getIP(ctx, ppfmt)
+	return c.getIPs(ctx, ppfmt)
 }
 
 // DNSOverHTTPSParam is the parameter of a DNS-based IP provider.
@@ -170,12 +231,16 @@ type DNSOverHTTPSParam = struct {
 	URL   string           // the DoH server
 	Name  string           // domain name to query
 	Class dnsmessage.Class // DNS class to query
+	Type  dnsmessage.Type  // DNS type to query (A, AAAA, or TXT)
+
+	RecursionDesired bool // whether to set the RD bit
 }
 
 // DNSOverHTTPS represents a generic detection protocol using DNS over HTTPS.
 type DNSOverHTTPS struct {
 	ProviderName string // name of the protocol
 	Param        map[ipnet.Type]DNSOverHTTPSParam
+	Bind         Binding // the network interface or the source address of the requests
 }
 
 // Name of the detection protocol.
@@ -191,10 +256,10 @@ func (p DNSOverHTTPS) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type)
 		return nil, false
 	}
 
-	ip, ok := getIPFromDNS(ctx, ppfmt, ipNet, param.URL, param.Name, param.Class)
+	ips, ok := getIPsFromDNS(ctx, ppfmt, ipNet, p.Bind, param)
 	if !ok {
 		return nil, false
 	}
 
-	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
 }
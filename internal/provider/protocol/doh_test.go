@@ -545,7 +545,7 @@ func TestDNSOverHTTPSGetIPs(t *testing.T) {
 			provider := &protocol.DNSOverHTTPS{
 				ProviderName: "",
 				Param: map[ipnet.Type]protocol.DNSOverHTTPSParam{
-					tc.urlKey: {server.URL, tc.name, tc.class},
+					tc.urlKey: {server.URL, tc.name, tc.class, dnsmessage.TypeTXT, false},
 				},
 			}
 
@@ -564,3 +564,64 @@ func TestDNSOverHTTPSGetIPs(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSOverHTTPSGetIPsAddress(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("1.2.3.4")
+	ip6 := netip.MustParseAddr("2606:4700:4700::1234")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if !assert.NoError(t, err) {
+			panic(http.ErrAbortHandler)
+		}
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(dnsReply(t, body, dnsmessage.Header{}, //nolint:exhaustruct
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AResource{A: ip4.As4()}),
+			dnsAnswer("myip.test.", dnsmessage.ClassINET, &dnsmessage.AAAAResource{AAAA: ip6.As16()}),
+		))
+	}))
+	t.Cleanup(server.Close)
+
+	for name, tc := range map[string]struct {
+		ipNet         ipnet.Type
+		qtype         dnsmessage.Type
+		expected      netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"a": {ipnet.IP4, dnsmessage.TypeA, ip4, nil},
+		// The stand-in server only listens on IPv4.
+		"aaaa": {
+			ipnet.IP4, dnsmessage.TypeAAAA, netip.Addr{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Detected IP address %s is not a valid IPv4 address", ip6.String())
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := protocol.DNSOverHTTPS{
+				ProviderName: "",
+				Param: map[ipnet.Type]protocol.DNSOverHTTPSParam{
+					tc.ipNet: {server.URL, "myip.test.", dnsmessage.ClassINET, tc.qtype, false},
+				},
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			ips, ok := provider.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.Equal(t, tc.expected.IsValid(), ok)
+			if tc.expected.IsValid() {
+				require.Equal(t, []netip.Addr{tc.expected}, ips)
+			} else {
+				require.Empty(t, ips)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+const (
+	// ExecIPFamilyKey is the environment variable telling the command which IP family
+	// is requested. Its value is "4" or "6".
+	ExecIPFamilyKey = "CLOUDFLARE_DDNS_IP_FAMILY"
+
+	// execWaitDelay bounds the wait for the output pipes after the command is killed,
+	// in case the command started background processes that keep the pipes open.
+	execWaitDelay = time.Second
+)
+
+// execEnvKeys are the environment variables passed to the command. Everything else,
+// in particular the secrets of the updater, is not passed.
+//
+//nolint:gochecknoglobals
+var execEnvKeys = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TZ", "LANG", "LANGUAGE",
+}
+
+// execEnv gives the environment of the command.
+func execEnv(ipNet ipnet.Type) []string {
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		key, _, _ := strings.Cut(kv, "=")
+		return !slices.Contains(execEnvKeys, key) && !strings.HasPrefix(key, "LC_")
+	})
+	return append(env, ExecIPFamilyKey+"="+strconv.Itoa(int(ipNet)))
+}
+
+// parseExecOutput parses the IP addresses separated by spaces, newlines, or commas.
+func parseExecOutput(ppfmt pp.PP, program string, output string) ([]netip.Addr, bool) {
+	fields := strings.FieldsFunc(output, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	if len(fields) == 0 {
+		ppfmt.Noticef(pp.EmojiError, "The command %s did not print any IP addresses", program)
+		return nil, false
+	}
+
+	ips := make([]netip.Addr, 0, len(fields))
+	for _, field := range fields {
+		ip, err := netip.ParseAddr(field)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to parse %q printed by the command %s as an IP address", field, program)
+			return nil, false
+		}
+		ips = append(ips, ip)
+	}
+	return ips, true
+}
+
+// Exec runs a command and reads the IP addresses it prints.
+type Exec struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The program and its arguments.
+	Command []string
+}
+
+// Name of the detection protocol.
+func (p Exec) Name() string {
+	return p.ProviderName
+}
+
+// GetIPs runs the command and parses its standard output. The command is killed
+// when the context is done, and the IP family is passed in [ExecIPFamilyKey].
+func (p Exec) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	program := p.Command[0]
+
+	cmd := exec.CommandContext(ctx, program, p.Command[1:]...)
+	cmd.Env = execEnv(ipNet)
+	cmd.WaitDelay = execWaitDelay
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() != nil:
+			ppfmt.Noticef(pp.EmojiError, "The command %s did not finish in time: %v", program, context.Cause(ctx))
+		case errors.As(err, &exitErr) && len(strings.TrimSpace(string(exitErr.Stderr))) > 0:
+			ppfmt.Noticef(pp.EmojiError, "The command %s exited with code %d: %s",
+				program, exitErr.ExitCode(), strings.TrimSpace(string(exitErr.Stderr)))
+		case errors.As(err, &exitErr):
+			ppfmt.Noticef(pp.EmojiError, "The command %s exited with code %d", program, exitErr.ExitCode())
+		default:
+			ppfmt.Noticef(pp.EmojiError, "Failed to run the command %s: %v", program, err)
+		}
+		return nil, false
+	}
+
+	ips, ok := parseExecOutput(ppfmt, program, string(output))
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
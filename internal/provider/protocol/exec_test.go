@@ -0,0 +1,132 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestExecName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.Exec{
+		ProviderName: "very secret name",
+		Command:      nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+//nolint:paralleltest // environment vars are global
+func TestExecGetIPs(t *testing.T) {
+	t.Setenv("CLOUDFLARE_API_TOKEN", "secret")
+	t.Setenv("POWERDNS_API_KEY", "secret")
+	t.Setenv("IP4_PROVIDER", "url:https://example.com,header=Authorization: Bearer secret")
+	t.Setenv("LC_DDNS_TEST", "1.1.1.1")
+
+	for name, tc := range map[string]struct {
+		script        string
+		ipNet         ipnet.Type
+		timeout       time.Duration
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"one": {
+			"echo 1.1.1.1", ipnet.IP4, time.Second,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1")}, nil,
+		},
+		"many": {
+			"printf '2.2.2.2\\n 1.1.1.1,2.2.2.2\\r\\n'", ipnet.IP4, time.Second,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2.2.2.2")}, nil,
+		},
+		"family": {
+			`test "$CLOUDFLARE_DDNS_IP_FAMILY" = 6 && echo 2001:db8::1`, ipnet.IP6, time.Second,
+			[]netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil,
+		},
+		"environment": {
+			`test -z "$CLOUDFLARE_API_TOKEN$POWERDNS_API_KEY$IP4_PROVIDER" && test -n "$PATH" && echo $LC_DDNS_TEST`, ipnet.IP4, time.Second,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1")}, nil,
+		},
+		"empty": {
+			"echo", ipnet.IP4, time.Second, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The command %s did not print any IP addresses", "/bin/sh")
+			},
+		},
+		"illformed": {
+			"echo 1.1.1.1 hello", ipnet.IP4, time.Second, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to parse %q printed by the command %s as an IP address", "hello", "/bin/sh")
+			},
+		},
+		"mismatched": {
+			"echo 2001:db8::1", ipnet.IP4, time.Second, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Detected IP address %s is not a valid IPv4 address", "2001:db8::1")
+			},
+		},
+		"exit": {
+			"exit 3", ipnet.IP4, time.Second, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The command %s exited with code %d", "/bin/sh", 3)
+			},
+		},
+		"exit-with-message": {
+			"echo 1.1.1.1; echo 'router unreachable' >&2; exit 1", ipnet.IP4, time.Second, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The command %s exited with code %d: %s", "/bin/sh", 1, "router unreachable")
+			},
+		},
+		"timeout": {
+			"sleep 10", ipnet.IP4, 100 * time.Millisecond, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The command %s did not finish in time: %v", "/bin/sh", context.DeadlineExceeded)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			provider := protocol.Exec{
+				ProviderName: "secret name",
+				Command:      []string{"/bin/sh", "-c", tc.script},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+func TestExecGetIPsNotFound(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to run the command %s: %v", "/nonexistent/wan-ip", gomock.Any())
+
+	provider := protocol.Exec{ProviderName: "secret name", Command: []string{"/nonexistent/wan-ip"}}
+	ips, ok := provider.GetIPs(context.Background(), mockPP, ipnet.IP4)
+	require.False(t, ok)
+	require.Empty(t, ips)
+}
@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// File reads the IPs from a file, such as the one written by DHCP or PPP hooks.
+type File struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The absolute path of the file in [file.FS].
+	Path string
+}
+
+// Name of the detection protocol.
+func (p File) Name() string {
+	return p.ProviderName
+}
+
+// GetIPs reads the file again and parses the IPs separated by newlines or commas.
+// Blank entries are ignored.
+func (p File) GetIPs(_ context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	content, ok := file.ReadString(ppfmt, p.Path)
+	if !ok {
+		return nil, false
+	}
+
+	rawIPs := strings.FieldsFunc(content, func(r rune) bool { return r == '\n' || r == ',' })
+	rawIPs = slices.DeleteFunc(rawIPs, func(s string) bool { return strings.TrimSpace(s) == "" })
+	if len(rawIPs) == 0 {
+		ppfmt.Noticef(pp.EmojiError, "The file %q does not contain any IP addresses", p.Path)
+		return nil, false
+	}
+
+	ips, ok := ParseLiteralIPs(ppfmt, "file", rawIPs)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
@@ -0,0 +1,105 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestFileName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.File{
+		ProviderName: "very secret name",
+		Path:         "",
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+//nolint:paralleltest // changing global var file.FS
+func TestFileGetIPs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content       *string
+		ipNet         ipnet.Type
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"one": {
+			new("1.1.1.1\n"), ipnet.IP4,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1")}, nil,
+		},
+		"many": {
+			new(" 2.2.2.2 \r\n\n1.1.1.1, 2.2.2.2,\n"), ipnet.IP4,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2.2.2.2")}, nil,
+		},
+		"ip6": {
+			new("2001:db8::1\n"), ipnet.IP6,
+			[]netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil,
+		},
+		"missing": {
+			nil, ipnet.IP4, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to read %q: %v", "run/wan4", gomock.Any())
+			},
+		},
+		"empty": {
+			new("\n \n"), ipnet.IP4, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The file %q does not contain any IP addresses", "/run/wan4")
+			},
+		},
+		"illformed": {
+			new("1.1.1.1\nhello\n"), ipnet.IP4, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the IP address %q for "%s:"`, "hello", "file")
+			},
+		},
+		"zoned": {
+			new("fe80::1%eth0"), ipnet.IP6, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the IP address %q for "%s:": zoned IP addresses are not allowed`, "fe80::1%eth0", "file")
+			},
+		},
+		"mismatched": {
+			new("1.1.1.1"), ipnet.IP6, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Detected IP address %s is not a valid IPv6 address", "1.1.1.1")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			memfs := fstest.MapFS{}
+			if tc.content != nil {
+				memfs["run/wan4"] = &fstest.MapFile{Data: []byte(*tc.content), Mode: 0o644} //nolint:exhaustruct
+			}
+			file.FS = memfs
+			t.Cleanup(func() { file.FS = os.DirFS("/") })
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			provider := protocol.File{ProviderName: "secret name", Path: "/run/wan4"}
+			ips, ok := provider.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
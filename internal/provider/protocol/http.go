@@ -10,31 +10,34 @@ import (
 	"github.com/favonia/cloudflare-ddns/internal/pp"
 )
 
-func getIPFromHTTP(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, url string) (netip.Addr, bool) {
+func getIPsFromHTTP(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, url string, options HTTPOptions,
+) ([]netip.Addr, bool) {
 	c := httpCore{
 		ipNet:             ipNet,
 		url:               url,
 		method:            http.MethodGet,
 		additionalHeaders: nil,
+		options:           options,
 		requestBody:       nil,
-		extract: func(_ pp.PP, body []byte) (netip.Addr, bool) {
+		extract: func(_ pp.PP, body []byte) ([]netip.Addr, bool) {
 			ipString := strings.TrimSpace(string(body))
 			ip, err := netip.ParseAddr(ipString)
 			if err != nil {
 				ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, ipString)
-				return netip.Addr{}, false
+				return nil, false
 			}
-			return ip, true
+			return []netip.Addr{ip}, true
 		},
 	}
 
-	return c.getIP(ctx, ppfmt)
+	return c.getIPs(ctx, ppfmt)
 }
 
 // HTTP represents a generic detection protocol to use an HTTP response directly.
 type HTTP struct {
 	ProviderName string                // name of the protocol
 	URL          map[ipnet.Type]string // URL of the page for detection
+	Options      HTTPOptions           // settings of the HTTP(S) requests
 }
 
 // Name of the detection protocol.
@@ -50,10 +53,10 @@ func (p HTTP) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]neti
 		return nil, false
 	}
 
-	ip, ok := getIPFromHTTP(ctx, ppfmt, ipNet, url)
+	ips, ok := getIPsFromHTTP(ctx, ppfmt, ipNet, url, p.Options)
 	if !ok {
 		return nil, false
 	}
 
-	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
 }
@@ -4,9 +4,22 @@ package protocol_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/netip"
+	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -167,3 +180,167 @@ func TestHTTPGetIPs(t *testing.T) {
 		})
 	}
 }
+
+func newClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{ //nolint:exhaustruct
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"}, //nolint:exhaustruct
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf //nolint:exhaustruct
+}
+
+func TestHTTPGetIPsWithOptions(t *testing.T) {
+	t.Parallel()
+
+	clientCert, clientLeaf := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientLeaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if r.Header.Get("X-Token") != "secret" || username != "user" || password != "pass:word" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "1.2.3.4")
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{ //nolint:exhaustruct
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	options := protocol.HTTPOptions{
+		Header:    map[string]string{"X-Token": "secret"},
+		BasicAuth: url.UserPassword("user", "pass:word"),
+		TLSConfig: &tls.Config{ //nolint:exhaustruct
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{clientCert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		options       func() protocol.HTTPOptions
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"all": {func() protocol.HTTPOptions { return options }, true, nil},
+		"no-header": {
+			func() protocol.HTTPOptions {
+				o := options
+				o.Header = nil
+				return o
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, server.URL, "")
+			},
+		},
+		"no-client-cert": {
+			func() protocol.HTTPOptions {
+				o := options
+				o.TLSConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12} //nolint:exhaustruct
+				return o
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q: %v", server.URL, gomock.Any())
+			},
+		},
+		"no-ca": {
+			func() protocol.HTTPOptions {
+				o := options
+				o.TLSConfig = nil
+				return o
+			},
+			false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q: %v", server.URL, gomock.Any())
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := &protocol.HTTP{
+				ProviderName: "secret name",
+				URL:          map[ipnet.Type]string{ipnet.IP4: server.URL},
+				Options:      tc.options(),
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ips, ok := provider.GetIPs(ctx, mockPP, ipnet.IP4)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, ips)
+			} else {
+				require.Empty(t, ips)
+			}
+		})
+	}
+}
+
+func TestHTTPGetIPsWithOptionsReusesConnections(t *testing.T) {
+	t.Parallel()
+
+	var newConns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "1.2.3.4")
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	provider := &protocol.HTTP{
+		ProviderName: "secret name",
+		URL:          map[ipnet.Type]string{ipnet.IP4: server.URL},
+		Options: protocol.HTTPOptions{
+			Header:    nil,
+			BasicAuth: nil,
+			TLSConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}, //nolint:exhaustruct
+		},
+	}
+
+	mockPP := mocks.NewMockPP(gomock.NewController(t))
+	for range 3 {
+		ips, ok := provider.GetIPs(context.Background(), mockPP, ipnet.IP4)
+		require.True(t, ok)
+		require.Equal(t, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, ips)
+	}
+	require.Equal(t, int32(1), newConns.Load())
+}
@@ -2,8 +2,10 @@ package protocol
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net/netip"
+	"net/url"
 
 	"github.com/hashicorp/go-retryablehttp"
 
@@ -14,41 +16,58 @@ import (
 // maxReadLength is the maximum number of bytes read from an HTTP response.
 const maxReadLength int64 = 102400
 
+// HTTPOptions are the user-specified settings of the HTTP(S) requests made by a provider.
+type HTTPOptions struct {
+	Header    map[string]string // additional request headers
+	BasicAuth *url.Userinfo     // credentials of HTTP basic authentication; nil if not used
+	TLSConfig *tls.Config       // TLS settings, such as client certificates; nil for the default settings
+	Bind      Binding           // the network interface or the source address of the requests
+}
+
 type httpCore struct {
 	ipNet             ipnet.Type
 	url               string
 	method            string
 	additionalHeaders map[string]string
+	options           HTTPOptions
 	requestBody       io.Reader
-	extract           func(pp.PP, []byte) (netip.Addr, bool)
+	extract           func(pp.PP, []byte) ([]netip.Addr, bool)
 }
 
-func (h httpCore) getIP(ctx context.Context, ppfmt pp.PP) (netip.Addr, bool) {
-	var invalidIP netip.Addr
-
+func (h httpCore) getIPs(ctx context.Context, ppfmt pp.PP) ([]netip.Addr, bool) {
 	req, err := retryablehttp.NewRequestWithContext(ctx, h.method, h.url, h.requestBody)
 	if err != nil {
 		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare HTTP(S) request to %q: %v", h.url, err)
-		return invalidIP, false
+		return nil, false
 	}
 
 	for header, value := range h.additionalHeaders {
 		req.Header.Set(header, value)
 	}
+	for header, value := range h.options.Header {
+		req.Header.Set(header, value)
+	}
+	if h.options.BasicAuth != nil {
+		password, _ := h.options.BasicAuth.Password()
+		req.SetBasicAuth(h.options.BasicAuth.Username(), password)
+	}
 
 	c := SharedRetryableSplitClient(h.ipNet)
+	if h.options.TLSConfig != nil || !h.options.Bind.IsZero() {
+		c = retryableSplitClientWithOptions(h.ipNet, h.options.TLSConfig, h.options.Bind)
+	}
 
 	resp, err := c.Do(req)
 	if err != nil {
 		ppfmt.Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q: %v", h.url, err)
-		return invalidIP, false
+		return nil, false
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReadLength))
 	if err != nil {
 		ppfmt.Noticef(pp.EmojiError, "Failed to read HTTP(S) response from %q: %v", h.url, err)
-		return invalidIP, false
+		return nil, false
 	}
 
 	return h.extract(ppfmt, body)
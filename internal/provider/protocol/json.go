@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// JSONWildcard is the path segment selecting all elements of an array.
+const JSONWildcard = "*"
+
+// selectJSON follows the path from the JSON value. An object key selects the field,
+// a number selects the array element, and [JSONWildcard] selects all array elements.
+func selectJSON(value any, path []string) ([]any, bool) {
+	values := []any{value}
+	for _, segment := range path {
+		var next []any
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]any:
+				field, found := v[segment]
+				if !found {
+					return nil, false
+				}
+				next = append(next, field)
+
+			case []any:
+				if segment == JSONWildcard {
+					next = append(next, v...)
+					continue
+				}
+				i, err := strconv.Atoi(segment)
+				if err != nil || i < 0 || i >= len(v) {
+					return nil, false
+				}
+				next = append(next, v[i])
+
+			default:
+				return nil, false
+			}
+		}
+		values = next
+	}
+	return values, true
+}
+
+// parseJSONIPs reads the IP addresses at the path. If the selected value is an array,
+// all of its elements are read.
+func parseJSONIPs(ppfmt pp.PP, url string, body []byte, path []string) ([]netip.Addr, bool) {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		ppfmt.Noticef(pp.EmojiError, `Failed to parse the JSON response of %q: %v`, url, err)
+		return nil, false
+	}
+
+	selected, ok := selectJSON(value, path)
+	if !ok {
+		ppfmt.Noticef(pp.EmojiError, `Failed to find %q in the JSON response of %q`, strings.Join(path, "."), url)
+		return nil, false
+	}
+
+	var ips []netip.Addr
+	for _, v := range selected {
+		elems, isArray := v.([]any)
+		if !isArray {
+			elems = []any{v}
+		}
+
+		for _, elem := range elems {
+			ipString, isString := elem.(string)
+			if !isString {
+				ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%v)`, url, elem)
+				return nil, false
+			}
+			ip, err := netip.ParseAddr(strings.TrimSpace(ipString))
+			if err != nil {
+				ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, ipString)
+				return nil, false
+			}
+			ips = append(ips, ip)
+		}
+	}
+
+	if len(ips) == 0 {
+		ppfmt.Noticef(pp.EmojiError, `Failed to find any IP addresses at %q in the JSON response of %q`,
+			strings.Join(path, "."), url)
+		return nil, false
+	}
+
+	return ips, true
+}
+
+func getIPsFromJSON(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, param JSONParam, options HTTPOptions,
+) ([]netip.Addr, bool) {
+	c := httpCore{
+		ipNet:  ipNet,
+		url:    param.URL,
+		method: http.MethodGet,
+		additionalHeaders: map[string]string{
+			"Accept": "application/json",
+		},
+		options:     options,
+		requestBody: nil,
+		extract: func(ppfmt pp.PP, body []byte) ([]netip.Addr, bool) {
+			return parseJSONIPs(ppfmt, param.URL, body, param.Path)
+		},
+	}
+
+	return c.getIPs(ctx, ppfmt)
+}
+
+// JSONParam is the type of parameters for the JSON provider for a specific IP network.
+type JSONParam = struct {
+	URL  string   // URL of the JSON document
+	Path []string // path to the IP addresses; empty for the whole document
+}
+
+// JSON represents a generic detection protocol to extract IP addresses from a JSON document.
+type JSON struct {
+	ProviderName string // name of the detection protocol
+	Param        map[ipnet.Type]JSONParam
+	Options      HTTPOptions // settings of the HTTP(S) requests
+}
+
+// Name of the detection protocol.
+func (p JSON) Name() string { return p.ProviderName }
+
+// GetIPs detects the IP addresses by extracting them from the JSON document.
+func (p JSON) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	param, found := p.Param[ipNet]
+	if !found {
+		ppfmt.Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", ipNet.Describe())
+		return nil, false
+	}
+
+	ips, ok := getIPsFromJSON(ctx, ppfmt, ipNet, param, p.Options)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
@@ -0,0 +1,144 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestJSONName(t *testing.T) {
+	t.Parallel()
+
+	p := &protocol.JSON{
+		ProviderName: "very secret name",
+		Param:        nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func TestJSONGetIPs(t *testing.T) {
+	t.Parallel()
+
+	const document = `{
+		"ip": "1.2.3.4",
+		"wan": {"ipv4": "5.6.7.8", "ipv4s": ["4.3.2.1", "1.2.3.4"], "port": 1234, "empty": []},
+		"interfaces": [{"address": "8.7.6.5"}, {"address": "1.2.3.4"}],
+		"bad": "hello"
+	}`
+
+	server := newSplitServer(ipnet.IP4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		fmt.Fprint(w, document)
+	}))
+	t.Cleanup(server.Close)
+	illformed := newSplitServer(ipnet.IP4, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "{")
+	}))
+	t.Cleanup(illformed.Close)
+
+	ip := func(s string) netip.Addr { return netip.MustParseAddr(s) }
+
+	for name, tc := range map[string]struct {
+		url           string
+		path          []string
+		ipNet         ipnet.Type
+		ok            bool
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"top":      {server.URL, []string{"ip"}, ipnet.IP4, true, []netip.Addr{ip("1.2.3.4")}, nil},
+		"nested":   {server.URL, []string{"wan", "ipv4"}, ipnet.IP4, true, []netip.Addr{ip("5.6.7.8")}, nil},
+		"array":    {server.URL, []string{"wan", "ipv4s"}, ipnet.IP4, true, []netip.Addr{ip("1.2.3.4"), ip("4.3.2.1")}, nil},
+		"index":    {server.URL, []string{"wan", "ipv4s", "0"}, ipnet.IP4, true, []netip.Addr{ip("4.3.2.1")}, nil},
+		"wildcard": {server.URL, []string{"interfaces", "*", "address"}, ipnet.IP4, true, []netip.Addr{ip("1.2.3.4"), ip("8.7.6.5")}, nil},
+		"missing": {
+			server.URL, []string{"wan", "ipv6"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find %q in the JSON response of %q`, "wan.ipv6", server.URL)
+			},
+		},
+		"index/out-of-range": {
+			server.URL, []string{"wan", "ipv4s", "2"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find %q in the JSON response of %q`, "wan.ipv4s.2", server.URL)
+			},
+		},
+		"through-string": {
+			server.URL, []string{"ip", "more"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find %q in the JSON response of %q`, "ip.more", server.URL)
+			},
+		},
+		"empty": {
+			server.URL, []string{"wan", "empty"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find any IP addresses at %q in the JSON response of %q`, "wan.empty", server.URL)
+			},
+		},
+		"not-string": {
+			server.URL, []string{"wan", "port"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%v)`, server.URL, float64(1234))
+			},
+		},
+		"not-ip": {
+			server.URL, []string{"bad"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, server.URL, "hello")
+			},
+		},
+		"illformed": {
+			illformed.URL, []string{"ip"}, ipnet.IP4, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the JSON response of %q: %v`, illformed.URL, gomock.Any())
+			},
+		},
+		"wrong-family": {
+			server.URL, []string{"ip"}, ipnet.IP6, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", "IPv6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := &protocol.JSON{
+				ProviderName: "secret name",
+				Param: map[ipnet.Type]protocol.JSONParam{
+					ipnet.IP4: {URL: tc.url, Path: tc.path},
+				},
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
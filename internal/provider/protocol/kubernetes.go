@@ -0,0 +1,277 @@
+package protocol
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// The credentials of the service account mounted into every pod.
+const (
+	KubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	KubernetesCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// maxKubernetesObjectLength is the maximum number of bytes read from the API server.
+// It is much larger than [maxReadLength] because node objects list all the container images
+// on the node and the managed fields, which easily take hundreds of kilobytes.
+const maxKubernetesObjectLength int64 = 16 << 20
+
+// KubernetesObject is the kind of the Kubernetes object holding the addresses.
+type KubernetesObject int
+
+const (
+	// KubernetesService reads the ingress points of a LoadBalancer service.
+	KubernetesService KubernetesObject = iota
+
+	// KubernetesNode reads the ExternalIP addresses of a node, or its InternalIP addresses
+	// if it has no ExternalIP addresses of either IP family.
+	KubernetesNode
+)
+
+// Kubernetes reads the IP addresses from a Kubernetes object via the API server,
+// authenticated as the service account of the pod.
+type Kubernetes struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The URL of the API server. If empty, the in-cluster API server
+	// (KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT) is used.
+	APIServer string
+
+	// The kind of the object.
+	Object KubernetesObject
+
+	// The namespace of the object; empty for cluster-scoped objects such as nodes.
+	Namespace string
+
+	// The name of the object.
+	ObjectName string
+}
+
+// Name of the detection protocol.
+func (p Kubernetes) Name() string {
+	return p.ProviderName
+}
+
+// describe gives the kind and the reference of the object, such as "service default/ingress".
+func (p Kubernetes) describe() string {
+	switch p.Object {
+	case KubernetesService:
+		return "service " + p.Namespace + "/" + p.ObjectName
+	default:
+		return "node " + p.ObjectName
+	}
+}
+
+func (p Kubernetes) path() string {
+	switch p.Object {
+	case KubernetesService:
+		return "/api/v1/namespaces/" + url.PathEscape(p.Namespace) + "/services/" + url.PathEscape(p.ObjectName)
+	default:
+		return "/api/v1/nodes/" + url.PathEscape(p.ObjectName)
+	}
+}
+
+// findKubernetesAPIServer gives the URL of the in-cluster API server.
+func findKubernetesAPIServer(ppfmt pp.PP) (string, bool) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		ppfmt.Noticef(pp.EmojiUserError,
+			"Failed to find the Kubernetes API server because KUBERNETES_SERVICE_HOST or "+
+				"KUBERNETES_SERVICE_PORT is not set; is the updater running in a Kubernetes pod?")
+		return "", false
+	}
+	return "https://" + net.JoinHostPort(host, port), true
+}
+
+// kubernetesClientCache keeps the client for the certificate authority of the cluster,
+// so that the certificate pool and the client are built only when the certificate changes.
+//
+//nolint:gochecknoglobals
+var kubernetesClientCache struct {
+	sync.Mutex
+	caPEM  string
+	client *http.Client
+}
+
+// newKubernetesClient gives an HTTP client trusting the certificate authority of the cluster.
+// The service-account token is sent to the API server directly, without any proxies.
+// The API server is usually reached over IPv4 even when detecting IPv6 addresses.
+func newKubernetesClient(ppfmt pp.PP) (*http.Client, bool) {
+	caPEM, ok := file.ReadString(ppfmt, KubernetesCAFile)
+	if !ok {
+		return nil, false
+	}
+
+	kubernetesClientCache.Lock()
+	defer kubernetesClientCache.Unlock()
+
+	if kubernetesClientCache.client != nil && kubernetesClientCache.caPEM == caPEM {
+		return kubernetesClientCache.client, true
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM([]byte(caPEM)) {
+		ppfmt.Noticef(pp.EmojiError, "Failed to find any PEM certificates in %q", KubernetesCAFile)
+		return nil, false
+	}
+
+	if kubernetesClientCache.client != nil {
+		kubernetesClientCache.client.CloseIdleConnections()
+	}
+	kubernetesClientCache.caPEM = caPEM
+	kubernetesClientCache.client = &http.Client{ //nolint:exhaustruct
+		Transport: &http.Transport{ //nolint:exhaustruct
+			Proxy:               nil,
+			TLSClientConfig:     &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}, //nolint:exhaustruct
+			DialContext:         newControlledDialer(nil).DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+	return kubernetesClientCache.client, true
+}
+
+// getKubernetesObject reads the object from the API server.
+func (p Kubernetes) getKubernetesObject(ctx context.Context, ppfmt pp.PP) ([]byte, bool) {
+	server := p.APIServer
+	if server == "" {
+		var ok bool
+		if server, ok = findKubernetesAPIServer(ppfmt); !ok {
+			return nil, false
+		}
+	}
+
+	token, ok := file.ReadString(ppfmt, KubernetesTokenFile)
+	if !ok {
+		return nil, false
+	}
+
+	client, ok := newKubernetesClient(ppfmt)
+	if !ok {
+		return nil, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+p.path(), nil)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the request to the Kubernetes API server: %v", err)
+		return nil, false
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to send the request to the Kubernetes API server: %v", err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ppfmt.Noticef(pp.EmojiError, "Failed to get the %s from the Kubernetes API server: %s",
+			p.describe(), resp.Status)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKubernetesObjectLength))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to read the %s from the Kubernetes API server: %v", p.describe(), err)
+		return nil, false
+	}
+	return body, true
+}
+
+// parseKubernetesObject extracts the addresses from the object.
+func (p Kubernetes) parseKubernetesObject(body []byte) ([]string, error) {
+	switch p.Object {
+	case KubernetesService:
+		var service struct {
+			Status struct {
+				LoadBalancer struct {
+					Ingress []struct {
+						IP string `json:"ip"`
+					} `json:"ingress"`
+				} `json:"loadBalancer"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(body, &service); err != nil {
+			return nil, err
+		}
+		var ips []string
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			ips = append(ips, ingress.IP)
+		}
+		return ips, nil
+
+	default:
+		var node struct {
+			Status struct {
+				Addresses []struct {
+					Type    string `json:"type"`
+					Address string `json:"address"`
+				} `json:"addresses"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(body, &node); err != nil {
+			return nil, err
+		}
+		var external, internal []string
+		for _, address := range node.Status.Addresses {
+			switch address.Type {
+			case "ExternalIP":
+				external = append(external, address.Address)
+			case "InternalIP":
+				internal = append(internal, address.Address)
+			}
+		}
+		if len(external) > 0 {
+			return external, nil
+		}
+		return internal, nil
+	}
+}
+
+// GetIPs reads the IP addresses from the Kubernetes object.
+func (p Kubernetes) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	body, ok := p.getKubernetesObject(ctx, ppfmt)
+	if !ok {
+		return nil, false
+	}
+
+	rawIPs, err := p.parseKubernetesObject(body)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to parse the %s from the Kubernetes API server: %v", p.describe(), err)
+		return nil, false
+	}
+
+	// Skip the addresses of the other IP family and the unparsable ones,
+	// such as the hostnames of some cloud load balancers.
+	var ips []netip.Addr
+	for _, rawIP := range rawIPs {
+		if ip, err := netip.ParseAddr(rawIP); err == nil && ipNet.Matches(ip) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		ppfmt.Noticef(pp.EmojiError, "The %s does not have any %s addresses", p.describe(), ipNet.Describe())
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
@@ -0,0 +1,226 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestKubernetesName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.Kubernetes{
+		ProviderName: "very secret name",
+		APIServer:    "",
+		Object:       protocol.KubernetesNode,
+		Namespace:    "",
+		ObjectName:   "",
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+// newKubernetesAPIServer mimics the API server with a few services and nodes.
+func newKubernetesAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	objects := map[string]string{
+		"/api/v1/namespaces/default/services/ingress": `{"kind":"Service","status":{"loadBalancer":{"ingress":[
+			{"ip":"203.0.113.1"},{"ip":"2001:db8::1"},{"hostname":"lb.example.com"}]}}}`,
+		"/api/v1/namespaces/default/services/pending": `{"kind":"Service","status":{"loadBalancer":{}}}`,
+		"/api/v1/namespaces/default/services/garbage": `{"kind":`,
+		"/api/v1/nodes/worker-1": `{"kind":"Node","status":{"addresses":[
+			{"type":"Hostname","address":"worker-1"},
+			{"type":"InternalIP","address":"10.0.0.1"},
+			{"type":"InternalIP","address":"2001:db8::1:1"},
+			{"type":"ExternalIP","address":"203.0.113.11"}]}}`,
+		"/api/v1/nodes/worker-3": `{"kind":"Node","status":{"addresses":[
+			{"type":"Hostname","address":"worker-3"},
+			{"type":"InternalIP","address":"10.0.0.3"},
+			{"type":"InternalIP","address":"2001:db8::1:3"}]}}`,
+	}
+
+	// A real node lists the container images on it and the managed fields,
+	// which can be much larger than the usual limit of HTTP responses.
+	objects["/api/v1/nodes/worker-2"] = `{"kind":"Node","metadata":{"managedFields":[{"manager":"` +
+		strings.Repeat("x", 1<<20) + `"}]},"status":{"addresses":[{"type":"ExternalIP","address":"203.0.113.12"}]}}`
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer service-account-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		object, found := objects[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, object)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+//nolint:paralleltest // changing global var file.FS and environment variables
+func TestKubernetesGetIPs(t *testing.T) {
+	server := newKubernetesAPIServer(t)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Headers: nil, Bytes: server.Certificate().Raw})
+	credentials := fstest.MapFS{
+		"var/run/secrets/kubernetes.io/serviceaccount/token":  {Data: []byte("service-account-token\n")}, //nolint:exhaustruct
+		"var/run/secrets/kubernetes.io/serviceaccount/ca.crt": {Data: caPEM},                             //nolint:exhaustruct
+	}
+
+	for name, tc := range map[string]struct {
+		apiServer     string
+		object        protocol.KubernetesObject
+		namespace     string
+		objectName    string
+		ipNet         ipnet.Type
+		fs            fstest.MapFS
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"service/4": {
+			server.URL, protocol.KubernetesService, "default", "ingress", ipnet.IP4, credentials,
+			[]netip.Addr{netip.MustParseAddr("203.0.113.1")}, nil,
+		},
+		"service/6": {
+			server.URL, protocol.KubernetesService, "default", "ingress", ipnet.IP6, credentials,
+			[]netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil,
+		},
+		"service/pending": {
+			server.URL, protocol.KubernetesService, "default", "pending", ipnet.IP4, credentials, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The %s does not have any %s addresses", "service default/pending", "IPv4")
+			},
+		},
+		"service/garbage": {
+			server.URL, protocol.KubernetesService, "default", "garbage", ipnet.IP4, credentials, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to parse the %s from the Kubernetes API server: %v", "service default/garbage", gomock.Any())
+			},
+		},
+		"service/missing": {
+			server.URL, protocol.KubernetesService, "kube-system", "ingress", ipnet.IP4, credentials, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to get the %s from the Kubernetes API server: %s", "service kube-system/ingress", "404 Not Found")
+			},
+		},
+		"node/external": {
+			server.URL, protocol.KubernetesNode, "", "worker-1", ipnet.IP4, credentials,
+			[]netip.Addr{netip.MustParseAddr("203.0.113.11")}, nil,
+		},
+		"node/large": {
+			server.URL, protocol.KubernetesNode, "", "worker-2", ipnet.IP4, credentials,
+			[]netip.Addr{netip.MustParseAddr("203.0.113.12")}, nil,
+		},
+		"node/internal/4": {
+			server.URL, protocol.KubernetesNode, "", "worker-3", ipnet.IP4, credentials,
+			[]netip.Addr{netip.MustParseAddr("10.0.0.3")}, nil,
+		},
+		"node/internal/6": {
+			server.URL, protocol.KubernetesNode, "", "worker-3", ipnet.IP6, credentials,
+			[]netip.Addr{netip.MustParseAddr("2001:db8::1:3")}, nil,
+		},
+		// The node has an ExternalIP address, though only for IPv4; its InternalIP addresses are not used.
+		"node/mixed-family": {
+			server.URL, protocol.KubernetesNode, "", "worker-1", ipnet.IP6, credentials, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The %s does not have any %s addresses", "node worker-1", "IPv6")
+			},
+		},
+		"no-token": {
+			server.URL, protocol.KubernetesNode, "", "worker-1", ipnet.IP4, fstest.MapFS{}, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to read %q: %v", "var/run/secrets/kubernetes.io/serviceaccount/token", gomock.Any())
+			},
+		},
+		"bad-ca": {
+			server.URL, protocol.KubernetesNode, "", "worker-1", ipnet.IP4,
+			fstest.MapFS{
+				"var/run/secrets/kubernetes.io/serviceaccount/token":  credentials["var/run/secrets/kubernetes.io/serviceaccount/token"],
+				"var/run/secrets/kubernetes.io/serviceaccount/ca.crt": {Data: []byte("garbage")}, //nolint:exhaustruct
+			},
+			nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find any PEM certificates in %q", protocol.KubernetesCAFile)
+			},
+		},
+		"in-cluster/missing": {
+			"", protocol.KubernetesNode, "", "worker-1", ipnet.IP4, credentials, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "Failed to find the Kubernetes API server because KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT is not set; is the updater running in a Kubernetes pod?")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			file.FS = tc.fs
+			t.Cleanup(func() { file.FS = os.DirFS("/") })
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			p := protocol.Kubernetes{
+				ProviderName: "",
+				APIServer:    tc.apiServer,
+				Object:       tc.object,
+				Namespace:    tc.namespace,
+				ObjectName:   tc.objectName,
+			}
+			ips, ok := p.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+//nolint:paralleltest // changing global var file.FS and environment variables
+func TestKubernetesInCluster(t *testing.T) {
+	server := newKubernetesAPIServer(t)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Headers: nil, Bytes: server.Certificate().Raw})
+	file.FS = fstest.MapFS{
+		"var/run/secrets/kubernetes.io/serviceaccount/token":  {Data: []byte("service-account-token")}, //nolint:exhaustruct
+		"var/run/secrets/kubernetes.io/serviceaccount/ca.crt": {Data: caPEM},                           //nolint:exhaustruct
+	}
+	t.Cleanup(func() { file.FS = os.DirFS("/") })
+
+	// The certificate of httptest is valid for 127.0.0.1 and ::1.
+	addrPort := netip.MustParseAddrPort(server.Listener.Addr().String())
+	t.Setenv("KUBERNETES_SERVICE_HOST", addrPort.Addr().String())
+	t.Setenv("KUBERNETES_SERVICE_PORT", fmt.Sprint(addrPort.Port()))
+
+	p := protocol.Kubernetes{
+		ProviderName: "",
+		APIServer:    "",
+		Object:       protocol.KubernetesService,
+		Namespace:    "default",
+		ObjectName:   "ingress",
+	}
+	mockPP := mocks.NewMockPP(gomock.NewController(t))
+	ips, ok := p.GetIPs(context.Background(), mockPP, ipnet.IP4)
+	require.True(t, ok)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("203.0.113.1")}, ips)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/netip"
+	"slices"
 
 	"github.com/favonia/cloudflare-ddns/internal/ipnet"
 	"github.com/favonia/cloudflare-ddns/internal/pp"
@@ -17,6 +18,10 @@ type LocalWithInterface struct {
 
 	// The name of the network interface
 	InterfaceName string
+
+	// The policy of selecting addresses. A nonzero policy reads the addresses via netlink,
+	// which also reports whether an address is temporary, tentative, or deprecated.
+	Policy InterfacePolicy
 }
 
 // Name of the detection protocol.
@@ -107,6 +112,16 @@ func (p LocalWithInterface) GetIPs(_ context.Context, ppfmt pp.PP, ipNet ipnet.T
 		return nil, false
 	}
 
+	if !p.Policy.IsZero() {
+		addrs, err := DumpInterfaceAddrs()
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to list addresses of interface %s via netlink: %v", p.InterfaceName, err)
+			return nil, false
+		}
+		addrs = slices.DeleteFunc(addrs, func(a InterfaceAddr) bool { return a.Index != iface.Index })
+		return SelectInterfaceAddrs(ppfmt, p.InterfaceName, ipNet, p.Policy, addrs)
+	}
+
 	addrs, err := iface.Addrs()
 	if err != nil {
 		ppfmt.Noticef(pp.EmojiImpossible, "Failed to list unicast addresses of interface %s: %v", p.InterfaceName, err)
@@ -0,0 +1,251 @@
+package protocol
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/sliceutil"
+)
+
+// Constants from the Linux headers <linux/netlink.h>, <linux/rtnetlink.h>, and <linux/if_addr.h>.
+// They are copied here so that the parser can be compiled and tested on all platforms.
+const (
+	nlmsgHeaderLen   = 16
+	nlmsgNoop        = 0x1
+	nlmsgError       = 0x2
+	nlmsgDone        = 0x3
+	rtmNewAddr       = 20
+	ifAddrMsgLen     = 8
+	rtAttrHeaderLen  = 4
+	ifaAddress       = 1
+	ifaLocal         = 2
+	ifaCacheInfo     = 6
+	ifaFlags         = 8
+	ifaCacheInfoLen  = 16
+	netlinkAlignment = 4
+)
+
+// Address flags from <linux/if_addr.h>.
+const (
+	IfaFlagTemporary  uint32 = 0x01
+	IfaFlagDADFailed  uint32 = 0x08
+	IfaFlagDeprecated uint32 = 0x20
+	IfaFlagTentative  uint32 = 0x40
+)
+
+// LifetimeInfinite is the lifetime of an address that never expires.
+const LifetimeInfinite uint32 = 0xffffffff
+
+// InterfaceAddr is an address assigned to a network interface, as reported by netlink.
+type InterfaceAddr struct {
+	Index             int        // the index of the network interface
+	Addr              netip.Addr // the (local) address
+	Flags             uint32     // the IFA_F_* flags
+	PreferredLifetime uint32     // in seconds; [LifetimeInfinite] means forever
+	ValidLifetime     uint32     // in seconds; [LifetimeInfinite] means forever
+}
+
+// errNetlinkTruncated means the netlink messages ended in the middle of a message or an attribute.
+var errNetlinkTruncated = errors.New("truncated netlink message")
+
+func netlinkAlign(n int) int {
+	return (n + netlinkAlignment - 1) &^ (netlinkAlignment - 1)
+}
+
+// ParseNetlinkAddrs parses the netlink messages in response to an RTM_GETADDR dump request.
+// Netlink messages use the native byte order.
+func ParseNetlinkAddrs(data []byte) ([]InterfaceAddr, error) {
+	var addrs []InterfaceAddr
+	for len(data) > 0 {
+		if len(data) < nlmsgHeaderLen {
+			return nil, errNetlinkTruncated
+		}
+		msgLen := int(binary.NativeEndian.Uint32(data[0:4]))
+		msgType := binary.NativeEndian.Uint16(data[4:6])
+		if msgLen < nlmsgHeaderLen || msgLen > len(data) {
+			return nil, errNetlinkTruncated
+		}
+		body := data[nlmsgHeaderLen:msgLen]
+		data = data[min(netlinkAlign(msgLen), len(data)):]
+
+		switch msgType {
+		case nlmsgDone:
+			return addrs, nil
+		case nlmsgNoop:
+			continue
+		case nlmsgError:
+			if len(body) < 4 {
+				return nil, errNetlinkTruncated
+			}
+			errno := -int32(binary.NativeEndian.Uint32(body[0:4]))
+			if errno == 0 { // an acknowledgment
+				continue
+			}
+			return nil, fmt.Errorf("netlink error: %w", syscall.Errno(errno))
+		case rtmNewAddr:
+			addr, ok, err := ParseIfAddrMsg(body)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// ParseIfAddrMsg parses the body of an RTM_NEWADDR or RTM_DELADDR message.
+// The second return value is false if the message does not contain any address.
+func ParseIfAddrMsg(body []byte) (InterfaceAddr, bool, error) {
+	if len(body) < ifAddrMsgLen {
+		return InterfaceAddr{}, false, errNetlinkTruncated
+	}
+	addr := InterfaceAddr{
+		Index:             int(binary.NativeEndian.Uint32(body[4:8])),
+		Addr:              netip.Addr{},
+		Flags:             uint32(body[2]),
+		PreferredLifetime: LifetimeInfinite,
+		ValidLifetime:     LifetimeInfinite,
+	}
+
+	var address, local netip.Addr
+	attrs := body[ifAddrMsgLen:]
+	for len(attrs) > 0 {
+		if len(attrs) < rtAttrHeaderLen {
+			return InterfaceAddr{}, false, errNetlinkTruncated
+		}
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4])
+		if attrLen < rtAttrHeaderLen || attrLen > len(attrs) {
+			return InterfaceAddr{}, false, errNetlinkTruncated
+		}
+		value := attrs[rtAttrHeaderLen:attrLen]
+		attrs = attrs[min(netlinkAlign(attrLen), len(attrs)):]
+
+		switch attrType {
+		case ifaAddress:
+			address, _ = netip.AddrFromSlice(value)
+		case ifaLocal:
+			local, _ = netip.AddrFromSlice(value)
+		case ifaFlags:
+			// IFA_FLAGS supersedes the 8-bit flags in the header.
+			if len(value) >= 4 {
+				addr.Flags = binary.NativeEndian.Uint32(value[0:4])
+			}
+		case ifaCacheInfo:
+			if len(value) >= ifaCacheInfoLen {
+				addr.PreferredLifetime = binary.NativeEndian.Uint32(value[0:4])
+				addr.ValidLifetime = binary.NativeEndian.Uint32(value[4:8])
+			}
+		}
+	}
+
+	// For point-to-point links, IFA_ADDRESS is the address of the other end
+	// and IFA_LOCAL is our address. Otherwise, only IFA_ADDRESS is reliable.
+	switch {
+	case local.IsValid():
+		addr.Addr = local.Unmap()
+	case address.IsValid():
+		addr.Addr = address.Unmap()
+	default:
+		return InterfaceAddr{}, false, nil
+	}
+	return addr, true, nil
+}
+
+// InterfacePolicy restricts which addresses of a network interface are used.
+// The zero value accepts all global unicast addresses.
+type InterfacePolicy struct {
+	// StableOnly rejects temporary addresses, such as IPv6 privacy addresses.
+	StableOnly bool
+
+	// LongestLifetime keeps only the addresses with the longest preferred lifetime.
+	LongestLifetime bool
+
+	// MaxAddresses, if positive, keeps at most this many addresses,
+	// preferring the ones with longer preferred lifetimes.
+	MaxAddresses int
+}
+
+// IsZero checks whether the policy is the zero value.
+func (p InterfacePolicy) IsZero() bool {
+	return p == InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}
+}
+
+// String returns the policy in the syntax of "local.iface(...)".
+func (p InterfacePolicy) String() string {
+	var parts []string
+	if p.StableOnly {
+		parts = append(parts, "stable")
+	}
+	if p.LongestLifetime {
+		parts = append(parts, "longest")
+	}
+	if p.MaxAddresses > 0 {
+		parts = append(parts, "max="+strconv.Itoa(p.MaxAddresses))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SelectInterfaceAddrs keeps the matching global unicast addresses that are usable
+// (neither tentative nor deprecated) and satisfy the policy, in canonical sorted order.
+func SelectInterfaceAddrs(ppfmt pp.PP, iface string, ipNet ipnet.Type, policy InterfacePolicy,
+	addrs []InterfaceAddr,
+) ([]netip.Addr, bool) {
+	candidates := make([]InterfaceAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if !ipNet.Matches(addr.Addr) || !addr.Addr.IsGlobalUnicast() {
+			continue
+		}
+		// Tentative addresses have not passed duplicate address detection yet
+		// and deprecated addresses are going away; neither should be published.
+		if addr.Flags&(IfaFlagTentative|IfaFlagDADFailed|IfaFlagDeprecated) != 0 || addr.PreferredLifetime == 0 {
+			continue
+		}
+		if policy.StableOnly && addr.Flags&IfaFlagTemporary != 0 {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+
+	if len(candidates) == 0 {
+		if policy.IsZero() {
+			ppfmt.Noticef(pp.EmojiError,
+				"Failed to find any global unicast %s address among usable addresses assigned to interface %s",
+				ipNet.Describe(), iface)
+		} else {
+			ppfmt.Noticef(pp.EmojiError,
+				"Failed to find any global unicast %s address among usable addresses assigned to interface %s "+
+					"that satisfies the policy (%s)",
+				ipNet.Describe(), iface, policy.String())
+		}
+		return nil, false
+	}
+
+	slices.SortStableFunc(candidates, func(a, b InterfaceAddr) int {
+		return cmp.Or(cmp.Compare(b.PreferredLifetime, a.PreferredLifetime), a.Addr.Compare(b.Addr))
+	})
+	if policy.LongestLifetime {
+		longest := candidates[0].PreferredLifetime
+		candidates = slices.DeleteFunc(candidates, func(a InterfaceAddr) bool { return a.PreferredLifetime != longest })
+	}
+	if policy.MaxAddresses > 0 && len(candidates) > policy.MaxAddresses {
+		candidates = candidates[:policy.MaxAddresses]
+	}
+
+	ips := make([]netip.Addr, 0, len(candidates))
+	for _, addr := range candidates {
+		ips = append(ips, addr.Addr)
+	}
+	return sliceutil.SortAndCompact(ips, netip.Addr.Compare), true
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package protocol
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// DumpInterfaceAddrs lists the addresses of all network interfaces via an RTM_GETADDR dump request.
+func DumpInterfaceAddrs() ([]InterfaceAddr, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("RTM_GETADDR: %w", err)
+	}
+	return ParseNetlinkAddrs(data)
+}
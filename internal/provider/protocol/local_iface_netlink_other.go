@@ -0,0 +1,10 @@
+//go:build !linux
+
+package protocol
+
+import "errors"
+
+// DumpInterfaceAddrs is only implemented on Linux because it needs netlink.
+func DumpInterfaceAddrs() ([]InterfaceAddr, error) {
+	return nil, errors.New("netlink is only available on Linux")
+}
@@ -0,0 +1,187 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// netlinkDump is a response to RTM_GETADDR captured on a little-endian machine, with
+// the addresses replaced by documentation addresses. It contains (in order):
+//
+//  1. 192.0.2.10/24 on interface 2 (permanent)
+//  2. 2001:db8::1234/64 on interface 2 (stable; preferred for 14400 seconds)
+//  3. 2001:db8::abcd/64 on interface 2 (temporary; preferred for 3600 seconds)
+//  4. 2001:db8:0:1::1/64 on interface 2 (deprecated)
+//  5. 2001:db8::5/64 on interface 2 (tentative)
+//  6. ::1/128 on interface 1 (permanent)
+//
+// followed by NLMSG_DONE.
+const netlinkDump = "" +
+	"\x44\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00" +
+	"\x02\x18\x80\x00\x02\x00\x00\x00\x08\x00\x01\x00\xc0\x00\x02\x0a" +
+	"\x08\x00\x02\x00\xc0\x00\x02\x0a\x14\x00\x06\x00\xff\xff\xff\xff" +
+	"\xff\xff\xff\xff\x64\x00\x00\x00\xc8\x00\x00\x00\x08\x00\x08\x00" +
+	"\x80\x00\x00\x00\x48\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00" +
+	"\x00\x00\x00\x00\x0a\x40\x00\x00\x02\x00\x00\x00\x14\x00\x01\x00" +
+	"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x34" +
+	"\x14\x00\x06\x00\x40\x38\x00\x00\x80\x51\x01\x00\x64\x00\x00\x00" +
+	"\xc8\x00\x00\x00\x08\x00\x08\x00\x00\x01\x00\x00\x48\x00\x00\x00" +
+	"\x14\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00\x0a\x40\x01\x00" +
+	"\x02\x00\x00\x00\x14\x00\x01\x00\x20\x01\x0d\xb8\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\xab\xcd\x14\x00\x06\x00\x10\x0e\x00\x00" +
+	"\x80\x51\x01\x00\x64\x00\x00\x00\xc8\x00\x00\x00\x08\x00\x08\x00" +
+	"\x01\x01\x00\x00\x48\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00" +
+	"\x00\x00\x00\x00\x0a\x40\x20\x00\x02\x00\x00\x00\x14\x00\x01\x00" +
+	"\x20\x01\x0d\xb8\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01" +
+	"\x14\x00\x06\x00\x00\x00\x00\x00\x58\x02\x00\x00\x64\x00\x00\x00" +
+	"\xc8\x00\x00\x00\x08\x00\x08\x00\x20\x01\x00\x00\x48\x00\x00\x00" +
+	"\x14\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00\x0a\x40\x40\x00" +
+	"\x02\x00\x00\x00\x14\x00\x01\x00\x20\x01\x0d\xb8\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x05\x14\x00\x06\x00\x40\x38\x00\x00" +
+	"\x80\x51\x01\x00\x64\x00\x00\x00\xc8\x00\x00\x00\x08\x00\x08\x00" +
+	"\x40\x00\x00\x00\x48\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00" +
+	"\x00\x00\x00\x00\x0a\x80\x80\xfe\x01\x00\x00\x00\x14\x00\x01\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01" +
+	"\x14\x00\x06\x00\xff\xff\xff\xff\xff\xff\xff\xff\x64\x00\x00\x00" +
+	"\xc8\x00\x00\x00\x08\x00\x08\x00\x80\x00\x00\x00\x14\x00\x00\x00" +
+	"\x03\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+
+// netlinkDumpAddrs are the addresses in [netlinkDump].
+//
+//nolint:gochecknoglobals
+var netlinkDumpAddrs = []protocol.InterfaceAddr{
+	{Index: 2, Addr: netip.MustParseAddr("192.0.2.10"), Flags: 0x80, PreferredLifetime: protocol.LifetimeInfinite, ValidLifetime: protocol.LifetimeInfinite},
+	{Index: 2, Addr: netip.MustParseAddr("2001:db8::1234"), Flags: 0x100, PreferredLifetime: 14400, ValidLifetime: 86400},
+	{Index: 2, Addr: netip.MustParseAddr("2001:db8::abcd"), Flags: 0x101, PreferredLifetime: 3600, ValidLifetime: 86400},
+	{Index: 2, Addr: netip.MustParseAddr("2001:db8:0:1::1"), Flags: 0x120, PreferredLifetime: 0, ValidLifetime: 600},
+	{Index: 2, Addr: netip.MustParseAddr("2001:db8::5"), Flags: 0x40, PreferredLifetime: 14400, ValidLifetime: 86400},
+	{Index: 1, Addr: netip.MustParseAddr("::1"), Flags: 0x80, PreferredLifetime: protocol.LifetimeInfinite, ValidLifetime: protocol.LifetimeInfinite},
+}
+
+func TestParseNetlinkAddrs(t *testing.T) {
+	t.Parallel()
+
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("the fixtures were captured on a little-endian machine")
+	}
+
+	for name, tc := range map[string]struct {
+		input  string
+		output []protocol.InterfaceAddr
+		err    string
+	}{
+		"dump":      {netlinkDump, netlinkDumpAddrs, ""},
+		"empty":     {"", nil, ""},
+		"done-only": {netlinkDump[len(netlinkDump)-20:], nil, ""},
+		"header-flags": {
+			"\x2c\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00" +
+				"\x0a\x40\x01\x00\x03\x00\x00\x00\x14\x00\x01\x00\x20\x01\x0d\xb8" +
+				"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x07",
+			[]protocol.InterfaceAddr{{Index: 3, Addr: netip.MustParseAddr("2001:db8::7"), Flags: 0x01, PreferredLifetime: protocol.LifetimeInfinite, ValidLifetime: protocol.LifetimeInfinite}},
+			"",
+		},
+		"error": {
+			"\x24\x00\x00\x00\x02\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00" +
+				"\xff\xff\xff\xff\x14\x00\x00\x00\x16\x00\x01\x03\x01\x00\x00\x00\x00\x00\x00\x00",
+			nil, "netlink error: operation not permitted",
+		},
+		"truncated/header":    {netlinkDump[:10], nil, "truncated netlink message"},
+		"truncated/message":   {netlinkDump[:0x40], nil, "truncated netlink message"},
+		"truncated/attribute": {"\x1c\x00\x00\x00\x14\x00\x02\x00\x01\x00\x00\x00\x00\x00\x00\x00\x0a\x40\x00\x00\x02\x00\x00\x00\x14\x00\x01\x00", nil, "truncated netlink message"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := protocol.ParseNetlinkAddrs([]byte(tc.input))
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err)
+			}
+			require.Equal(t, tc.output, output)
+		})
+	}
+}
+
+func TestSelectInterfaceAddrs(t *testing.T) {
+	t.Parallel()
+
+	var noIPs []netip.Addr
+	ethAddrs := netlinkDumpAddrs[:5]
+
+	for name, tc := range map[string]struct {
+		ipNet         ipnet.Type
+		policy        protocol.InterfacePolicy
+		ok            bool
+		output        []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"4/default": {
+			ipnet.IP4, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			true, []netip.Addr{netip.MustParseAddr("192.0.2.10")}, nil,
+		},
+		"6/default": {
+			ipnet.IP6, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			true, []netip.Addr{netip.MustParseAddr("2001:db8::1234"), netip.MustParseAddr("2001:db8::abcd")}, nil,
+		},
+		"6/stable": {
+			ipnet.IP6, protocol.InterfacePolicy{StableOnly: true, LongestLifetime: false, MaxAddresses: 0},
+			true, []netip.Addr{netip.MustParseAddr("2001:db8::1234")}, nil,
+		},
+		"6/longest": {
+			ipnet.IP6, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: true, MaxAddresses: 0},
+			true, []netip.Addr{netip.MustParseAddr("2001:db8::1234")}, nil,
+		},
+		"6/max=1": {
+			ipnet.IP6, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 1},
+			true, []netip.Addr{netip.MustParseAddr("2001:db8::1234")}, nil,
+		},
+		"6/max=5": {
+			ipnet.IP6, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 5},
+			true, []netip.Addr{netip.MustParseAddr("2001:db8::1234"), netip.MustParseAddr("2001:db8::abcd")}, nil,
+		},
+		"4/none": {
+			ipnet.IP4, protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
+			false, noIPs,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find any global unicast %s address among usable addresses assigned to interface %s", "IPv4", "iface")
+			},
+		},
+		"4/none/policy": {
+			ipnet.IP4, protocol.InterfacePolicy{StableOnly: true, LongestLifetime: true, MaxAddresses: 2},
+			false, noIPs,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find any global unicast %s address among usable addresses assigned to interface %s that satisfies the policy (%s)", "IPv4", "iface", "stable,longest,max=2")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			addrs := ethAddrs
+			if !tc.ok {
+				addrs = netlinkDumpAddrs[5:]
+			}
+			output, ok := protocol.SelectInterfaceAddrs(mockPP, "iface", tc.ipNet, tc.policy, addrs)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.output, output)
+		})
+	}
+}
@@ -24,6 +24,7 @@ func TestLocalWithInterfaceName(t *testing.T) {
 	p := &protocol.LocalWithInterface{
 		ProviderName:  "very secret name",
 		InterfaceName: "lo",
+		Policy:        protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0},
 	}
 
 	require.Equal(t, "very secret name", p.Name())
@@ -290,27 +291,35 @@ func TestLocalWithInterfaceGetIPs(t *testing.T) {
 
 	for name, tc := range map[string]struct {
 		interfaceName string
+		policy        protocol.InterfacePolicy
 		ipNet         ipnet.Type
 		ok            bool
 		expected      []netip.Addr
 		prepareMockPP func(*mocks.MockPP)
 	}{
 		"lo/4": {
-			"lo", ipnet.IP4, false,
+			"lo", protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}, ipnet.IP4, false,
 			nil,
 			func(ppfmt *mocks.MockPP) {
 				ppfmt.EXPECT().Noticef(pp.EmojiError, "Failed to find any global unicast %s address among unicast addresses assigned to interface %s", "IPv4", "lo")
 			},
 		},
 		"lo/6": {
-			"lo", ipnet.IP6, false,
+			"lo", protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}, ipnet.IP6, false,
 			nil,
 			func(ppfmt *mocks.MockPP) {
 				ppfmt.EXPECT().Noticef(pp.EmojiError, "Failed to find any global unicast %s address among unicast addresses assigned to interface %s", "IPv6", "lo")
 			},
 		},
+		"lo/6/netlink": {
+			"lo", protocol.InterfacePolicy{StableOnly: true, LongestLifetime: false, MaxAddresses: 1}, ipnet.IP6, false,
+			nil,
+			func(ppfmt *mocks.MockPP) {
+				ppfmt.EXPECT().Noticef(pp.EmojiError, "Failed to find any global unicast %s address among usable addresses assigned to interface %s that satisfies the policy (%s)", "IPv6", "lo", "stable,max=1")
+			},
+		},
 		"non-existent": {
-			"non-existent-iface", ipnet.IP4, false,
+			"non-existent-iface", protocol.InterfacePolicy{StableOnly: false, LongestLifetime: false, MaxAddresses: 0}, ipnet.IP4, false,
 			nil,
 			func(ppfmt *mocks.MockPP) {
 				ppfmt.EXPECT().Noticef(pp.EmojiUserError, "Failed to find an interface named %q: %v", "non-existent-iface", gomock.Any())
@@ -329,6 +338,7 @@ func TestLocalWithInterfaceGetIPs(t *testing.T) {
 			provider := &protocol.LocalWithInterface{
 				ProviderName:  "",
 				InterfaceName: tc.interfaceName,
+				Policy:        tc.policy,
 			}
 			ips, ok := provider.GetIPs(context.Background(), mockPP, tc.ipNet)
 			require.Equal(t, tc.ok, ok)
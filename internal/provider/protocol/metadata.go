@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// The metadata services of cloud platforms are only reachable from within the instances,
+// usually via the IPv4 link-local address 169.254.169.254. Therefore, the requests are not
+// restricted to the IP family being detected, and proxies are never used.
+//
+//nolint:gochecknoglobals
+var sharedMetadataClient = &http.Client{ //nolint:exhaustruct
+	Transport: &http.Transport{ //nolint:exhaustruct
+		Proxy:               nil,
+		DialContext:         newControlledDialer(nil).DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// MetadataFormat is the format of the response of a metadata service.
+type MetadataFormat int
+
+const (
+	// MetadataText means the response contains IP addresses separated by whitespace.
+	MetadataText MetadataFormat = iota
+
+	// MetadataOpenStackNetworks means the response is network_data.json of OpenStack,
+	// and the addresses are the "ip_address" fields of the networks.
+	MetadataOpenStackNetworks
+)
+
+// MetadataToken describes the token handshake of a metadata service, such as AWS IMDSv2.
+type MetadataToken struct {
+	URL    string            // the URL to PUT for a session token
+	Header map[string]string // the headers of the token request
+	Name   string            // the header carrying the token in the following requests
+}
+
+// MetadataParam is the location of the addresses of an IP family in a metadata service.
+type MetadataParam struct {
+	URL    string         // the URL of the addresses
+	Format MetadataFormat // the format of the response
+}
+
+// Metadata represents a detection protocol reading the instance metadata service of a cloud platform.
+type Metadata struct {
+	ProviderName string                       // name of the protocol
+	Token        *MetadataToken               // the token handshake; nil if not used
+	Header       map[string]string            // the headers of all requests, such as "Metadata-Flavor: Google"
+	Param        map[ipnet.Type]MetadataParam // the locations of the addresses
+}
+
+// Name of the detection protocol.
+func (p Metadata) Name() string {
+	return p.ProviderName
+}
+
+// fetchMetadata sends a request to the metadata service and reads the response.
+func fetchMetadata(ctx context.Context, ppfmt pp.PP, method, url string, header map[string]string) ([]byte, bool) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the request to the metadata service at %q: %v", url, err)
+		return nil, false
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := sharedMetadataClient.Do(req)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to send the request to the metadata service at %q: %v", url, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ppfmt.Noticef(pp.EmojiError, "The metadata service at %q responded with %s", url, resp.Status)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReadLength))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to read the response of the metadata service at %q: %v", url, err)
+		return nil, false
+	}
+	return body, true
+}
+
+// parseMetadata extracts the IP addresses of the IP family from the response.
+func parseMetadata(ppfmt pp.PP, ipNet ipnet.Type, url string, format MetadataFormat, body []byte,
+) ([]netip.Addr, bool) {
+	var rawIPs []string
+	switch format {
+	case MetadataText:
+		rawIPs = strings.Fields(string(body))
+	case MetadataOpenStackNetworks:
+		var data struct {
+			Networks []struct {
+				IPAddress string `json:"ip_address"`
+			} `json:"networks"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			ppfmt.Noticef(pp.EmojiError, "Failed to parse the JSON response of %q: %v", url, err)
+			return nil, false
+		}
+		for _, network := range data.Networks {
+			if ip, err := netip.ParseAddr(network.IPAddress); err == nil && ipNet.Matches(ip) {
+				rawIPs = append(rawIPs, network.IPAddress)
+			}
+		}
+	default:
+		ppfmt.Noticef(pp.EmojiImpossible, "Unknown format of the metadata: %d", format)
+		return nil, false
+	}
+
+	ips := make([]netip.Addr, 0, len(rawIPs))
+	for _, rawIP := range rawIPs {
+		ip, err := netip.ParseAddr(rawIP)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, rawIP)
+			return nil, false
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		ppfmt.Noticef(pp.EmojiError, "Failed to find any %s addresses in the response of %q", ipNet.Describe(), url)
+		return nil, false
+	}
+	return ips, true
+}
+
+// GetIPs reads the IP addresses from the metadata service.
+func (p Metadata) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	param, found := p.Param[ipNet]
+	if !found {
+		ppfmt.Noticef(pp.EmojiUserError, "%s cannot detect %s addresses; use another provider",
+			p.ProviderName, ipNet.Describe())
+		return nil, false
+	}
+
+	header := maps.Clone(p.Header)
+	if p.Token != nil {
+		token, ok := fetchMetadata(ctx, ppfmt, http.MethodPut, p.Token.URL, p.Token.Header)
+		if !ok {
+			return nil, false
+		}
+		if header == nil {
+			header = map[string]string{}
+		}
+		header[p.Token.Name] = strings.TrimSpace(string(token))
+	}
+
+	body, ok := fetchMetadata(ctx, ppfmt, http.MethodGet, param.URL, header)
+	if !ok {
+		return nil, false
+	}
+
+	ips, ok := parseMetadata(ppfmt, ipNet, param.URL, param.Format, body)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
@@ -0,0 +1,143 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestMetadataName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.Metadata{
+		ProviderName: "very secret name",
+		Token:        nil,
+		Header:       nil,
+		Param:        nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func TestMetadataGetIPs(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /token", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, " secret\n") })
+	mux.HandleFunc("PUT /no-token", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusForbidden) })
+	mux.HandleFunc("GET /ip", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "1.1.1.1\n2.2.2.2\n")
+	})
+	mux.HandleFunc("GET /empty", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("GET /garbage", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "not-an-ip") })
+	mux.HandleFunc("GET /networks", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"networks":[{"ip_address":"10.0.0.1"},{"ip_address":"2001:db8::1"},{"type":"ipv6_slaac"}]}`)
+	})
+	mux.HandleFunc("GET /bad-json", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, `{`) })
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	token := func(path string) *protocol.MetadataToken {
+		return &protocol.MetadataToken{URL: server.URL + path, Header: nil, Name: "X-Token"}
+	}
+
+	for name, tc := range map[string]struct {
+		token         *protocol.MetadataToken
+		paramNet      ipnet.Type
+		ipNet         ipnet.Type
+		path          string
+		format        protocol.MetadataFormat
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"token": {
+			token("/token"), ipnet.IP4, ipnet.IP4, "/ip", protocol.MetadataText,
+			[]netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2.2.2.2")}, nil,
+		},
+		"token/failed": {
+			token("/no-token"), ipnet.IP4, ipnet.IP4, "/ip", protocol.MetadataText, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The metadata service at %q responded with %s", server.URL+"/no-token", "403 Forbidden")
+			},
+		},
+		"no-token": {
+			nil, ipnet.IP4, ipnet.IP4, "/ip", protocol.MetadataText, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The metadata service at %q responded with %s", server.URL+"/ip", "401 Unauthorized")
+			},
+		},
+		"unhandled": {
+			nil, ipnet.IP4, ipnet.IP6, "/ip", protocol.MetadataText, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "%s cannot detect %s addresses; use another provider", "cloud.test", "IPv6")
+			},
+		},
+		"empty": {
+			nil, ipnet.IP4, ipnet.IP4, "/empty", protocol.MetadataText, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find any %s addresses in the response of %q", "IPv4", server.URL+"/empty")
+			},
+		},
+		"garbage": {
+			nil, ipnet.IP4, ipnet.IP4, "/garbage", protocol.MetadataText, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, server.URL+"/garbage", "not-an-ip")
+			},
+		},
+		"networks/6": {
+			nil, ipnet.IP6, ipnet.IP6, "/networks", protocol.MetadataOpenStackNetworks,
+			[]netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil,
+		},
+		"networks/bad-json": {
+			nil, ipnet.IP6, ipnet.IP6, "/bad-json", protocol.MetadataOpenStackNetworks, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to parse the JSON response of %q: %v", server.URL+"/bad-json", gomock.Any())
+			},
+		},
+		"unknown-format": {
+			nil, ipnet.IP4, ipnet.IP4, "/empty", protocol.MetadataFormat(100), nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Unknown format of the metadata: %d", protocol.MetadataFormat(100))
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			p := protocol.Metadata{
+				ProviderName: "cloud.test",
+				Token:        tc.token,
+				Header:       nil,
+				Param: map[ipnet.Type]protocol.MetadataParam{
+					tc.paramNet: {URL: server.URL + tc.path, Format: tc.format},
+				},
+			}
+
+			ips, ok := p.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
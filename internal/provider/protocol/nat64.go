@@ -0,0 +1,207 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+const (
+	// NAT64DiscoveryName is the well-known name to discover the NAT64 prefix (RFC 7050).
+	NAT64DiscoveryName = "ipv4only.arpa"
+
+	// NAT64TraceURL is the default Cloudflare trace page reached through NAT64.
+	// Its host must be an IPv4 address so that it can be embedded into the NAT64 prefix.
+	NAT64TraceURL = "https://1.1.1.1/cdn-cgi/trace"
+)
+
+var errNotIPv4Host = errors.New("not an IPv4 address")
+
+// nat64WellKnownIPs are the IPv4 addresses of ipv4only.arpa (RFC 7050).
+//
+//nolint:gochecknoglobals
+var nat64WellKnownIPs = []netip.Addr{
+	netip.AddrFrom4([4]byte{192, 0, 0, 170}),
+	netip.AddrFrom4([4]byte{192, 0, 0, 171}),
+}
+
+// nat64PrefixLengths are the prefix lengths allowed by RFC 6052.
+//
+//nolint:gochecknoglobals
+var nat64PrefixLengths = []int{96, 64, 56, 48, 40, 32}
+
+// nat64Positions gives the positions of the four bytes of an IPv4 address embedded
+// into an IPv6 address with a NAT64 prefix of the given length. Bits 64 to 71 are
+// reserved and skipped (RFC 6052, Section 2.2).
+func nat64Positions(bits int) [4]int {
+	var positions [4]int
+	i := bits / 8
+	for j := range positions {
+		if i == 8 {
+			i++
+		}
+		positions[j] = i
+		i++
+	}
+	return positions
+}
+
+// extractNAT64IPv4 extracts the IPv4 address embedded into an IPv6 address
+// as if the NAT64 prefix had the given length.
+func extractNAT64IPv4(ip netip.Addr, bits int) netip.Addr {
+	bytes6 := ip.As16()
+	var bytes4 [4]byte
+	for j, i := range nat64Positions(bits) {
+		bytes4[j] = bytes6[i]
+	}
+	return netip.AddrFrom4(bytes4)
+}
+
+// SynthesizeNAT64 embeds an IPv4 address into the NAT64 prefix (RFC 6052).
+func SynthesizeNAT64(prefix netip.Prefix, ip netip.Addr) netip.Addr {
+	bytes6 := prefix.Masked().Addr().As16()
+	bytes4 := ip.As4()
+	for j, i := range nat64Positions(prefix.Bits()) {
+		bytes6[i] = bytes4[j]
+	}
+	return netip.AddrFrom16(bytes6)
+}
+
+// findNAT64Prefix finds the NAT64 prefix from the AAAA records of ipv4only.arpa.
+func findNAT64Prefix(ips []netip.Addr) (netip.Prefix, bool) {
+	for _, ip := range ips {
+		if !ip.Is6() || ip.Is4In6() {
+			continue
+		}
+		for _, bits := range nat64PrefixLengths {
+			for _, wellKnown := range nat64WellKnownIPs {
+				if extractNAT64IPv4(ip, bits) == wellKnown {
+					return netip.PrefixFrom(ip, bits).Masked(), true
+				}
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// NAT64Resolver looks up the IP addresses of a host. [net.Resolver] implements it.
+type NAT64Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// NAT64 detects the public IPv4 address of the NAT64 gateway on an IPv6-only network.
+// It discovers the NAT64 prefix via ipv4only.arpa (RFC 7050) and then reads
+// a Cloudflare trace page through the synthesized IPv6 address.
+type NAT64 struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The resolver to look up ipv4only.arpa; nil means the system resolver,
+	// which must be the DNS64 server of the network.
+	Resolver NAT64Resolver
+
+	// The URL of the Cloudflare trace page, whose host must be an IPv4 address.
+	URL string
+}
+
+// Name of the detection protocol.
+func (p NAT64) Name() string {
+	return p.ProviderName
+}
+
+// discoverPrefix discovers the NAT64 prefix via ipv4only.arpa.
+func (p NAT64) discoverPrefix(ctx context.Context, ppfmt pp.PP) (netip.Prefix, bool) {
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ips, err := resolver.LookupNetIP(ctx, "ip6", NAT64DiscoveryName)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to look up %q to discover the NAT64 prefix: %v", NAT64DiscoveryName, err)
+		return netip.Prefix{}, false
+	}
+
+	prefix, ok := findNAT64Prefix(ips)
+	if !ok {
+		ppfmt.Noticef(pp.EmojiError, "Failed to find the NAT64 prefix in the AAAA records of %q (%s)",
+			NAT64DiscoveryName, pp.JoinMap(netip.Addr.String, ips))
+		return netip.Prefix{}, false
+	}
+
+	return prefix, true
+}
+
+// newNAT64Client creates an HTTP client that reaches IPv4 hosts through the NAT64 prefix.
+func newNAT64Client(prefix netip.Prefix) *http.Client {
+	dialer := splitDialer(ipnet.IP6, Binding{}) //nolint:exhaustruct
+
+	return &http.Client{ //nolint:exhaustruct
+		Transport: &http.Transport{ //nolint:exhaustruct
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				ip, err := netip.ParseAddr(host)
+				if err != nil || !ip.Is4() {
+					return nil, fmt.Errorf("%w: %q", errNotIPv4Host, host)
+				}
+				return dialer.DialContext(ctx, "tcp6", net.JoinHostPort(SynthesizeNAT64(prefix, ip).String(), port))
+			},
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// GetIPs detects the public IPv4 address of the NAT64 gateway.
+func (p NAT64) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	if ipNet != ipnet.IP4 {
+		ppfmt.Noticef(pp.EmojiUserError, "NAT64 cannot detect %s addresses; use another provider", ipNet.Describe())
+		return nil, false
+	}
+
+	prefix, ok := p.discoverPrefix(ctx, ppfmt)
+	if !ok {
+		return nil, false
+	}
+
+	client := newNAT64Client(prefix)
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare HTTP(S) request to %q: %v", p.URL, err)
+		return nil, false
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q through the NAT64 prefix %s: %v",
+			p.URL, prefix, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReadLength))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to read HTTP(S) response from %q: %v", p.URL, err)
+		return nil, false
+	}
+
+	ips, ok := extractCloudflareTraceIPs(ppfmt, p.URL, body)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
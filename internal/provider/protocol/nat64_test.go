@@ -0,0 +1,161 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// stubResolver answers every lookup with fixed addresses.
+type stubResolver struct {
+	ips []netip.Addr
+	err error
+}
+
+func (r stubResolver) LookupNetIP(_ context.Context, network, host string) ([]netip.Addr, error) {
+	if network != "ip6" || host != protocol.NAT64DiscoveryName {
+		return nil, fmt.Errorf("unexpected lookup of %s %q", network, host)
+	}
+	return r.ips, r.err
+}
+
+func TestNAT64Name(t *testing.T) {
+	t.Parallel()
+
+	p := &protocol.NAT64{
+		ProviderName: "very secret name",
+		Resolver:     nil,
+		URL:          "",
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func TestSynthesizeNAT64(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("192.0.2.33")
+
+	// The examples in RFC 6052, Section 2.4.
+	for prefix, expected := range map[string]string{
+		"2001:db8::/32":         "2001:db8:c000:221::",
+		"2001:db8:100::/40":     "2001:db8:1c0:2:21::",
+		"2001:db8:122::/48":     "2001:db8:122:c000:2:2100::",
+		"2001:db8:122:300::/56": "2001:db8:122:3c0:0:221::",
+		"2001:db8:122:344::/64": "2001:db8:122:344:c0:2:2100:0",
+		"2001:db8:122:344::/96": "2001:db8:122:344::192.0.2.33",
+		"64:ff9b::/96":          "64:ff9b::192.0.2.33",
+	} {
+		t.Run(prefix, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, netip.MustParseAddr(expected), protocol.SynthesizeNAT64(netip.MustParsePrefix(prefix), ip4))
+		})
+	}
+}
+
+func TestNAT64GetIPs(t *testing.T) {
+	t.Parallel()
+
+	server := newSplitServer(ipnet.IP6, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cdn-cgi/trace" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "fl=1f1\nip=198.51.100.7\ncolo=SJC\nloc=US\nwarp=off\ngateway=off\n")
+	}))
+	t.Cleanup(server.Close)
+
+	// With the prefix ::/96, the IPv4 address 0.0.0.1 is synthesized into ::1,
+	// where the test server listens.
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	traceURL := "http://0.0.0.1:" + serverURL.Port() + "/cdn-cgi/trace"
+
+	errLookup := errors.New("no DNS64")
+
+	for name, tc := range map[string]struct {
+		ipNet         ipnet.Type
+		resolver      protocol.NAT64Resolver
+		url           string
+		ok            bool
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"96": {
+			ipnet.IP4, stubResolver{[]netip.Addr{netip.MustParseAddr("::192.0.0.170"), netip.MustParseAddr("::192.0.0.171")}, nil},
+			traceURL, true, []netip.Addr{netip.MustParseAddr("198.51.100.7")}, nil,
+		},
+		"96/skip-unrelated": {
+			ipnet.IP4, stubResolver{[]netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::192.0.0.171")}, nil},
+			traceURL, true, []netip.Addr{netip.MustParseAddr("198.51.100.7")}, nil,
+		},
+		"ip6": {
+			ipnet.IP6, nil, traceURL, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "NAT64 cannot detect %s addresses; use another provider", "IPv6")
+			},
+		},
+		"lookup-fail": {
+			ipnet.IP4, stubResolver{nil, errLookup}, traceURL, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to look up %q to discover the NAT64 prefix: %v", "ipv4only.arpa", errLookup)
+			},
+		},
+		"no-prefix": {
+			ipnet.IP4, stubResolver{[]netip.Addr{netip.MustParseAddr("2001:db8::1")}, nil}, traceURL, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find the NAT64 prefix in the AAAA records of %q (%s)", "ipv4only.arpa", "2001:db8::1")
+			},
+		},
+		"non-ip4-host": {
+			ipnet.IP4, stubResolver{[]netip.Addr{netip.MustParseAddr("::192.0.0.170")}, nil}, "http://localhost/cdn-cgi/trace", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to send HTTP(S) request to %q through the NAT64 prefix %s: %v", "http://localhost/cdn-cgi/trace", netip.MustParsePrefix("::/96"), gomock.Any())
+			},
+		},
+		"illformed-response": {
+			ipnet.IP4, stubResolver{[]netip.Addr{netip.MustParseAddr("::192.0.0.170")}, nil}, "http://0.0.0.1:" + serverURL.Port() + "/", false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, "http://0.0.0.1:"+serverURL.Port()+"/", []byte{})
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			p := protocol.NAT64{
+				ProviderName: "secret name",
+				Resolver:     tc.resolver,
+				URL:          tc.url,
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ips, ok := p.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io/fs"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// This file implements the external address request of NAT-PMP (RFC 6886).
+// No port mappings are requested.
+
+const (
+	// NATPMPPort is the port of NAT-PMP servers.
+	NATPMPPort = 5351
+
+	natpmpVersion                 byte = 0
+	natpmpOpExternalAddress       byte = 0
+	natpmpOpExternalAddressResult byte = 128
+	natpmpResultLength                 = 12
+
+	// natpmpInitialRTO and natpmpMaxTransmissions are the retransmission parameters
+	// required by RFC 6886. The retransmission timeout doubles after each attempt.
+	natpmpInitialRTO       = 250 * time.Millisecond
+	natpmpMaxTransmissions = 9
+
+	// routeTablePath is the Linux IPv4 routing table, relative to the root of [file.FS].
+	routeTablePath = "proc/net/route"
+
+	// rtfUp and rtfGateway are the route flags in the Linux routing table.
+	rtfUp      = 0x0001
+	rtfGateway = 0x0002
+)
+
+func describeNATPMPResultCode(code uint16) string {
+	switch code {
+	case 1:
+		return "unsupported version"
+	case 2:
+		return "not authorized or refused"
+	case 3:
+		return "network failure"
+	case 4:
+		return "out of resources"
+	case 5:
+		return "unsupported opcode"
+	default:
+		return "unknown error " + strconv.Itoa(int(code))
+	}
+}
+
+func isNATPMPExternalAddressResult(msg []byte) bool {
+	return len(msg) >= 2 && msg[0] == natpmpVersion && msg[1] == natpmpOpExternalAddressResult
+}
+
+func parseNATPMPResponse(ppfmt pp.PP, gateway string, msg []byte) (netip.Addr, bool) {
+	if len(msg) < 4 {
+		ppfmt.Noticef(pp.EmojiError, "Invalid NAT-PMP response from %s: wrong length %d", gateway, len(msg))
+		return netip.Addr{}, false
+	}
+
+	if code := binary.BigEndian.Uint16(msg[2:4]); code != 0 {
+		ppfmt.Noticef(pp.EmojiError, "The NAT-PMP gateway %s failed to report its external address: %s",
+			gateway, describeNATPMPResultCode(code))
+		return netip.Addr{}, false
+	}
+
+	if len(msg) != natpmpResultLength {
+		ppfmt.Noticef(pp.EmojiError, "Invalid NAT-PMP response from %s: wrong length %d", gateway, len(msg))
+		return netip.Addr{}, false
+	}
+
+	// msg[4:8] is the number of seconds since the gateway started.
+	return netip.AddrFrom4([4]byte(msg[8:12])), true
+}
+
+// FindDefaultGatewayIP4 finds the IPv4 default gateway with the lowest metric
+// in the content of the Linux routing table /proc/net/route.
+func FindDefaultGatewayIP4(ppfmt pp.PP, routeTable string) (netip.Addr, bool) {
+	var (
+		gateway    netip.Addr
+		bestMetric uint64
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(routeTable))
+	scanner.Scan() // skip the header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+
+		rawGateway, errGateway := strconv.ParseUint(fields[2], 16, 32)
+		flags, errFlags := strconv.ParseUint(fields[3], 16, 16)
+		metric, errMetric := strconv.ParseUint(fields[6], 10, 32)
+		if errGateway != nil || errFlags != nil || errMetric != nil {
+			ppfmt.Noticef(pp.EmojiImpossible, "Failed to parse the route %q in the routing table", scanner.Text())
+			return netip.Addr{}, false
+		}
+		if flags&(rtfUp|rtfGateway) != rtfUp|rtfGateway {
+			continue
+		}
+
+		if !gateway.IsValid() || metric < bestMetric {
+			// The kernel prints the address in network byte order as a native integer.
+			var ip [4]byte
+			binary.NativeEndian.PutUint32(ip[:], uint32(rawGateway))
+			gateway, bestMetric = netip.AddrFrom4(ip), metric
+		}
+	}
+
+	if !gateway.IsValid() {
+		ppfmt.Noticef(pp.EmojiError, "Failed to find the default IPv4 gateway in the routing table")
+		return netip.Addr{}, false
+	}
+
+	return gateway, true
+}
+
+func findDefaultGateway(ppfmt pp.PP) (string, bool) {
+	routeTable, err := fs.ReadFile(file.FS, routeTablePath)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to read the routing table to find the default gateway: %v", err)
+		return "", false
+	}
+
+	ip, ok := FindDefaultGatewayIP4(ppfmt, string(routeTable))
+	if !ok {
+		return "", false
+	}
+
+	return net.JoinHostPort(ip.String(), strconv.Itoa(NATPMPPort)), true
+}
+
+func getIPFromNATPMP(ctx context.Context, ppfmt pp.PP, gateway string) (netip.Addr, bool) {
+	conn, err := sharedSplitDialer[ipnet.IP4].DialContext(ctx, "udp", gateway)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to connect to the NAT-PMP gateway %s: %v", gateway, err)
+		return netip.Addr{}, false
+	}
+	defer conn.Close()
+
+	resp, err := exchangeUDP(ctx, conn, []byte{natpmpVersion, natpmpOpExternalAddress},
+		natpmpInitialRTO, natpmpMaxTransmissions, isNATPMPExternalAddressResult)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to receive the NAT-PMP response from %s: %v", gateway, err)
+		return netip.Addr{}, false
+	}
+
+	return parseNATPMPResponse(ppfmt, gateway, resp)
+}
+
+// NATPMP detects the external IPv4 address of the NAT gateway via NAT-PMP.
+type NATPMP struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The NAT-PMP server in the form host:port. If it is empty,
+	// the default IPv4 gateway in the Linux routing table will be used.
+	Gateway string
+}
+
+// Name of the detection protocol.
+func (p NATPMP) Name() string {
+	return p.ProviderName
+}
+
+// GetIPs asks the NAT gateway for its external IPv4 address.
+func (p NATPMP) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	if ipNet != ipnet.IP4 {
+		ppfmt.Noticef(pp.EmojiUserError, "NAT-PMP cannot detect %s addresses; use another provider", ipNet.Describe())
+		return nil, false
+	}
+
+	gateway := p.Gateway
+	if gateway == "" {
+		var ok bool
+		if gateway, ok = findDefaultGateway(ppfmt); !ok {
+			return nil, false
+		}
+	}
+
+	ip, ok := getIPFromNATPMP(ctx, ppfmt, gateway)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+}
@@ -0,0 +1,279 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/file"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestNATPMPName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.NATPMP{
+		ProviderName: "very secret name",
+		Gateway:      "",
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func natpmpResult(code uint16, ip netip.Addr) []byte {
+	msg := []byte{0, 128}
+	msg = binary.BigEndian.AppendUint16(msg, code)
+	msg = binary.BigEndian.AppendUint32(msg, 12345)
+	return append(msg, ip.AsSlice()...)
+}
+
+func TestNATPMPGetIPs(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("1.2.3.4")
+
+	isRequest := func(req []byte) bool { return len(req) == 2 && req[0] == 0 && req[1] == 0 }
+	server := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		if !isRequest(req) {
+			return nil
+		}
+		return [][]byte{natpmpResult(0, ip4)}
+	})
+	noisy := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{{0}, {1, 128}, {0, 129}, natpmpResult(0, ip4)}
+	})
+	refused := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{natpmpResult(2, netip.IPv4Unspecified())}
+	})
+	unknownError := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{natpmpResult(42, netip.IPv4Unspecified())}
+	})
+	tooShort := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{{0, 128, 0}}
+	})
+	tooLong := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{append(natpmpResult(0, ip4), 0)}
+	})
+	private := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte {
+		return [][]byte{natpmpResult(0, netip.MustParseAddr("127.0.0.1"))}
+	})
+	silent := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte { return nil })
+	server6 := newUDPServer(t, ipnet.IP6, func([]byte) [][]byte {
+		return [][]byte{natpmpResult(0, ip4)}
+	})
+
+	for name, tc := range map[string]struct {
+		gateway       string
+		ipNet         ipnet.Type
+		timeout       time.Duration
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"success": {server, ipnet.IP4, time.Second, true, nil},
+		"noisy":   {noisy, ipnet.IP4, time.Second, true, nil},
+		"refused": {
+			refused, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The NAT-PMP gateway %s failed to report its external address: %s", refused, "not authorized or refused")
+			},
+		},
+		"unknown-error": {
+			unknownError, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The NAT-PMP gateway %s failed to report its external address: %s", unknownError, "unknown error 42")
+			},
+		},
+		"too-short": {
+			tooShort, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid NAT-PMP response from %s: wrong length %d", tooShort, 3)
+			},
+		},
+		"too-long": {
+			tooLong, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid NAT-PMP response from %s: wrong length %d", tooLong, 13)
+			},
+		},
+		"loopback": {
+			private, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Detected %s address %s is a loopback address`, "IPv4", "127.0.0.1")
+			},
+		},
+		"timeout": {
+			silent, ipnet.IP4, 400 * time.Millisecond, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to receive the NAT-PMP response from %s: %v", silent, context.DeadlineExceeded)
+			},
+		},
+		"ip6-gateway": {
+			server6, ipnet.IP4, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to connect to the NAT-PMP gateway %s: %v", server6, gomock.Any())
+			},
+		},
+		"ip6": {
+			server, ipnet.IP6, time.Second, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, "NAT-PMP cannot detect %s addresses; use another provider", "IPv6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := protocol.NATPMP{
+				ProviderName: "secret name",
+				Gateway:      tc.gateway,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, []netip.Addr{ip4}, ips)
+			} else {
+				require.Empty(t, ips)
+			}
+		})
+	}
+}
+
+// routeTableAddr formats an IPv4 address as the Linux kernel does in /proc/net/route.
+func routeTableAddr(ip string) string {
+	return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(netip.MustParseAddr(ip).AsSlice()))
+}
+
+func TestFindDefaultGatewayIP4(t *testing.T) {
+	t.Parallel()
+
+	const header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	route := func(iface, dest, gateway, flags string, metric int, mask string) string {
+		return fmt.Sprintf("%s\t%s\t%s\t%s\t0\t0\t%d\t%s\t0\t0\t0\n",
+			iface, routeTableAddr(dest), routeTableAddr(gateway), flags, metric, routeTableAddr(mask))
+	}
+
+	for name, tc := range map[string]struct {
+		table         string
+		expected      netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"one": {
+			header +
+				route("eth0", "0.0.0.0", "192.168.1.1", "0003", 100, "0.0.0.0") +
+				route("eth0", "192.168.1.0", "0.0.0.0", "0001", 100, "255.255.255.0"),
+			netip.MustParseAddr("192.168.1.1"),
+			nil,
+		},
+		"lowest-metric": {
+			header +
+				route("wwan0", "0.0.0.0", "10.0.0.1", "0003", 700, "0.0.0.0") +
+				route("eth0", "0.0.0.0", "192.168.1.1", "0003", 100, "0.0.0.0") +
+				route("eth1", "0.0.0.0", "192.168.2.1", "0003", 200, "0.0.0.0"),
+			netip.MustParseAddr("192.168.1.1"),
+			nil,
+		},
+		"down": {
+			header +
+				route("eth0", "0.0.0.0", "192.168.1.1", "0002", 100, "0.0.0.0") +
+				route("eth1", "0.0.0.0", "192.168.2.1", "0003", 200, "0.0.0.0"),
+			netip.MustParseAddr("192.168.2.1"),
+			nil,
+		},
+		"none": {
+			header +
+				route("eth0", "192.168.1.0", "0.0.0.0", "0001", 100, "255.255.255.0") +
+				route("wg0", "0.0.0.0", "0.0.0.0", "0001", 0, "0.0.0.0"),
+			netip.Addr{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find the default IPv4 gateway in the routing table")
+			},
+		},
+		"empty": {
+			"",
+			netip.Addr{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find the default IPv4 gateway in the routing table")
+			},
+		},
+		"illformed": {
+			header + "eth0\t00000000\tXXXXXXXX\t0003\t0\t0\t100\t00000000\t0\t0\t0\n",
+			netip.Addr{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Failed to parse the route %q in the routing table", "eth0\t00000000\tXXXXXXXX\t0003\t0\t0\t100\t00000000\t0\t0\t0")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ip, ok := protocol.FindDefaultGatewayIP4(mockPP, tc.table)
+			require.Equal(t, tc.expected.IsValid(), ok)
+			require.Equal(t, tc.expected, ip)
+		})
+	}
+}
+
+//nolint:paralleltest // changing the global file.FS
+func TestNATPMPGetIPsDefaultGateway(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fs            fstest.MapFS
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"no-table": {
+			fstest.MapFS{},
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to read the routing table to find the default gateway: %v", gomock.Any())
+			},
+		},
+		"no-gateway": {
+			fstest.MapFS{"proc/net/route": {Data: []byte("Iface\tDestination\tGateway\n")}}, //nolint:exhaustruct
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to find the default IPv4 gateway in the routing table")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			savedFS := file.FS
+			file.FS = tc.fs
+			defer func() { file.FS = savedFS }()
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			tc.prepareMockPP(mockPP)
+
+			provider := protocol.NATPMP{ProviderName: "secret name", Gateway: ""}
+			ips, ok := provider.GetIPs(context.Background(), mockPP, ipnet.IP4)
+			require.False(t, ok)
+			require.Empty(t, ips)
+		})
+	}
+}
@@ -10,51 +10,69 @@ import (
 	"github.com/favonia/cloudflare-ddns/internal/pp"
 )
 
-func getIPFromRegexp(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, url string, re *regexp.Regexp,
-) (netip.Addr, bool) {
+// parseRegexpIPs extracts the IP addresses from the first capture group of the matches.
+// Only the first match is used unless all is true.
+func parseRegexpIPs(ppfmt pp.PP, url string, body []byte, re *regexp.Regexp, all bool) ([]netip.Addr, bool) {
+	var matches [][][]byte
+	if all {
+		matches = re.FindAllSubmatch(body, -1)
+	} else if matched := re.FindSubmatch(body); matched != nil {
+		matches = [][][]byte{matched}
+	}
+
+	if len(matches) == 0 || len(matches[0]) < 2 {
+		ppfmt.Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, url, body)
+		return nil, false
+	}
+
+	ips := make([]netip.Addr, 0, len(matches))
+	for _, matched := range matches {
+		ipString := string(matched[1])
+		ip, err := netip.ParseAddr(ipString)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, ipString)
+			return nil, false
+		}
+		ips = append(ips, ip)
+	}
+	return ips, true
+}
+
+func getIPsFromRegexp(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, param RegexpParam, options HTTPOptions,
+) ([]netip.Addr, bool) {
 	c := httpCore{
 		ipNet:             ipNet,
-		url:               url,
+		url:               param.URL,
 		method:            http.MethodGet,
 		additionalHeaders: nil,
+		options:           options,
 		requestBody:       nil,
-		extract: func(ppfmt pp.PP, body []byte) (netip.Addr, bool) {
-			var invalidIP netip.Addr
-
-			matched := re.FindSubmatch(body)
-			if len(matched) < 2 {
-				ppfmt.Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, url, body)
-				return invalidIP, false
-			}
-			ipString := string(matched[1])
-			ip, err := netip.ParseAddr(ipString)
-			if err != nil {
-				ppfmt.Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, url, ipString)
-				return invalidIP, false
-			}
-			return ip, true
+		extract: func(ppfmt pp.PP, body []byte) ([]netip.Addr, bool) {
+			return parseRegexpIPs(ppfmt, param.URL, body, param.Regexp, param.All)
 		},
 	}
 
-	return c.getIP(ctx, ppfmt)
+	return c.getIPs(ctx, ppfmt)
 }
 
 // RegexpParam is the type of parameters for the Regexp provider for a specific IP network.
 type RegexpParam = struct {
 	URL    string         // URL of the detection page
-	Regexp *regexp.Regexp // regular expression to match the IP address
+	Regexp *regexp.Regexp // regular expression to match the IP address in its first capture group
+	All    bool           // whether to use all the matches instead of only the first one
 }
 
 // Regexp represents a generic detection protocol to parse an HTTP response.
 type Regexp struct {
 	ProviderName string // name of the detection protocol
 	Param        map[ipnet.Type]RegexpParam
+	Options      HTTPOptions // settings of the HTTP(S) requests
 }
 
 // Name of the detection protocol.
 func (p Regexp) Name() string { return p.ProviderName }
 
-// GetIPs detects the IP address by parsing the HTTP response.
+// GetIPs detects the IP addresses by parsing the HTTP response.
 func (p Regexp) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
 	param, found := p.Param[ipNet]
 	if !found {
@@ -62,10 +80,10 @@ func (p Regexp) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]ne
 		return nil, false
 	}
 
-	ip, ok := getIPFromRegexp(ctx, ppfmt, ipNet, param.URL, param.Regexp)
+	ips, ok := getIPsFromRegexp(ctx, ppfmt, ipNet, param, p.Options)
 	if !ok {
 		return nil, false
 	}
 
-	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
 }
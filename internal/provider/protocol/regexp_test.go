@@ -165,3 +165,62 @@ func TestRegexpGetIPs(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexpGetIPsAll(t *testing.T) {
+	t.Parallel()
+
+	server := newSplitServer(ipnet.IP4, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "<<4.3.2.1>> <<1.2.3.4>> <<4.3.2.1>>")
+	}))
+	t.Cleanup(server.Close)
+
+	for name, tc := range map[string]struct {
+		regexp        *regexp.Regexp
+		all           bool
+		ok            bool
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"first": {regexp.MustCompile(`<<([^>]*)>>`), false, true, []netip.Addr{netip.MustParseAddr("4.3.2.1")}, nil},
+		"all": {
+			regexp.MustCompile(`<<([^>]*)>>`), true, true,
+			[]netip.Addr{netip.MustParseAddr("1.2.3.4"), netip.MustParseAddr("4.3.2.1")}, nil,
+		},
+		"all/no-match": {
+			regexp.MustCompile(`\[\[(.*)\]\]`), true, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to find the IP address in the response of %q (%q)`, server.URL, []byte("<<4.3.2.1>> <<1.2.3.4>> <<4.3.2.1>>"))
+			},
+		},
+		"all/illformed": {
+			regexp.MustCompile(`(\S+)`), true, false, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, `Failed to parse the IP address in the response of %q (%q)`, server.URL, "<<4.3.2.1>>")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := &protocol.Regexp{
+				ProviderName: "secret name",
+				Param: map[ipnet.Type]protocol.RegexpParam{
+					ipnet.IP4: {URL: server.URL, Regexp: tc.regexp, All: tc.all},
+				},
+			}
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			ips, ok := provider.GetIPs(ctx, mockPP, ipnet.IP4)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
@@ -2,9 +2,11 @@ package protocol
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
+	"sync"
 	"syscall"
 	"time"
 
@@ -43,8 +45,26 @@ func newControlledDialer(control func(context.Context, string, string, syscall.R
 	}
 }
 
-func newControlledTransport(control func(context.Context, string, string, syscall.RawConn) error) http.RoundTripper {
+//nolint:gochecknoglobals
+var sharedSplitDialer = map[ipnet.Type]*net.Dialer{
+	ipnet.IP4: newControlledDialer(filterIP4Only),
+	ipnet.IP6: newControlledDialer(filterIP6Only),
+}
+
+// splitDialer returns a dialer that allows only the traffic of specified IP family
+// and binds the sockets according to the binding.
+func splitDialer(ipNet ipnet.Type, b Binding) *net.Dialer {
+	if b.IsZero() {
+		return sharedSplitDialer[ipNet]
+	}
+	return newControlledDialer(bindControl(splitFilter[ipNet], b))
+}
+
+func newControlledTransport(control func(context.Context, string, string, syscall.RawConn) error,
+	tlsConfig *tls.Config,
+) http.RoundTripper {
 	return &http.Transport{ //nolint:exhaustruct
+		TLSClientConfig:       tlsConfig,
 		Proxy:                 http.ProxyFromEnvironment,
 		DialContext:           newControlledDialer(control).DialContext,
 		ForceAttemptHTTP2:     true,
@@ -55,14 +75,22 @@ func newControlledTransport(control func(context.Context, string, string, syscal
 	}
 }
 
-func newControlledClient(control func(context.Context, string, string, syscall.RawConn) error) *http.Client {
-	return &http.Client{Transport: newControlledTransport(control)} //nolint:exhaustruct
+func newControlledClient(control func(context.Context, string, string, syscall.RawConn) error,
+	tlsConfig *tls.Config,
+) *http.Client {
+	return &http.Client{Transport: newControlledTransport(control, tlsConfig)} //nolint:exhaustruct
+}
+
+//nolint:gochecknoglobals
+var splitFilter = map[ipnet.Type]func(context.Context, string, string, syscall.RawConn) error{
+	ipnet.IP4: filterIP4Only,
+	ipnet.IP6: filterIP6Only,
 }
 
 //nolint:gochecknoglobals
 var sharedSplitClient = map[ipnet.Type]*http.Client{
-	ipnet.IP4: newControlledClient(filterIP4Only),
-	ipnet.IP6: newControlledClient(filterIP6Only),
+	ipnet.IP4: newControlledClient(filterIP4Only, nil),
+	ipnet.IP6: newControlledClient(filterIP6Only, nil),
 }
 
 // SharedSplitClient returns the shared [http.Client] that allows only the traffic of specified IP family.
@@ -79,9 +107,62 @@ func SharedRetryableSplitClient(ipNet ipnet.Type) *retryablehttp.Client {
 	return c
 }
 
+// optionsClientKey identifies the client for a set of HTTP options. The TLS settings are
+// created once when the options are parsed, so the pointer identifies them.
+type optionsClientKey struct {
+	ipNet     ipnet.Type
+	tlsConfig *tls.Config
+	bind      Binding
+}
+
+// optionsClientCache keeps the clients with custom TLS settings or binding,
+// so that each provider builds its client once and reuses the connections across updates.
+//
+//nolint:gochecknoglobals
+var optionsClientCache struct {
+	sync.Mutex
+	clients map[optionsClientKey]*http.Client
+}
+
+// retryableSplitClientWithOptions returns a [retryablehttp.Client] with custom TLS settings and binding
+// that allows only the traffic of specified IP family. The underlying [http.Client] is shared
+// by all requests with the same settings.
+func retryableSplitClientWithOptions(ipNet ipnet.Type, tlsConfig *tls.Config, b Binding) *retryablehttp.Client {
+	key := optionsClientKey{ipNet: ipNet, tlsConfig: tlsConfig, bind: b}
+
+	optionsClientCache.Lock()
+	client, found := optionsClientCache.clients[key]
+	if !found {
+		if optionsClientCache.clients == nil {
+			optionsClientCache.clients = map[optionsClientKey]*http.Client{}
+		}
+		client = newControlledClient(bindControl(splitFilter[ipNet], b), tlsConfig)
+		optionsClientCache.clients[key] = client
+	}
+	optionsClientCache.Unlock()
+
+	c := retryablehttp.NewClient()
+	c.HTTPClient = client
+	c.Logger = nil
+	return c
+}
+
 // CloseIdleConnections closes all idle connections after making detecting the IP addresses.
 func CloseIdleConnections() {
 	for _, client := range ipnet.Bindings(sharedSplitClient) {
 		client.CloseIdleConnections()
 	}
+	sharedMetadataClient.CloseIdleConnections()
+
+	optionsClientCache.Lock()
+	for _, client := range optionsClientCache.clients {
+		client.CloseIdleConnections()
+	}
+	optionsClientCache.Unlock()
+
+	kubernetesClientCache.Lock()
+	defer kubernetesClientCache.Unlock()
+	if kubernetesClientCache.client != nil {
+		kubernetesClientCache.client.CloseIdleConnections()
+	}
 }
@@ -3,11 +3,41 @@ package protocol
 import (
 	"context"
 	"net/netip"
+	"slices"
+	"strings"
 
 	"github.com/favonia/cloudflare-ddns/internal/ipnet"
 	"github.com/favonia/cloudflare-ddns/internal/pp"
 )
 
+// ParseLiteralIPs parses the IP addresses written by the user, such as the ones
+// after "literal:". Each address is trimmed, and zoned addresses are rejected.
+// The result is sorted and deduplicated.
+func ParseLiteralIPs(ppfmt pp.PP, prefix string, rawIPs []string) ([]netip.Addr, bool) {
+	ips := make([]netip.Addr, 0, len(rawIPs))
+	for _, rawIP := range rawIPs {
+		rawIP = strings.TrimSpace(rawIP)
+
+		ip, err := netip.ParseAddr(rawIP)
+		if err != nil {
+			ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the IP address %q for "%s:"`, rawIP, prefix)
+			return nil, false
+		}
+		if ip.Zone() != "" {
+			ppfmt.Noticef(
+				pp.EmojiUserError,
+				`Failed to parse the IP address %q for "%s:": zoned IP addresses are not allowed`,
+				rawIP, prefix,
+			)
+			return nil, false
+		}
+		ips = append(ips, ip)
+	}
+
+	slices.SortFunc(ips, netip.Addr.Compare)
+	return slices.Compact(ips), true
+}
+
 // Static returns the same set of IPs.
 type Static struct {
 	// Name of the detection protocol.
@@ -0,0 +1,188 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// This file implements the minimum of RFC 5389 needed to learn the mapped address:
+// one Binding Request without authentication and the XOR-MAPPED-ADDRESS attribute
+// in the Binding Success Response.
+
+const (
+	stunHeaderLength               = 20
+	stunMagicCookie         uint32 = 0x2112A442
+	stunTransactionIDLength        = 12
+
+	stunBindingRequest         uint16 = 0x0001
+	stunBindingSuccessResponse uint16 = 0x0101
+	stunBindingErrorResponse   uint16 = 0x0111
+
+	stunAttrXORMappedAddress uint16 = 0x0020
+
+	stunFamilyIP4 byte = 0x01
+	stunFamilyIP6 byte = 0x02
+
+	// stunInitialRTO and stunMaxTransmissions are the retransmission parameters
+	// suggested by RFC 5389. The retransmission timeout doubles after each attempt.
+	stunInitialRTO       = 500 * time.Millisecond
+	stunMaxTransmissions = 7
+)
+
+type stunTransactionID = [stunTransactionIDLength]byte
+
+func newSTUNBindingRequest(id stunTransactionID) []byte {
+	msg := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(msg[2:4], 0) // no attributes
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:stunHeaderLength], id[:])
+	return msg
+}
+
+// isSTUNResponseTo checks whether the message looks like a STUN response to the transaction.
+func isSTUNResponseTo(msg []byte, id stunTransactionID) bool {
+	return len(msg) >= stunHeaderLength &&
+		msg[0]&0xC0 == 0 && // the two most significant bits must be zero
+		binary.BigEndian.Uint32(msg[4:8]) == stunMagicCookie &&
+		bytes.Equal(msg[8:stunHeaderLength], id[:])
+}
+
+func parseSTUNXORMappedAddress(ppfmt pp.PP, value []byte, id stunTransactionID) (netip.Addr, bool) {
+	// The mask for IPv6 addresses is the magic cookie followed by the transaction ID;
+	// the mask for IPv4 addresses is only the magic cookie.
+	var mask [16]byte
+	binary.BigEndian.PutUint32(mask[0:4], stunMagicCookie)
+	copy(mask[4:], id[:])
+
+	if len(value) < 4 {
+		ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: XOR-MAPPED-ADDRESS is too short")
+		return netip.Addr{}, false
+	}
+
+	var ipLength int
+	switch value[1] {
+	case stunFamilyIP4:
+		ipLength = net.IPv4len
+	case stunFamilyIP6:
+		ipLength = net.IPv6len
+	default:
+		ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: unknown address family %d in XOR-MAPPED-ADDRESS", value[1])
+		return netip.Addr{}, false
+	}
+
+	if len(value) != 4+ipLength {
+		ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: XOR-MAPPED-ADDRESS has a wrong length")
+		return netip.Addr{}, false
+	}
+
+	raw := make([]byte, ipLength)
+	for i := range raw {
+		raw[i] = value[4+i] ^ mask[i]
+	}
+
+	ip, _ := netip.AddrFromSlice(raw) // the length is always 4 or 16
+	return ip, true
+}
+
+func parseSTUNResponse(ppfmt pp.PP, msg []byte, id stunTransactionID) (netip.Addr, bool) {
+	switch binary.BigEndian.Uint16(msg[0:2]) {
+	case stunBindingSuccessResponse:
+	case stunBindingErrorResponse:
+		ppfmt.Noticef(pp.EmojiError, "The STUN server returned an error response")
+		return netip.Addr{}, false
+	default:
+		ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: unexpected message type 0x%04x",
+			binary.BigEndian.Uint16(msg[0:2]))
+		return netip.Addr{}, false
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if length != len(msg)-stunHeaderLength {
+		ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: mismatched message length")
+		return netip.Addr{}, false
+	}
+
+	attrs := msg[stunHeaderLength:]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLength := int(binary.BigEndian.Uint16(attrs[2:4]))
+		// Attribute values are padded to a multiple of 4 bytes.
+		paddedLength := (attrLength + 3) &^ 3
+		if 4+paddedLength > len(attrs) {
+			ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: truncated attribute")
+			return netip.Addr{}, false
+		}
+
+		if attrType == stunAttrXORMappedAddress {
+			return parseSTUNXORMappedAddress(ppfmt, attrs[4:4+attrLength], id)
+		}
+
+		attrs = attrs[4+paddedLength:]
+	}
+
+	ppfmt.Noticef(pp.EmojiError, "Invalid STUN response: no XOR-MAPPED-ADDRESS attribute")
+	return netip.Addr{}, false
+}
+
+func getIPFromSTUN(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type, b Binding, server string,
+) (netip.Addr, bool) {
+	var id stunTransactionID
+	if _, err := rand.Read(id[:]); err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to generate a STUN transaction ID: %v", err)
+		return netip.Addr{}, false
+	}
+
+	conn, err := splitDialer(ipNet, b).DialContext(ctx, "udp", server)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to connect to the STUN server %s: %v", server, err)
+		return netip.Addr{}, false
+	}
+	defer conn.Close()
+
+	resp, err := exchangeUDP(ctx, conn, newSTUNBindingRequest(id), stunInitialRTO, stunMaxTransmissions,
+		func(msg []byte) bool { return isSTUNResponseTo(msg, id) })
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to receive the STUN response from %s: %v", server, err)
+		return netip.Addr{}, false
+	}
+
+	return parseSTUNResponse(ppfmt, resp, id)
+}
+
+// STUN represents a generic detection protocol using the STUN Binding Request
+// defined in RFC 5389.
+type STUN struct {
+	ProviderName string                // name of the protocol
+	Server       map[ipnet.Type]string // address of the STUN server, in the form host:port
+	Bind         Binding               // the network interface or the source address of the requests
+}
+
+// Name of the detection protocol.
+func (p STUN) Name() string {
+	return p.ProviderName
+}
+
+// GetIPs detects the IP address by sending a STUN Binding Request.
+func (p STUN) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	server, found := p.Server[ipNet]
+	if !found {
+		ppfmt.Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", ipNet.Describe())
+		return nil, false
+	}
+
+	ip, ok := getIPFromSTUN(ctx, ppfmt, ipNet, p.Bind, server)
+	if !ok {
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, []netip.Addr{ip})
+}
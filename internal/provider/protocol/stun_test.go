@@ -0,0 +1,245 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+const stunMagicCookie uint32 = 0x2112A442
+
+func TestSTUNName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.STUN{
+		ProviderName: "very secret name",
+		Server:       nil,
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+func stunAttr(attrType uint16, value []byte) []byte {
+	attr := binary.BigEndian.AppendUint16(nil, attrType)
+	attr = binary.BigEndian.AppendUint16(attr, uint16(len(value)))
+	attr = append(attr, value...)
+	for len(attr)%4 != 0 {
+		attr = append(attr, 0)
+	}
+	return attr
+}
+
+func stunXORMappedAddress(req []byte, family byte, ip netip.Addr) []byte {
+	mask := binary.BigEndian.AppendUint32(nil, stunMagicCookie)
+	mask = append(mask, req[8:20]...)
+
+	value := []byte{0, family, 0x12, 0x34} // X-Port is ignored
+	for i, b := range ip.AsSlice() {
+		value = append(value, b^mask[i])
+	}
+	return stunAttr(0x0020, value)
+}
+
+func stunMessage(req []byte, msgType uint16, attrs ...[]byte) []byte {
+	var body []byte
+	for _, attr := range attrs {
+		body = append(body, attr...)
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, msgType)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(body)))
+	msg = append(msg, req[4:20]...) // magic cookie and transaction ID
+	return append(msg, body...)
+}
+
+func TestSTUNGetIPs(t *testing.T) {
+	t.Parallel()
+
+	ip4 := netip.MustParseAddr("1.2.3.4")
+	ip6 := netip.MustParseAddr("2606:4700:4700::1234")
+	invalidIP := netip.Addr{}
+
+	success4 := func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunAttr(0x8022, []byte("test")), stunXORMappedAddress(req, 0x01, ip4))}
+	}
+	success6 := func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunXORMappedAddress(req, 0x02, ip6))}
+	}
+	server4 := newUDPServer(t, ipnet.IP4, success4)
+	server6 := newUDPServer(t, ipnet.IP6, success6)
+	server6via4 := newUDPServer(t, ipnet.IP4, success6)
+	noisy4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		other := append([]byte{}, req...)
+		other[19]++
+		return [][]byte{
+			[]byte("hello"),
+			stunMessage(other, 0x0101, stunXORMappedAddress(other, 0x01, netip.MustParseAddr("5.6.7.8"))),
+			success4(req)[0],
+		}
+	})
+	lossy4 := newUDPServer(t, ipnet.IP4, func() func([]byte) [][]byte {
+		count := 0
+		return func(req []byte) [][]byte {
+			count++
+			if count == 1 {
+				return nil
+			}
+			return success4(req)
+		}
+	}())
+	silent4 := newUDPServer(t, ipnet.IP4, func([]byte) [][]byte { return nil })
+	error4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0111, stunAttr(0x0009, []byte{0, 0, 4, 0}))}
+	})
+	request4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0001)}
+	})
+	noAddress4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunAttr(0x8022, []byte("test")))}
+	})
+	badFamily4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunXORMappedAddress(req, 0x03, ip4))}
+	})
+	badLength4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunXORMappedAddress(req, 0x02, ip4))}
+	})
+	tooShort4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		return [][]byte{stunMessage(req, 0x0101, stunAttr(0x0020, []byte{0, 1}))}
+	})
+	truncated4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		msg := stunMessage(req, 0x0101, stunXORMappedAddress(req, 0x01, ip4))
+		binary.BigEndian.PutUint16(msg[22:24], 100)
+		return [][]byte{msg}
+	})
+	mismatched4 := newUDPServer(t, ipnet.IP4, func(req []byte) [][]byte {
+		msg := stunMessage(req, 0x0101, stunXORMappedAddress(req, 0x01, ip4))
+		binary.BigEndian.PutUint16(msg[2:4], 100)
+		return [][]byte{msg}
+	})
+
+	for name, tc := range map[string]struct {
+		serverKey     ipnet.Type
+		server        string
+		ipNet         ipnet.Type
+		timeout       time.Duration
+		expected      netip.Addr
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		"4":     {ipnet.IP4, server4, ipnet.IP4, time.Second, ip4, nil},
+		"6":     {ipnet.IP6, server6, ipnet.IP6, time.Second, ip6, nil},
+		"noisy": {ipnet.IP4, noisy4, ipnet.IP4, time.Second, ip4, nil},
+		"lossy": {ipnet.IP4, lossy4, ipnet.IP4, 2 * time.Second, ip4, nil},
+		"4to6": {
+			ipnet.IP6, server4, ipnet.IP6, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to connect to the STUN server %s: %v", server4, gomock.Any())
+			},
+		},
+		"6to4": {
+			ipnet.IP4, server6via4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Detected IP address %s is not a valid IPv4 address", ip6.String())
+			},
+		},
+		"timeout": {
+			ipnet.IP4, silent4, ipnet.IP4, 700 * time.Millisecond, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to receive the STUN response from %s: %v", silent4, context.DeadlineExceeded)
+			},
+		},
+		"error-response": {
+			ipnet.IP4, error4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "The STUN server returned an error response")
+			},
+		},
+		"unexpected-type": {
+			ipnet.IP4, request4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: unexpected message type 0x%04x", uint16(0x0001))
+			},
+		},
+		"no-address": {
+			ipnet.IP4, noAddress4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: no XOR-MAPPED-ADDRESS attribute")
+			},
+		},
+		"bad-family": {
+			ipnet.IP4, badFamily4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: unknown address family %d in XOR-MAPPED-ADDRESS", byte(3))
+			},
+		},
+		"bad-length": {
+			ipnet.IP4, badLength4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: XOR-MAPPED-ADDRESS has a wrong length")
+			},
+		},
+		"too-short": {
+			ipnet.IP4, tooShort4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: XOR-MAPPED-ADDRESS is too short")
+			},
+		},
+		"truncated": {
+			ipnet.IP4, truncated4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: truncated attribute")
+			},
+		},
+		"mismatched-length": {
+			ipnet.IP4, mismatched4, ipnet.IP4, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiError, "Invalid STUN response: mismatched message length")
+			},
+		},
+		"not-handled": {
+			ipnet.IP4, server4, ipnet.IP6, time.Second, invalidIP,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiImpossible, "Unhandled IP network: %s", "IPv6")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+
+			provider := protocol.STUN{
+				ProviderName: "secret name",
+				Server: map[ipnet.Type]string{
+					tc.serverKey: tc.server,
+				},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+
+			ips, ok := provider.GetIPs(ctx, mockPP, tc.ipNet)
+			require.Equal(t, tc.expected.IsValid(), ok)
+			if tc.expected.IsValid() {
+				require.Equal(t, []netip.Addr{tc.expected}, ips)
+			} else {
+				require.Empty(t, ips)
+			}
+		})
+	}
+}
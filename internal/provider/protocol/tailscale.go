@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// TailscaleSocket is the default path of the unix socket of tailscaled on Linux.
+const TailscaleSocket = "/var/run/tailscale/tailscaled.sock"
+
+// tailscaleStatusURL is the URL of the status in the local API. The host is ignored by tailscaled.
+const tailscaleStatusURL = "http://local-tailscaled.sock/localapi/v0/status"
+
+// maxTailscaleStatusLength is the maximum number of bytes read from the status.
+// The status includes all peers, so it can be much larger than other responses.
+const maxTailscaleStatusLength int64 = 16 << 20
+
+// Tailscale reads the Tailscale addresses of this node from the local API of tailscaled.
+type Tailscale struct {
+	// Name of the detection protocol.
+	ProviderName string
+
+	// The path of the unix socket of tailscaled.
+	Socket string
+}
+
+// Name of the detection protocol.
+func (p Tailscale) Name() string {
+	return p.ProviderName
+}
+
+// getTailscaleStatus reads the status of this node from the local API of tailscaled.
+func (p Tailscale) getTailscaleStatus(ctx context.Context, ppfmt pp.PP) ([]byte, bool) {
+	var dialer net.Dialer
+	client := &http.Client{ //nolint:exhaustruct
+		Transport: &http.Transport{ //nolint:exhaustruct
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", p.Socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tailscaleStatusURL, nil)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiImpossible, "Failed to prepare the request to tailscaled: %v", err)
+		return nil, false
+	}
+	// tailscaled rejects the requests to the local API without this header.
+	req.Header.Set("Sec-Tailscale", "localapi")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to send the request to tailscaled at %q: %v", p.Socket, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ppfmt.Noticef(pp.EmojiError, "Failed to get the status from tailscaled at %q: %s", p.Socket, resp.Status)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTailscaleStatusLength))
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to read the status from tailscaled at %q: %v", p.Socket, err)
+		return nil, false
+	}
+	return body, true
+}
+
+// GetIPs reads the Tailscale addresses of this node.
+func (p Tailscale) GetIPs(ctx context.Context, ppfmt pp.PP, ipNet ipnet.Type) ([]netip.Addr, bool) {
+	body, ok := p.getTailscaleStatus(ctx, ppfmt)
+	if !ok {
+		return nil, false
+	}
+
+	var status struct {
+		BackendState string `json:"BackendState"`
+		Self         *struct {
+			TailscaleIPs []netip.Addr `json:"TailscaleIPs"`
+		} `json:"Self"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		ppfmt.Noticef(pp.EmojiError, "Failed to parse the status from tailscaled at %q: %v", p.Socket, err)
+		return nil, false
+	}
+	if status.Self == nil {
+		ppfmt.Noticef(pp.EmojiError, "The status from tailscaled at %q does not describe this node", p.Socket)
+		return nil, false
+	}
+
+	var ips []netip.Addr
+	for _, ip := range status.Self.TailscaleIPs {
+		if ipNet.Matches(ip) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		ppfmt.Noticef(pp.EmojiError, "This node does not have any Tailscale %s addresses (the state of tailscaled is %q)",
+			ipNet.Describe(), status.BackendState)
+		return nil, false
+	}
+
+	return ipNet.NormalizeDetectedIPs(ppfmt, ips)
+}
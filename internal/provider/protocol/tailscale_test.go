@@ -0,0 +1,129 @@
+package protocol_test
+
+// vim: nowrap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+func TestTailscaleName(t *testing.T) {
+	t.Parallel()
+
+	p := protocol.Tailscale{
+		ProviderName: "very secret name",
+		Socket:       "",
+	}
+
+	require.Equal(t, "very secret name", p.Name())
+}
+
+// newTailscaled mimics the local API of tailscaled on a unix socket.
+func newTailscaled(t *testing.T, status int, body string) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "tailscaled.sock")
+	listener, err := net.Listen("unix", socket) //nolint:noctx
+	require.NoError(t, err)
+
+	server := &http.Server{ //nolint:exhaustruct
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/localapi/v0/status" || r.Header.Get("Sec-Tailscale") != "localapi" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}),
+		ReadHeaderTimeout: time.Minute,
+	}
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(func() { server.Close() })
+
+	return socket
+}
+
+func TestTailscaleGetIPs(t *testing.T) {
+	t.Parallel()
+
+	const running = `{"BackendState":"Running","Self":{"HostName":"node","TailscaleIPs":["100.101.102.103","fd7a:115c:a1e0::1"]},"Peer":{}}`
+
+	for name, tc := range map[string]struct {
+		status        int
+		body          string
+		ipNet         ipnet.Type
+		expected      []netip.Addr
+		prepareMockPP func(*mocks.MockPP, string)
+	}{
+		"4": {http.StatusOK, running, ipnet.IP4, []netip.Addr{netip.MustParseAddr("100.101.102.103")}, nil},
+		"6": {http.StatusOK, running, ipnet.IP6, []netip.Addr{netip.MustParseAddr("fd7a:115c:a1e0::1")}, nil},
+		"stopped": {
+			http.StatusOK, `{"BackendState":"Stopped","Self":{"TailscaleIPs":null}}`, ipnet.IP4, nil,
+			func(m *mocks.MockPP, _ string) {
+				m.EXPECT().Noticef(pp.EmojiError, "This node does not have any Tailscale %s addresses (the state of tailscaled is %q)", "IPv4", "Stopped")
+			},
+		},
+		"no-self": {
+			http.StatusOK, `{"BackendState":"NoState"}`, ipnet.IP4, nil,
+			func(m *mocks.MockPP, socket string) {
+				m.EXPECT().Noticef(pp.EmojiError, "The status from tailscaled at %q does not describe this node", socket)
+			},
+		},
+		"garbage": {
+			http.StatusOK, `{"Self":{"TailscaleIPs":["not-an-ip"]}}`, ipnet.IP4, nil,
+			func(m *mocks.MockPP, socket string) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to parse the status from tailscaled at %q: %v", socket, gomock.Any())
+			},
+		},
+		"forbidden": {
+			http.StatusForbidden, "", ipnet.IP4, nil,
+			func(m *mocks.MockPP, socket string) {
+				m.EXPECT().Noticef(pp.EmojiError, "Failed to get the status from tailscaled at %q: %s", socket, "403 Forbidden")
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			socket := newTailscaled(t, tc.status, tc.body)
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP, socket)
+			}
+
+			p := protocol.Tailscale{ProviderName: "", Socket: socket}
+			ips, ok := p.GetIPs(context.Background(), mockPP, tc.ipNet)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, ips)
+		})
+	}
+}
+
+func TestTailscaleGetIPsNoSocket(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiError, "Failed to send the request to tailscaled at %q: %v", socket, gomock.Any())
+
+	p := protocol.Tailscale{ProviderName: "", Socket: socket}
+	ips, ok := p.GetIPs(context.Background(), mockPP, ipnet.IP4)
+	require.False(t, ok)
+	require.Nil(t, ips)
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// maxUDPMessageLength is the size of the buffer for UDP responses.
+const maxUDPMessageLength = 1500
+
+// exchangeUDP sends the request over a connected UDP socket and waits for a datagram
+// accepted by isResponse. Other datagrams are silently discarded. The request is
+// retransmitted whenever the retransmission timeout expires, and the timeout doubles
+// after each attempt. The context cause is returned if the context is done.
+func exchangeUDP(ctx context.Context, conn net.Conn, req []byte,
+	rto time.Duration, maxTransmissions int, isResponse func([]byte) bool,
+) ([]byte, error) {
+	// Closing the connection unblocks any pending read when the context is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, maxUDPMessageLength)
+	for range maxTransmissions {
+		if _, err := conn.Write(req); err != nil {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+			return nil, err
+		}
+
+		resp, err := readUDPResponse(conn, buf, time.Now().Add(rto), isResponse)
+		switch {
+		case ctx.Err() != nil:
+			return nil, context.Cause(ctx)
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			rto *= 2
+		case err != nil:
+			return nil, err
+		default:
+			return resp, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no responses after %d attempts", maxTransmissions)
+}
+
+func readUDPResponse(conn net.Conn, buf []byte, deadline time.Time, isResponse func([]byte) bool) ([]byte, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if isResponse(buf[:n]) {
+			return buf[:n], nil
+		}
+	}
+}
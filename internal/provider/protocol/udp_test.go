@@ -0,0 +1,51 @@
+package protocol_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+)
+
+// newUDPServer starts a UDP stand-in server. For each request, it sends back
+// whatever respond returns, one datagram per element.
+func newUDPServer(t *testing.T, ipNet ipnet.Type, respond func(req []byte) [][]byte) string {
+	t.Helper()
+
+	var addr string
+	switch ipNet {
+	case ipnet.IP4:
+		addr = "127.0.0.1:0"
+	case ipnet.IP6:
+		addr = "[::1]:0"
+	}
+
+	conn, err := net.ListenPacket(ipNet.UDPNetwork(), addr) //nolint:noctx
+	require.NoError(t, err)
+
+	return serveUDP(t, conn, respond)
+}
+
+// serveUDP runs a UDP stand-in server on the connection until the test ends.
+func serveUDP(t *testing.T, conn net.PacketConn, respond func(req []byte) [][]byte) string {
+	t.Helper()
+
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, resp := range respond(buf[:n]) {
+				_, _ = conn.WriteTo(resp, from)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// newRegex creates a provider that reads the IP addresses from the first capture group
+// of the regular expression. The argument is "<url>,<pattern>"; the URL is split at the first comma
+// because patterns such as "\d{1,3}" often contain commas.
+func newRegex(ppfmt pp.PP, prefix string, options HTTPOptions, raw string, all bool) (Provider, bool) {
+	rawURL, pattern, found := strings.Cut(strings.TrimSpace(raw), ",")
+	if !found || pattern == "" {
+		ppfmt.Noticef(pp.EmojiUserError, `The provider %s:(redacted) must be followed by a URL and a regular expression`,
+			prefix)
+		return nil, false
+	}
+	rawURL = strings.TrimSpace(rawURL)
+
+	if !checkProviderURL(ppfmt, prefix, rawURL) {
+		return nil, false
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		ppfmt.Noticef(pp.EmojiUserError, `Failed to compile the regular expression %q for "%s:": %v`, pattern, prefix, err)
+		return nil, false
+	}
+	if re.NumSubexp() < 1 {
+		ppfmt.Noticef(pp.EmojiUserError,
+			`The regular expression %q for "%s:" must contain a capture group for the IP address`, pattern, prefix)
+		return nil, false
+	}
+
+	return protocol.Regexp{
+		ProviderName: prefix + ":(redacted)," + pattern,
+		Param: map[ipnet.Type]protocol.RegexpParam{
+			ipnet.IP4: {URL: rawURL, Regexp: re, All: all},
+			ipnet.IP6: {URL: rawURL, Regexp: re, All: all},
+		},
+		Options: options,
+	}, true
+}
+
+// NewRegex creates a provider that reads the IP address from the first match of the regular expression.
+func NewRegex(ppfmt pp.PP, raw string) (Provider, bool) {
+	return NewRegexWithOptions(ppfmt, HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}, raw)
+}
+
+// NewRegexWithOptions creates a regex provider with custom settings of HTTP(S) requests.
+func NewRegexWithOptions(ppfmt pp.PP, options HTTPOptions, raw string) (Provider, bool) {
+	return newRegex(ppfmt, "regex", options, raw, false)
+}
+
+// MustNewRegex creates a regex provider and panics if it fails.
+func MustNewRegex(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewRegex(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
+
+// NewRegexAll creates a provider that reads the IP addresses from all matches of the regular expression.
+func NewRegexAll(ppfmt pp.PP, raw string) (Provider, bool) {
+	return NewRegexAllWithOptions(ppfmt, HTTPOptions{Header: nil, BasicAuth: nil, TLSConfig: nil}, raw)
+}
+
+// NewRegexAllWithOptions creates a regex-all provider with custom settings of HTTP(S) requests.
+func NewRegexAllWithOptions(ppfmt pp.PP, options HTTPOptions, raw string) (Provider, bool) {
+	return newRegex(ppfmt, "regex-all", options, raw, true)
+}
+
+// MustNewRegexAll creates a regex-all provider and panics if it fails.
+func MustNewRegexAll(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewRegexAll(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
@@ -0,0 +1,89 @@
+package provider_test
+
+// vim: nowrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestRegexName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `regex:(redacted),WAN: (\S+)`, provider.Name(provider.MustNewRegex(`https://router.lan/status,WAN: (\S+)`)))
+	require.Equal(t, `regex-all:(redacted),addr=(\d{1,3}(?:\.\d{1,3}){3})`, provider.Name(provider.MustNewRegexAll(` https://router.lan/status ,addr=(\d{1,3}(?:\.\d{1,3}){3})`)))
+}
+
+func TestNewRegex(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		input         string
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		{`https://router.lan/status,WAN: (\S+)`, true, nil},
+		{
+			`https://router.lan/status`, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) must be followed by a URL and a regular expression`, "regex")
+			},
+		},
+		{
+			`https://router.lan/status,`, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) must be followed by a URL and a regular expression`, "regex")
+			},
+		},
+		{
+			`ftp://router.lan/status,(.*)`, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The provider %s:(redacted) only supports HTTP and HTTPS`, "regex")
+			},
+		},
+		{
+			`https://router.lan/status,(.*`, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to compile the regular expression %q for "%s:": %v`, "(.*", "regex", gomock.Any())
+			},
+		},
+		{
+			`https://router.lan/status,WAN: \S+`, false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `The regular expression %q for "%s:" must contain a capture group for the IP address`, `WAN: \S+`, "regex")
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p, ok := provider.NewRegex(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.NotNil(t, p)
+			} else {
+				require.Nil(t, p)
+			}
+		})
+	}
+}
+
+func TestMustNewRegex(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewRegex(`https://router.lan/status,WAN: (\S+)`) })
+	require.Panics(t, func() { provider.MustNewRegex(`https://router.lan/status,WAN`) })
+	require.NotPanics(t, func() { provider.MustNewRegexAll(`https://router.lan/status,WAN: (\S+)`) })
+	require.Panics(t, func() { provider.MustNewRegexAll(`https://router.lan/status`) })
+}
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// parseServerAddress parses the address of a UDP server used by the provider
+// named by prefix (e.g., "stun"). The port is optional.
+func parseServerAddress(ppfmt pp.PP, prefix string, raw string, defaultPort int) (string, bool) {
+	host, port, err := net.SplitHostPort(raw)
+	if err != nil {
+		// Treat the whole input as the host (possibly an IPv6 address in brackets).
+		host, port = raw, strconv.Itoa(defaultPort)
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+	}
+
+	if host == "" || strings.ContainsAny(host, "[]") {
+		ppfmt.Noticef(pp.EmojiUserError, `Failed to parse %q as a server address for "%s:"`, raw, prefix)
+		return "", false
+	}
+
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		ppfmt.Noticef(pp.EmojiUserError, `Failed to parse the port %q for "%s:"`, port, prefix)
+		return "", false
+	}
+
+	return net.JoinHostPort(host, port), true
+}
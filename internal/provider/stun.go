@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// defaultSTUNPort is the default port of STUN servers registered by RFC 5389.
+const defaultSTUNPort = 3478
+
+// NewSTUN creates a [protocol.STUN] provider. The server is given as host:port,
+// and the port defaults to 3478 if omitted.
+func NewSTUN(ppfmt pp.PP, raw string) (Provider, bool) {
+	server, ok := parseServerAddress(ppfmt, "stun", raw, defaultSTUNPort)
+	if !ok {
+		return nil, false
+	}
+
+	return protocol.STUN{
+		ProviderName: "stun:" + server,
+		Server: map[ipnet.Type]string{
+			ipnet.IP4: server,
+			ipnet.IP6: server,
+		},
+		Bind: protocol.Binding{}, //nolint:exhaustruct
+	}, true
+}
+
+// MustNewSTUN creates a [protocol.STUN] provider and panics if it fails.
+func MustNewSTUN(raw string) Provider {
+	var buf strings.Builder
+	p, ok := NewSTUN(pp.NewDefault(&buf), raw)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
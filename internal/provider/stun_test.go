@@ -0,0 +1,92 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestSTUNName(t *testing.T) {
+	t.Parallel()
+
+	for input, name := range map[string]string{
+		"stun.example.net":      "stun:stun.example.net:3478",
+		"stun.example.net:3479": "stun:stun.example.net:3479",
+		"1.2.3.4":               "stun:1.2.3.4:3478",
+		"::1":                   "stun:[::1]:3478",
+		"[::1]":                 "stun:[::1]:3478",
+		"[::1]:19302":           "stun:[::1]:19302",
+	} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, name, provider.Name(provider.MustNewSTUN(input)))
+		})
+	}
+}
+
+func TestNewSTUN(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		input         string
+		ok            bool
+		prepareMockPP func(*mocks.MockPP)
+	}{
+		{"stun.example.net", true, nil},
+		{"stun.example.net:3478", true, nil},
+		{
+			":3478", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse %q as a server address for "%s:"`, ":3478", "stun")
+			},
+		},
+		{
+			"[[::1]]", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse %q as a server address for "%s:"`, "[[::1]]", "stun")
+			},
+		},
+		{
+			"stun.example.net:http", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the port %q for "%s:"`, "http", "stun")
+			},
+		},
+		{
+			"stun.example.net:65536", false,
+			func(m *mocks.MockPP) {
+				m.EXPECT().Noticef(pp.EmojiUserError, `Failed to parse the port %q for "%s:"`, "65536", "stun")
+			},
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			p, ok := provider.NewSTUN(mockPP, tc.input)
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.NotNil(t, p)
+			} else {
+				require.Nil(t, p)
+			}
+		})
+	}
+}
+
+func TestMustNewSTUN(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { provider.MustNewSTUN("stun.example.net") })
+	require.Panics(t, func() { provider.MustNewSTUN("") })
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider/protocol"
+)
+
+// NewTailscale creates a [protocol.Tailscale] provider that talks to tailscaled
+// via its default unix socket.
+func NewTailscale() Provider {
+	return protocol.Tailscale{
+		ProviderName: "tailscale",
+		Socket:       protocol.TailscaleSocket,
+	}
+}
+
+// NewTailscaleWithSocket creates a [protocol.Tailscale] provider that talks to tailscaled
+// via a specific unix socket. The path must be absolute.
+func NewTailscaleWithSocket(ppfmt pp.PP, path string) (Provider, bool) {
+	if !filepath.IsAbs(path) {
+		ppfmt.Noticef(pp.EmojiUserError, `The path %q for "tailscale:" is not absolute`, path)
+		return nil, false
+	}
+
+	path = filepath.Clean(path)
+	return protocol.Tailscale{
+		ProviderName: "tailscale:" + path,
+		Socket:       path,
+	}, true
+}
+
+// MustNewTailscaleWithSocket creates a [protocol.Tailscale] provider and panics if it fails.
+func MustNewTailscaleWithSocket(path string) Provider {
+	var buf strings.Builder
+	p, ok := NewTailscaleWithSocket(pp.NewDefault(&buf), path)
+	if !ok {
+		panic(buf.String())
+	}
+	return p
+}
@@ -0,0 +1,33 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/provider"
+)
+
+func TestTailscaleName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "tailscale", provider.Name(provider.NewTailscale()))
+	require.Equal(t, "tailscale:/run/tailscale/tailscaled.sock",
+		provider.Name(provider.MustNewTailscaleWithSocket("/run//tailscale/tailscaled.sock")))
+}
+
+func TestNewTailscaleWithSocket(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockPP.EXPECT().Noticef(pp.EmojiUserError, `The path %q for "tailscale:" is not absolute`, "tailscaled.sock")
+
+	p, ok := provider.NewTailscaleWithSocket(mockPP, "tailscaled.sock")
+	require.False(t, ok)
+	require.Nil(t, p)
+	require.Panics(t, func() { provider.MustNewTailscaleWithSocket("tailscaled.sock") })
+}
@@ -20,6 +20,10 @@ import (
 
 // Setter uses [api.Handle] to reconcile DNS records and WAF lists.
 type Setter interface {
+	// StartRound marks the start of a round of updating or deleting. In the dry-run mode,
+	// the changes planned in the previous round are forgotten.
+	StartRound()
+
 	// SetIPs sets a particular domain to the given IP addresses.
 	//
 	// Invariant: IPs must already be canonical and represent a deterministic set:
@@ -0,0 +1,67 @@
+package setter_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/favonia/cloudflare-ddns/internal/api"
+	"github.com/favonia/cloudflare-ddns/internal/mocks"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+	"github.com/favonia/cloudflare-ddns/internal/setter"
+)
+
+// TestDryRunRound checks the whole log of two rounds in the dry-run mode:
+// only the planned changes are logged, none of them is reported as done,
+// and each round plans the changes against the real state again.
+func TestDryRunRound(t *testing.T) {
+	t.Parallel()
+
+	f := newDNSRecordFixture()
+	wafList := api.WAFList{AccountID: "account", Name: "list"}
+	const listDescription = "My List"
+
+	mockCtrl := gomock.NewController(t)
+	ctx := context.Background()
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockHandle := mocks.NewMockHandle(mockCtrl)
+
+	options := api.HandleOptions{CacheExpiration: time.Minute, ManagedRecordsCommentRegex: nil}
+	s, ok := setter.New(mockPP, api.NewDryRunHandle(mockHandle, options), true)
+	require.True(t, ok)
+
+	gomock.InOrder(
+		mockHandle.EXPECT().ListRecords(ctx, mockPP, f.ipNetwork, f.domain, f.params).
+			Return([]api.Record{dnsRecord(f.record1, f.ip2, f.params)}, false, true),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would update a stale %s record of %s (ID: %s) to %s",
+			"AAAA", "sub.test.org", f.record1, f.ip1),
+		mockHandle.EXPECT().ListWAFListItems(ctx, mockPP, wafList, listDescription).
+			Return([]api.WAFListItem{wafItem("2001:db8::/64", "item1")}, true, false, true),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would add %s to the list %s", "::/64", "account/list"),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would delete %s from the list %s", "2001:db8::/64", "account/list"),
+	)
+
+	detectedIPs := detected(netip.Addr{}, f.ip1)
+	require.Equal(t, setter.ResponseUpdated, s.SetIPs(ctx, mockPP, f.ipNetwork, f.domain, []netip.Addr{f.ip1}, f.params))
+	require.Equal(t, setter.ResponseUpdated, s.SetWAFList(ctx, mockPP, wafList, listDescription, detectedIPs, ""))
+
+	// The next round starts from the real records and lists, which were not changed,
+	// and plans the same changes again.
+	s.StartRound()
+	gomock.InOrder(
+		mockHandle.EXPECT().ListRecords(ctx, mockPP, f.ipNetwork, f.domain, f.params).
+			Return([]api.Record{dnsRecord(f.record1, f.ip2, f.params)}, true, true),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would update a stale %s record of %s (ID: %s) to %s",
+			"AAAA", "sub.test.org", f.record1, f.ip1),
+		mockHandle.EXPECT().ListWAFListItems(ctx, mockPP, wafList, listDescription).
+			Return([]api.WAFListItem{wafItem("2001:db8::/64", "item1")}, true, true, true),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would add %s to the list %s", "::/64", "account/list"),
+		mockPP.EXPECT().Noticef(pp.EmojiDryRun, "Dry run: would delete %s from the list %s", "2001:db8::/64", "account/list"),
+	)
+	require.Equal(t, setter.ResponseUpdated, s.SetIPs(ctx, mockPP, f.ipNetwork, f.domain, []netip.Addr{f.ip1}, f.params))
+	require.Equal(t, setter.ResponseUpdated, s.SetWAFList(ctx, mockPP, wafList, listDescription, detectedIPs, ""))
+}
@@ -17,7 +17,7 @@ func TestNewAcceptsHandle(t *testing.T) {
 	mockPP := mocks.NewMockPP(mockCtrl)
 	mockHandle := mocks.NewMockHandle(mockCtrl)
 
-	s, ok := setter.New(mockPP, mockHandle)
+	s, ok := setter.New(mockPP, mockHandle, false)
 	require.True(t, ok)
 	require.NotNil(t, s)
 }
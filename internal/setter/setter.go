@@ -14,11 +14,37 @@ import (
 
 type setter struct {
 	Handle api.Handle
+	DryRun bool // the handle is an [api.DryRunHandle] that logs the planned changes by itself
 }
 
 // New creates a new Setter against one handle-bound ownership scope.
-func New(_ppfmt pp.PP, handle api.Handle) (Setter, bool) {
-	return setter{Handle: handle}, true
+// The flag dryRun should be set when the handle is an [api.DryRunHandle].
+func New(_ppfmt pp.PP, handle api.Handle, dryRun bool) (Setter, bool) {
+	return setter{Handle: handle, DryRun: dryRun}, true
+}
+
+// viewResetter is implemented by handles that keep the planned changes between calls,
+// such as [api.DryRunHandle].
+type viewResetter interface {
+	ResetView()
+}
+
+var _ viewResetter = api.DryRunHandle{} //nolint:exhaustruct
+
+// StartRound forgets the changes planned in the previous round, if any.
+func (s setter) StartRound() {
+	if r, ok := s.Handle.(viewResetter); ok {
+		r.ResetView()
+	}
+}
+
+// changef logs a change that was made. In the dry-run mode, nothing was made,
+// and [api.DryRunHandle] has already logged what would have been made.
+func (s setter) changef(ppfmt pp.PP, emoji pp.Emoji, format string, args ...any) {
+	if s.DryRun {
+		return
+	}
+	ppfmt.Noticef(emoji, format, args...)
 }
 
 // Record represents a DNS record in this package.
@@ -119,7 +145,7 @@ func (s setter) SetIPs(ctx context.Context, ppfmt pp.PP,
 					recordType, domainDescription)
 				return ResponseFailed
 			}
-			ppfmt.Noticef(pp.EmojiUpdate,
+			s.changef(ppfmt, pp.EmojiUpdate,
 				"Updated a stale %s record of %s (ID: %s)",
 				recordType, domainDescription, recycled.ID)
 			staleRecords = staleRecords[1:]
@@ -133,7 +159,7 @@ func (s setter) SetIPs(ctx context.Context, ppfmt pp.PP,
 				recordType, domainDescription)
 			return ResponseFailed
 		}
-		ppfmt.Noticef(pp.EmojiCreation,
+		s.changef(ppfmt, pp.EmojiCreation,
 			"Added a new %s record of %s (ID: %s)", recordType, domainDescription, id)
 	}
 
@@ -146,7 +172,7 @@ func (s setter) SetIPs(ctx context.Context, ppfmt pp.PP,
 			return ResponseFailed
 		}
 
-		ppfmt.Noticef(pp.EmojiDeletion,
+		s.changef(ppfmt, pp.EmojiDeletion,
 			"Deleted a stale %s record of %s (ID: %s)", recordType, domainDescription, r.ID)
 	}
 
@@ -155,7 +181,7 @@ func (s setter) SetIPs(ctx context.Context, ppfmt pp.PP,
 	for _, target := range targets {
 		for _, r := range matchedByIP[target] {
 			if ok := s.Handle.DeleteRecord(ctx, ppfmt, ipNetwork, domain, r.ID, api.RegularDelitionMode); ok {
-				ppfmt.Noticef(pp.EmojiDeletion,
+				s.changef(ppfmt, pp.EmojiDeletion,
 					"Deleted a duplicate %s record of %s (ID: %s)", recordType, domainDescription, r.ID)
 			}
 			if ctx.Err() != nil {
@@ -208,7 +234,7 @@ func (s setter) FinalDelete(ctx context.Context, ppfmt pp.PP, ipnet ipnet.Type,
 			continue
 		}
 
-		ppfmt.Noticef(pp.EmojiDeletion, "Deleted a stale %s record of %s (ID: %s)", recordType, domainDescription, id)
+		s.changef(ppfmt, pp.EmojiDeletion, "Deleted a stale %s record of %s (ID: %s)", recordType, domainDescription, id)
 	}
 	if !allOK {
 		ppfmt.Noticef(pp.EmojiError,
@@ -234,7 +260,7 @@ func (s setter) SetWAFList(ctx context.Context, ppfmt pp.PP,
 		return ResponseFailed
 	}
 	if !alreadyExisting {
-		ppfmt.Noticef(pp.EmojiCreation, "Created a new list %s", list.Describe())
+		s.changef(ppfmt, pp.EmojiCreation, "Created a new list %s", list.Describe())
 	}
 
 	var itemsToDelete []api.WAFListItem
@@ -305,7 +331,7 @@ func (s setter) SetWAFList(ctx context.Context, ppfmt pp.PP,
 		return ResponseFailed
 	}
 	for _, item := range itemsToCreate {
-		ppfmt.Noticef(pp.EmojiCreation, "Added %s to the list %s",
+		s.changef(ppfmt, pp.EmojiCreation, "Added %s to the list %s",
 			ipnet.DescribePrefixOrIP(item), list.Describe())
 	}
 
@@ -319,7 +345,7 @@ func (s setter) SetWAFList(ctx context.Context, ppfmt pp.PP,
 		return ResponseFailed
 	}
 	for _, item := range itemsToDelete {
-		ppfmt.Noticef(pp.EmojiDeletion, "Deleted %s from the list %s",
+		s.changef(ppfmt, pp.EmojiDeletion, "Deleted %s from the list %s",
 			ipnet.DescribePrefixOrIP(item.Prefix), list.Describe())
 	}
 
@@ -332,7 +358,7 @@ func (s setter) FinalClearWAFList(ctx context.Context, ppfmt pp.PP, list api.WAF
 	deleted, ok := s.Handle.FinalClearWAFListAsync(ctx, ppfmt, list, listDescription)
 	switch {
 	case ok && deleted:
-		ppfmt.Noticef(pp.EmojiDeletion, "The list %s was deleted", list.Describe())
+		s.changef(ppfmt, pp.EmojiDeletion, "The list %s was deleted", list.Describe())
 		return ResponseUpdated
 	case ok && !deleted:
 		ppfmt.Noticef(pp.EmojiClear, "The list %s is being cleared (asynchronously)", list.Describe())
@@ -65,7 +65,7 @@ func newSetterHarness(t *testing.T) (context.Context, setterHarness) {
 	mockPP := mocks.NewMockPP(mockCtrl)
 	mockHandle := mocks.NewMockHandle(mockCtrl)
 
-	s, ok := setter.New(mockPP, mockHandle)
+	s, ok := setter.New(mockPP, mockHandle, false)
 	require.True(t, ok)
 
 	return ctx, setterHarness{
@@ -35,13 +35,17 @@ func NotifyContext(ctx context.Context) (context.Context, context.CancelFunc) {
 }
 
 // WaitForSignalsUntil waits for a period of time. It returns true if it is interrupted by signals in [Signals].
-func (h Handle) WaitForSignalsUntil(ppfmt pp.PP, t time.Time) bool {
+// It also stops waiting (and returns false) when the trigger channel receives a value; a nil trigger never fires.
+func (h Handle) WaitForSignalsUntil(ppfmt pp.PP, t time.Time, trigger <-chan struct{}) bool {
 	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
 	for {
 		select {
 		case sig := <-h.channel:
 			ppfmt.Noticef(pp.EmojiSignal, "Caught signal: %v", sig)
 			return true
+		case <-trigger:
+			return false
 		case <-timer.C:
 			return false
 		}
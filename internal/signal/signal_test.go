@@ -58,7 +58,7 @@ func TestWaitForSignalsUntil(t *testing.T) {
 			sig := signal.Setup()
 			go signalSelf()
 			target := time.Now().Add(tc.alarmDelay)
-			res := sig.WaitForSignalsUntil(mockPP, target)
+			res := sig.WaitForSignalsUntil(mockPP, target, nil)
 			<-done
 
 			require.Equal(t, tc.expected, res)
@@ -66,6 +66,22 @@ func TestWaitForSignalsUntil(t *testing.T) {
 	}
 }
 
+//nolint:paralleltest //signals are global
+func TestWaitForSignalsUntilTrigger(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+
+	trigger := make(chan struct{}, 1)
+	trigger <- struct{}{}
+
+	sig := signal.Setup()
+	startTime := time.Now()
+	res := sig.WaitForSignalsUntil(mockPP, startTime.Add(time.Minute), trigger)
+
+	require.False(t, res)
+	require.WithinDuration(t, startTime, time.Now(), time.Second/10)
+}
+
 //nolint:paralleltest //signals are global
 func TestNotifyContext(t *testing.T) {
 	delta := time.Second / 10
@@ -0,0 +1,25 @@
+package updater
+
+import (
+	"net/netip"
+
+	"github.com/favonia/cloudflare-ddns/internal/sliceutil"
+)
+
+// applyInterfaceID combines the leading id.Bits() bits of each detected IPv6 address
+// with the remaining bits of the interface identifier id.
+func applyInterfaceID(ips []netip.Addr, id netip.Prefix) []netip.Addr {
+	suffix := id.Addr().As16()
+	targets := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		bytes := ip.As16()
+		for i := range bytes {
+			// the mask of the leading bits within the i-th byte
+			bits := min(max(id.Bits()-8*i, 0), 8)
+			mask := byte(0xff << (8 - bits))
+			bytes[i] = bytes[i]&mask | suffix[i]&^mask
+		}
+		targets = append(targets, netip.AddrFrom16(bytes))
+	}
+	return sliceutil.SortAndCompact(targets, netip.Addr.Compare)
+}
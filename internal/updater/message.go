@@ -1,6 +1,9 @@
 package updater
 
 import (
+	"slices"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
 	"github.com/favonia/cloudflare-ddns/internal/heartbeat"
 	"github.com/favonia/cloudflare-ddns/internal/notifier"
 )
@@ -34,3 +37,19 @@ func MergeMessages(msgs ...Message) Message {
 		NotifierMessage:  notifier.MergeMessages(nms...),
 	}
 }
+
+// labelDryRun marks a message in the dry-run mode so that planned changes
+// will not be mistaken for actual ones. Empty notifier messages are kept empty.
+func labelDryRun(c *config.UpdateConfig, msg Message) Message {
+	if !c.DryRun {
+		return msg
+	}
+
+	msg.HeartbeatMessage.Lines = slices.Insert(slices.Clone(msg.HeartbeatMessage.Lines), 0,
+		"Dry run (nothing was actually changed)")
+	if !msg.NotifierMessage.IsEmpty() {
+		msg.NotifierMessage = slices.Insert(slices.Clone(msg.NotifierMessage), 0,
+			"Dry run (nothing was actually changed):")
+	}
+	return msg
+}
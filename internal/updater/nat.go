@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"slices"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/heartbeat"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/notifier"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// cgnatRange is the shared address space for carrier-grade NAT (RFC 6598).
+var cgnatRange = netip.MustParsePrefix("100.64.0.0/10") //nolint:gochecknoglobals
+
+// detectNAT compares the local IPv4 address with the detected (public) ones.
+// It returns the local address if the updater seems to be behind carrier-grade NAT
+// or the NAT of a router, either of which can stop inbound connections.
+func detectNAT(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, ips []netip.Addr) (netip.Addr, bool) {
+	if c.NATProbe == nil {
+		return netip.Addr{}, false
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, c.DetectionTimeout, errTimeout)
+	defer cancel()
+
+	// The check is best-effort; failures of the probe are not worth reporting.
+	locals, ok := c.NATProbe.GetIPs(ctx, pp.New(io.Discard, false, pp.Quiet), ipnet.IP4)
+	// A network interface may have several addresses; none of them being published indicates NAT.
+	if !ok || len(locals) == 0 || slices.ContainsFunc(locals, func(l netip.Addr) bool { return slices.Contains(ips, l) }) {
+		return netip.Addr{}, false
+	}
+	local := locals[0]
+
+	switch {
+	case cgnatRange.Contains(local):
+		ppfmt.NoticeOncef(pp.MessageCarrierGradeNAT, pp.EmojiHint,
+			"The local IPv4 address %v is in 100.64.0.0/10, which indicates carrier-grade NAT; "+
+				"inbound connections to %s will likely not reach this machine. "+
+				"Consider using IPv6 or a tunnel if you need to accept inbound connections",
+			local, describeIPs(ips))
+		return local, true
+	case local.IsPrivate():
+		ppfmt.NoticeOncef(pp.MessageNATPortForwarding, pp.EmojiHint,
+			"The local IPv4 address %v is private; "+
+				"inbound connections to %s will reach this machine only if your router forwards them. "+
+				"Set DETECT_NAT=false to skip this check",
+			local, describeIPs(ips))
+		return local, true
+	default:
+		return netip.Addr{}, false
+	}
+}
+
+// generateNATMessage adds a warning to the notification about updated DNS records.
+// Nothing is added if no notification will be sent, so that the warning is not repeated at every update.
+func generateNATMessage(local netip.Addr, setMsg Message) Message {
+	if len(setMsg.NotifierMessage) == 0 {
+		return NewMessage()
+	}
+
+	var notifierMsg notifier.Message
+	if cgnatRange.Contains(local) {
+		notifierMsg = notifier.NewMessagef(
+			"The local IPv4 address %v indicates carrier-grade NAT; "+
+				"inbound connections to the published IPv4 addresses will likely not reach this machine.",
+			local)
+	} else {
+		notifierMsg = notifier.NewMessagef(
+			"The local IPv4 address %v is private; "+
+				"inbound connections to the published IPv4 addresses will reach this machine only if your router forwards them.",
+			local)
+	}
+	return Message{
+		HeartbeatMessage: heartbeat.NewMessage(),
+		NotifierMessage:  notifierMsg,
+	}
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// ignoredIP is a detected IP address dropped by the ranges.
+type ignoredIP struct {
+	ip     netip.Addr
+	denied netip.Prefix // the denied range containing ip; invalid if ip is not in the allowed ranges
+}
+
+// filterDetectedIPs drops the detected IP addresses that are outside the allowed ranges
+// (if any) or inside the denied ranges. It fails if no addresses are left.
+// The dropped addresses are reported only when they differ from the ones dropped last time,
+// so that a stable Docker or CGNAT address is not reported at every update.
+func (t *ChangeTracker) filterDetectedIPs(ppfmt pp.PP, c *config.UpdateConfig, ipNet ipnet.Type, ips []netip.Addr,
+) ([]netip.Addr, bool) {
+	allowed := c.AllowedRanges[ipNet]
+	denied := c.DeniedRanges[ipNet]
+	if len(allowed) == 0 && len(denied) == 0 {
+		return ips, true
+	}
+
+	kept := make([]netip.Addr, 0, len(ips))
+	var ignored []ignoredIP
+ipLoop:
+	for _, ip := range ips {
+		if len(allowed) > 0 && !slices.ContainsFunc(allowed, func(r netip.Prefix) bool { return r.Contains(ip) }) {
+			ignored = append(ignored, ignoredIP{ip: ip, denied: netip.Prefix{}})
+			continue
+		}
+		for _, r := range denied {
+			if r.Contains(ip) {
+				ignored = append(ignored, ignoredIP{ip: ip, denied: r})
+				continue ipLoop
+			}
+		}
+		kept = append(kept, ip)
+	}
+
+	if !slices.Equal(t.ignored[ipNet], ignored) {
+		t.ignored[ipNet] = ignored
+		for _, i := range ignored {
+			if i.denied.IsValid() {
+				ppfmt.Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is in %v of IP%d_DENIED_RANGES",
+					ipNet.Describe(), i.ip, i.denied, ipNet.Int())
+			} else {
+				ppfmt.Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is not in IP%d_ALLOWED_RANGES",
+					ipNet.Describe(), i.ip, ipNet.Int())
+			}
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, false
+	}
+	return kept, true
+}
@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/ipnet"
+	"github.com/favonia/cloudflare-ddns/internal/pp"
+)
+
+// changeRecheckInterval is how soon a pending change is checked again
+// when it only needs more confirmations.
+const changeRecheckInterval = 30 * time.Second
+
+// pendingChange is a newly detected set of IP addresses waiting for confirmation.
+type pendingChange struct {
+	ips   []netip.Addr
+	since time.Time
+	count int
+}
+
+// ChangeTracker remembers the detected IP addresses between rounds, so that a change is
+// published only after it is seen IP_CHANGE_CONFIRMATIONS times in a row and stays the same
+// for IP_CHANGE_STABLE_FOR. It also remembers the addresses dropped by the allowed and denied ranges,
+// so that they are reported only when they change. The zero value is not usable; use [NewChangeTracker] instead.
+type ChangeTracker struct {
+	now       func() time.Time
+	published map[ipnet.Type][]netip.Addr
+	pending   map[ipnet.Type]*pendingChange
+	ignored   map[ipnet.Type][]ignoredIP
+}
+
+// NewChangeTracker creates a [ChangeTracker] that uses now as the clock.
+func NewChangeTracker(now func() time.Time) *ChangeTracker {
+	return &ChangeTracker{
+		now:       now,
+		published: map[ipnet.Type][]netip.Addr{},
+		pending:   map[ipnet.Type]*pendingChange{},
+		ignored:   map[ipnet.Type][]ignoredIP{},
+	}
+}
+
+// confirm records the detected IP addresses and checks whether they should be published now.
+// The first detected addresses are always published immediately because there is nothing to compare with.
+func (t *ChangeTracker) confirm(ppfmt pp.PP, c *config.UpdateConfig, ipNet ipnet.Type, ips []netip.Addr) bool {
+	published, found := t.published[ipNet]
+	if !found || slices.Equal(published, ips) || (c.IPChangeConfirmations <= 1 && c.IPChangeStableFor <= 0) {
+		t.published[ipNet] = ips
+		delete(t.pending, ipNet)
+		return true
+	}
+
+	now := t.now()
+	p := t.pending[ipNet]
+	if p == nil || !slices.Equal(p.ips, ips) {
+		p = &pendingChange{ips: ips, since: now, count: 0}
+		t.pending[ipNet] = p
+	}
+	p.count++
+
+	stableFor := now.Sub(p.since)
+	if p.count >= c.IPChangeConfirmations && stableFor >= c.IPChangeStableFor {
+		t.published[ipNet] = ips
+		delete(t.pending, ipNet)
+		return true
+	}
+
+	ppfmt.Infof(pp.EmojiAlarm,
+		"Waiting to confirm the new %s addresses %s (seen %d/%d times; unchanged for %v/%v)",
+		ipNet.Describe(), describeIPs(ips),
+		p.count, max(c.IPChangeConfirmations, 1), stableFor.Round(time.Second), c.IPChangeStableFor)
+	return false
+}
+
+// forget drops the pending change because the detection failed and the change was not seen in a row.
+func (t *ChangeTracker) forget(ipNet ipnet.Type) {
+	delete(t.pending, ipNet)
+}
+
+// NextRecheck returns when the pending changes should be checked again.
+// It returns the zero time if no changes are pending.
+func (t *ChangeTracker) NextRecheck(c *config.UpdateConfig) time.Time {
+	var next time.Time
+	for _, p := range t.pending {
+		recheck := t.now().Add(changeRecheckInterval)
+		if stable := p.since.Add(c.IPChangeStableFor); stable.After(t.now()) &&
+			(p.count >= c.IPChangeConfirmations || stable.Before(recheck)) {
+			recheck = stable
+		}
+		if next.IsZero() || recheck.Before(next) {
+			next = recheck
+		}
+	}
+	return next
+}
@@ -6,9 +6,11 @@ import (
 	"context"
 	"errors"
 	"net/netip"
+	"time"
 
 	"github.com/favonia/cloudflare-ddns/internal/api"
 	"github.com/favonia/cloudflare-ddns/internal/config"
+	"github.com/favonia/cloudflare-ddns/internal/domain"
 	"github.com/favonia/cloudflare-ddns/internal/ipnet"
 	"github.com/favonia/cloudflare-ddns/internal/pp"
 	"github.com/favonia/cloudflare-ddns/internal/provider"
@@ -22,11 +24,15 @@ func getMessageIDForDetection(ipNet ipnet.Type) pp.ID {
 	}[ipNet]
 }
 
-func detectIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, ipNet ipnet.Type) ([]netip.Addr, Message) {
+func (t *ChangeTracker) detectIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, ipNet ipnet.Type,
+) ([]netip.Addr, Message) {
 	ctx, cancel := context.WithTimeoutCause(ctx, c.DetectionTimeout, errTimeout)
 	defer cancel()
 
 	ips, ok := c.Provider[ipNet].GetIPs(ctx, ppfmt, ipNet)
+	if ok {
+		ips, ok = t.filterDetectedIPs(ppfmt, c, ipNet, ips)
+	}
 
 	switch {
 	// Fast path: one detected target.
@@ -87,16 +93,40 @@ func wrapUpdateWithTimeout(ctx context.Context, ppfmt pp.PP, c *config.UpdateCon
 	return resp
 }
 
+// targetIPs gives the IP addresses of a domain, combining the detected ones with
+// the interface identifier of the domain (if any).
+func targetIPs(c *config.UpdateConfig, ipNet ipnet.Type, domain domain.Domain, ips []netip.Addr) []netip.Addr {
+	if ipNet != ipnet.IP6 {
+		return ips
+	}
+	id, found := c.InterfaceIDs[domain]
+	if !found {
+		return ips
+	}
+	return applyInterfaceID(ips, id)
+}
+
 // setIPs extracts relevant settings from the configuration and calls [setter.Setter.SetIPs] with timeout.
+// Domains with the same target IP addresses are reported together.
 func setIPs(ctx context.Context, ppfmt pp.PP,
 	c *config.UpdateConfig, s setter.Setter, ipNet ipnet.Type, ips []netip.Addr,
 ) Message {
-	resps := emptySetterResponses()
+	var groups []string
+	groupTargets := map[string][]netip.Addr{}
+	groupResps := map[string]setterResponses{}
 
 	for _, domain := range c.Domains[ipNet] {
-		resps.register(domain,
+		targets := targetIPs(c, ipNet, domain, ips)
+		group := describeIPs(targets)
+		if _, found := groupResps[group]; !found {
+			groups = append(groups, group)
+			groupTargets[group] = targets
+			groupResps[group] = emptySetterResponses()
+		}
+
+		groupResps[group].register(domain,
 			wrapUpdateWithTimeout(ctx, ppfmt, c, func(ctx context.Context) setter.ResponseCode {
-				return s.SetIPs(ctx, ppfmt, ipNet, domain, ips, api.RecordParams{
+				return s.SetIPs(ctx, ppfmt, ipNet, domain, targets, api.RecordParams{
 					TTL:     c.TTL,
 					Proxied: c.Proxied[domain],
 					Comment: c.RecordComment,
@@ -105,7 +135,15 @@ func setIPs(ctx context.Context, ppfmt pp.PP,
 		)
 	}
 
-	return generateUpdateMessage(ipNet, ips, resps)
+	if len(groups) == 0 {
+		return generateUpdateMessage(ipNet, ips, emptySetterResponses())
+	}
+
+	msgs := make([]Message, 0, len(groups))
+	for _, group := range groups {
+		msgs = append(msgs, generateUpdateMessage(ipNet, groupTargets[group], groupResps[group]))
+	}
+	return MergeMessages(msgs...)
 }
 
 // finalDeleteIP extracts relevant settings from the configuration
@@ -164,7 +202,16 @@ func finalClearWAFLists(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig
 }
 
 // UpdateIPs detects IP addresses and updates DNS records of managed domains.
+// It does not remember anything between calls; use [ChangeTracker.UpdateIPs] to confirm changes before publishing them.
 func UpdateIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s setter.Setter) Message {
+	return NewChangeTracker(time.Now).UpdateIPs(ctx, ppfmt, c, s)
+}
+
+// UpdateIPs detects IP addresses and updates DNS records of managed domains.
+// Changes of the detected IP addresses are published only after they are confirmed.
+func (t *ChangeTracker) UpdateIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s setter.Setter) Message {
+	s.StartRound()
+
 	var msgs []Message
 	detectedIPsForWAF := map[ipnet.Type][]netip.Addr{}
 	numManagedNetworks := 0
@@ -172,16 +219,33 @@ func UpdateIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s sette
 	for ipNet, p := range ipnet.Bindings(c.Provider) {
 		if p != nil {
 			numManagedNetworks++
-			ips, msg := detectIPs(ctx, ppfmt, c, ipNet)
+			ips, msg := t.detectIPs(ctx, ppfmt, c, ipNet)
 			msgs = append(msgs, msg)
 
 			// Note: If we can't detect the new IP address,
 			// it's probably better to leave existing records alone.
 			if msg.HeartbeatMessage.OK {
 				numValidIPs++
+
+				// Keep the DNS records and WAF lists as they are until the change is confirmed.
+				if !t.confirm(ppfmt, c, ipNet, ips) {
+					detectedIPsForWAF[ipNet] = t.published[ipNet]
+					continue
+				}
+
 				detectedIPsForWAF[ipNet] = ips
-				msgs = append(msgs, setIPs(ctx, ppfmt, c, s, ipNet, ips))
+				var local netip.Addr
+				behindNAT := false
+				if ipNet == ipnet.IP4 {
+					local, behindNAT = detectNAT(ctx, ppfmt, c, ips)
+				}
+				setMsg := setIPs(ctx, ppfmt, c, s, ipNet, ips)
+				msgs = append(msgs, setMsg)
+				if behindNAT {
+					msgs = append(msgs, generateNATMessage(local, setMsg))
+				}
 			} else {
+				t.forget(ipNet)
 				// Keep a nil entry for managed-but-failed families.
 				// Missing keys represent unmanaged families.
 				detectedIPsForWAF[ipNet] = nil
@@ -198,11 +262,13 @@ func UpdateIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s sette
 		msgs = append(msgs, setWAFLists(ctx, ppfmt, c, s, detectedIPsForWAF))
 	}
 
-	return MergeMessages(msgs...)
+	return labelDryRun(c, MergeMessages(msgs...))
 }
 
 // FinalDeleteIPs removes all DNS records of managed domains.
 func FinalDeleteIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s setter.Setter) Message {
+	s.StartRound()
+
 	var msgs []Message
 
 	for ipNet, provider := range ipnet.Bindings(c.Provider) {
@@ -214,5 +280,5 @@ func FinalDeleteIPs(ctx context.Context, ppfmt pp.PP, c *config.UpdateConfig, s
 	// Clear WAF lists
 	msgs = append(msgs, finalClearWAFLists(ctx, ppfmt, c, s))
 
-	return MergeMessages(msgs...)
+	return labelDryRun(c, MergeMessages(msgs...))
 }
@@ -178,6 +178,7 @@ func TestUpdateIPsMultiple(t *testing.T) {
 				mockProviders[ipnet] = mockProvider
 			}
 			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
 			if tc.prepareMocks != nil {
 				tc.prepareMocks(mockPP, mockProviders, mockSetter)
 			}
@@ -194,6 +195,397 @@ func TestUpdateIPsMultiple(t *testing.T) {
 	}
 }
 
+func TestUpdateIPsInterfaceIDs(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	server1 := domain.FQDN("server1.hello")
+	server2 := domain.FQDN("server2.hello")
+	detected6 := []netip.Addr{netip.MustParseAddr("2001:db8:1:2::1"), netip.MustParseAddr("2001:db8:1:3::1")}
+	targets1 := []netip.Addr{netip.MustParseAddr("2001:db8:1:2::1234:5678"), netip.MustParseAddr("2001:db8:1:3::1234:5678")}
+	targets2 := []netip.Addr{netip.MustParseAddr("2001:db8:1:ab::1")}
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockProvider := mocks.NewMockProvider(mockCtrl)
+	mockSetter := mocks.NewMockSetter(mockCtrl)
+	mockSetter.EXPECT().StartRound()
+
+	conf := initUpdateConfig()
+	conf.Provider[ipnet.IP6] = mockProvider
+	conf.Domains[ipnet.IP6] = []domain.Domain{domain6, server1, server2}
+	conf.InterfaceIDs = map[domain.Domain]netip.Prefix{
+		server1: netip.MustParsePrefix("::1234:5678/64"),
+		server2: netip.MustParsePrefix("0:0:0:ab::1/56"),
+	}
+
+	gomock.InOrder(
+		mockProvider.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP6).Return(detected6, true),
+		mockPP.EXPECT().Infof(pp.EmojiInternet, "Detected %d %s addresses: %s", 2, "IPv6", "2001:db8:1:2::1, 2001:db8:1:3::1"),
+		mockPP.EXPECT().Suppress(pp.MessageIP6DetectionFails),
+		mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP6, domain6, detected6, params).Return(setter.ResponseNoop),
+		mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP6, server1, targets1, params).Return(setter.ResponseUpdated),
+		mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP6, server2, targets2, params).Return(setter.ResponseUpdated),
+	)
+
+	resp := updater.UpdateIPs(context.Background(), mockPP, conf, mockSetter)
+	require.Equal(t, updater.Message{
+		HeartbeatMessage: heartbeat.Message{
+			OK: true,
+			Lines: []string{
+				"Set AAAA (2001:db8:1:2::1234:5678, 2001:db8:1:3::1234:5678) of server1.hello",
+				"Set AAAA (2001:db8:1:ab::1) of server2.hello",
+			},
+		},
+		NotifierMessage: notifier.Message{
+			"Updated AAAA records of server1.hello with 2001:db8:1:2::1234:5678 and 2001:db8:1:3::1234:5678.",
+			"Updated AAAA records of server2.hello with 2001:db8:1:ab::1.",
+		},
+	}, resp)
+}
+
+func TestUpdateIPsRanges(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	public := netip.MustParseAddr("203.0.113.1")
+	docker := netip.MustParseAddr("172.17.0.1")
+	cgnat := netip.MustParseAddr("100.64.0.1")
+
+	for name, tc := range map[string]struct {
+		detected         []netip.Addr
+		ok               bool
+		monitorMessages  []string
+		notifierMessages []string
+		prepareMocks     func(*mocks.MockPP, *mocks.MockSetter)
+	}{
+		"partial": {
+			[]netip.Addr{cgnat, docker, public},
+			true, nil, nil,
+			func(p *mocks.MockPP, s *mocks.MockSetter) {
+				gomock.InOrder(
+					p.EXPECT().Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is not in IP%d_ALLOWED_RANGES", "IPv4", cgnat, 4),
+					p.EXPECT().Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is in %v of IP%d_DENIED_RANGES", "IPv4", docker, netip.MustParsePrefix("172.16.0.0/12"), 4),
+					p.EXPECT().Infof(pp.EmojiInternet, "Detected the %s address %v", "IPv4", public),
+					p.EXPECT().Suppress(pp.MessageIP4DetectionFails),
+					s.EXPECT().SetIPs(gomock.Any(), p, ipnet.IP4, domain4, []netip.Addr{public}, params).Return(setter.ResponseNoop),
+				)
+			},
+		},
+		"none": {
+			[]netip.Addr{docker},
+			false,
+			[]string{"Failed to detect any IPv4 addresses"},
+			[]string{"Failed to detect any IPv4 addresses."},
+			func(p *mocks.MockPP, _ *mocks.MockSetter) {
+				gomock.InOrder(
+					p.EXPECT().Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is in %v of IP%d_DENIED_RANGES", "IPv4", docker, netip.MustParsePrefix("172.16.0.0/12"), 4),
+					p.EXPECT().Noticef(pp.EmojiError, "Failed to detect any %s addresses", "IPv4"),
+					p.EXPECT().NoticeOncef(pp.MessageIP4DetectionFails, pp.EmojiHint, "If your network does not support IPv4, you can disable it with IP4_PROVIDER=none"),
+				)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockProvider := mocks.NewMockProvider(mockCtrl)
+			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
+
+			conf := initUpdateConfig()
+			conf.Provider[ipnet.IP4] = mockProvider
+			conf.Domains[ipnet.IP4] = []domain.Domain{domain4}
+			conf.AllowedRanges = map[ipnet.Type][]netip.Prefix{
+				ipnet.IP4: {netip.MustParsePrefix("172.16.0.0/12"), netip.MustParsePrefix("203.0.113.0/24")},
+			}
+			conf.DeniedRanges = map[ipnet.Type][]netip.Prefix{
+				ipnet.IP4: {netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("172.16.0.0/12")},
+			}
+
+			mockProvider.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).Return(tc.detected, true)
+			tc.prepareMocks(mockPP, mockSetter)
+
+			resp := updater.UpdateIPs(context.Background(), mockPP, conf, mockSetter)
+			require.Equal(t, updater.Message{
+				HeartbeatMessage: heartbeat.Message{OK: tc.ok, Lines: tc.monitorMessages},
+				NotifierMessage:  notifier.Message(tc.notifierMessages),
+			}, resp)
+		})
+	}
+}
+
+func TestChangeTrackerIgnoredIPs(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	public := netip.MustParseAddr("203.0.113.1")
+	docker := netip.MustParseAddr("172.17.0.1")
+	bridge := netip.MustParseAddr("172.18.0.1")
+	denied := netip.MustParsePrefix("172.16.0.0/12")
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockProvider := mocks.NewMockProvider(mockCtrl)
+	mockSetter := mocks.NewMockSetter(mockCtrl)
+
+	conf := initUpdateConfig()
+	conf.Provider[ipnet.IP4] = mockProvider
+	conf.Domains[ipnet.IP4] = []domain.Domain{domain4}
+	conf.DeniedRanges = map[ipnet.Type][]netip.Prefix{ipnet.IP4: {denied}}
+
+	tracker := updater.NewChangeTracker(time.Now)
+	update := func(detected []netip.Addr, ignored ...netip.Addr) {
+		mockProvider.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).Return(detected, true)
+		for _, ip := range ignored {
+			mockPP.EXPECT().Noticef(pp.EmojiWarning, "Ignoring the detected %s address %v because it is in %v of IP%d_DENIED_RANGES", "IPv4", ip, denied, 4)
+		}
+		mockPP.EXPECT().Infof(pp.EmojiInternet, "Detected the %s address %v", "IPv4", public)
+		mockPP.EXPECT().Suppress(pp.MessageIP4DetectionFails)
+		mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP4, domain4, []netip.Addr{public}, params).Return(setter.ResponseNoop)
+
+		mockSetter.EXPECT().StartRound()
+		resp := tracker.UpdateIPs(context.Background(), mockPP, conf, mockSetter)
+		require.Equal(t, updater.Message{
+			HeartbeatMessage: heartbeat.Message{OK: true, Lines: nil},
+			NotifierMessage:  notifier.Message(nil),
+		}, resp)
+	}
+
+	// The ignored addresses are reported only when they change.
+	update([]netip.Addr{docker, public}, docker)
+	update([]netip.Addr{docker, public})
+	update([]netip.Addr{docker, bridge, public}, docker, bridge)
+	update([]netip.Addr{docker, bridge, public})
+	update([]netip.Addr{public})
+	update([]netip.Addr{docker, public}, docker)
+}
+
+func TestUpdateIPsNAT(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	public := netip.MustParseAddr("203.0.113.1")
+	detected := []netip.Addr{public}
+
+	for name, tc := range map[string]struct {
+		local            []netip.Addr
+		localOK          bool
+		response         setter.ResponseCode
+		monitorMessages  []string
+		notifierMessages []string
+		prepareMockPP    func(*mocks.MockPP)
+	}{
+		"cgnat/updated": {
+			[]netip.Addr{netip.MustParseAddr("100.64.1.2")}, true, setter.ResponseUpdated,
+			[]string{"Set A (203.0.113.1) of ip4.hello"},
+			[]string{
+				"Updated A records of ip4.hello with 203.0.113.1.",
+				"The local IPv4 address 100.64.1.2 indicates carrier-grade NAT; inbound connections to the published IPv4 addresses will likely not reach this machine.",
+			},
+			func(m *mocks.MockPP) {
+				m.EXPECT().NoticeOncef(pp.MessageCarrierGradeNAT, pp.EmojiHint,
+					"The local IPv4 address %v is in 100.64.0.0/10, which indicates carrier-grade NAT; inbound connections to %s will likely not reach this machine. Consider using IPv6 or a tunnel if you need to accept inbound connections",
+					netip.MustParseAddr("100.64.1.2"), "203.0.113.1")
+			},
+		},
+		"cgnat/noop": {
+			[]netip.Addr{netip.MustParseAddr("100.64.1.2")}, true, setter.ResponseNoop,
+			nil, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().NoticeOncef(pp.MessageCarrierGradeNAT, pp.EmojiHint,
+					"The local IPv4 address %v is in 100.64.0.0/10, which indicates carrier-grade NAT; inbound connections to %s will likely not reach this machine. Consider using IPv6 or a tunnel if you need to accept inbound connections",
+					netip.MustParseAddr("100.64.1.2"), "203.0.113.1")
+			},
+		},
+		"private/updated": {
+			[]netip.Addr{netip.MustParseAddr("192.168.1.2")}, true, setter.ResponseUpdated,
+			[]string{"Set A (203.0.113.1) of ip4.hello"},
+			[]string{
+				"Updated A records of ip4.hello with 203.0.113.1.",
+				"The local IPv4 address 192.168.1.2 is private; inbound connections to the published IPv4 addresses will reach this machine only if your router forwards them.",
+			},
+			func(m *mocks.MockPP) {
+				m.EXPECT().NoticeOncef(pp.MessageNATPortForwarding, pp.EmojiHint,
+					"The local IPv4 address %v is private; inbound connections to %s will reach this machine only if your router forwards them. Set DETECT_NAT=false to skip this check",
+					netip.MustParseAddr("192.168.1.2"), "203.0.113.1")
+			},
+		},
+		"private/noop": {
+			[]netip.Addr{netip.MustParseAddr("192.168.1.2")}, true, setter.ResponseNoop,
+			nil, nil,
+			func(m *mocks.MockPP) {
+				m.EXPECT().NoticeOncef(pp.MessageNATPortForwarding, pp.EmojiHint,
+					"The local IPv4 address %v is private; inbound connections to %s will reach this machine only if your router forwards them. Set DETECT_NAT=false to skip this check",
+					netip.MustParseAddr("192.168.1.2"), "203.0.113.1")
+			},
+		},
+		"public": {
+			[]netip.Addr{netip.MustParseAddr("198.51.100.2")}, true, setter.ResponseUpdated,
+			[]string{"Set A (203.0.113.1) of ip4.hello"},
+			[]string{"Updated A records of ip4.hello with 203.0.113.1."},
+			nil,
+		},
+		"same": {
+			detected, true, setter.ResponseUpdated,
+			[]string{"Set A (203.0.113.1) of ip4.hello"},
+			[]string{"Updated A records of ip4.hello with 203.0.113.1."},
+			nil,
+		},
+		"same/several": {
+			[]netip.Addr{netip.MustParseAddr("192.168.1.2"), public}, true, setter.ResponseUpdated,
+			[]string{"Set A (203.0.113.1) of ip4.hello"},
+			[]string{"Updated A records of ip4.hello with 203.0.113.1."},
+			nil,
+		},
+		"probe-fails": {
+			nil, false, setter.ResponseNoop,
+			nil, nil,
+			nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockProvider := mocks.NewMockProvider(mockCtrl)
+			mockProbe := mocks.NewMockProvider(mockCtrl)
+			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
+
+			conf := initUpdateConfig()
+			conf.Provider[ipnet.IP4] = mockProvider
+			conf.NATProbe = mockProbe
+			conf.Domains[ipnet.IP4] = []domain.Domain{domain4}
+
+			gomock.InOrder(
+				mockProvider.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).Return(detected, true),
+				mockPP.EXPECT().Infof(pp.EmojiInternet, "Detected the %s address %v", "IPv4", public),
+				mockPP.EXPECT().Suppress(pp.MessageIP4DetectionFails),
+				mockProbe.EXPECT().GetIPs(gomock.Any(), gomock.Any(), ipnet.IP4).Return(tc.local, tc.localOK),
+			)
+			if tc.prepareMockPP != nil {
+				tc.prepareMockPP(mockPP)
+			}
+			mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP4, domain4, detected, params).Return(tc.response)
+
+			resp := updater.UpdateIPs(context.Background(), mockPP, conf, mockSetter)
+			require.Equal(t, updater.Message{
+				HeartbeatMessage: heartbeat.Message{OK: true, Lines: tc.monitorMessages},
+				NotifierMessage:  notifier.Message(tc.notifierMessages),
+			}, resp)
+		})
+	}
+}
+
+func TestChangeTracker(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	old := []netip.Addr{netip.MustParseAddr("203.0.113.1")}
+	flap := []netip.Addr{netip.MustParseAddr("198.51.100.1")}
+	updated := []string{"Set A (198.51.100.1) of ip4.hello"}
+	notified := []string{"Updated A records of ip4.hello with 198.51.100.1."}
+
+	mockCtrl := gomock.NewController(t)
+	mockPP := mocks.NewMockPP(mockCtrl)
+	mockProvider := mocks.NewMockProvider(mockCtrl)
+	mockSetter := mocks.NewMockSetter(mockCtrl)
+
+	conf := initUpdateConfig()
+	conf.Provider[ipnet.IP4] = mockProvider
+	conf.Domains[ipnet.IP4] = []domain.Domain{domain4}
+	conf.IPChangeConfirmations = 2
+	conf.IPChangeStableFor = time.Minute
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := updater.NewChangeTracker(func() time.Time { return now })
+	require.True(t, tracker.NextRecheck(conf).IsZero())
+
+	detect := func(ips []netip.Addr) {
+		mockProvider.EXPECT().GetIPs(gomock.Any(), mockPP, ipnet.IP4).Return(ips, true)
+		mockPP.EXPECT().Infof(pp.EmojiInternet, "Detected the %s address %v", "IPv4", ips[0])
+		mockPP.EXPECT().Suppress(pp.MessageIP4DetectionFails)
+	}
+	waiting := func(count int, stableFor time.Duration) {
+		mockPP.EXPECT().Infof(pp.EmojiAlarm,
+			"Waiting to confirm the new %s addresses %s (seen %d/%d times; unchanged for %v/%v)",
+			"IPv4", "198.51.100.1", count, 2, stableFor, time.Minute)
+	}
+	update := func(t *testing.T, hbLines, ntLines []string) {
+		t.Helper()
+		mockSetter.EXPECT().StartRound()
+		resp := tracker.UpdateIPs(context.Background(), mockPP, conf, mockSetter)
+		require.Equal(t, updater.Message{
+			HeartbeatMessage: heartbeat.Message{OK: true, Lines: hbLines},
+			NotifierMessage:  notifier.Message(ntLines),
+		}, resp)
+	}
+
+	// The first detection is published immediately.
+	detect(old)
+	mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP4, domain4, old, params).Return(setter.ResponseNoop)
+	update(t, nil, nil)
+
+	// A flap is not published.
+	detect(flap)
+	waiting(1, 0)
+	update(t, nil, nil)
+	require.Equal(t, now.Add(30*time.Second), tracker.NextRecheck(conf))
+
+	// Going back to the old addresses cancels the pending change.
+	now = now.Add(30 * time.Second)
+	detect(old)
+	mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP4, domain4, old, params).Return(setter.ResponseNoop)
+	update(t, nil, nil)
+	require.True(t, tracker.NextRecheck(conf).IsZero())
+
+	// A real change is seen twice but is not yet stable.
+	detect(flap)
+	waiting(1, 0)
+	update(t, nil, nil)
+	now = now.Add(30 * time.Second)
+	detect(flap)
+	waiting(2, 30*time.Second)
+	update(t, nil, nil)
+	require.Equal(t, now.Add(30*time.Second), tracker.NextRecheck(conf))
+
+	// The change is published after it is stable for a minute.
+	now = now.Add(30 * time.Second)
+	detect(flap)
+	mockSetter.EXPECT().SetIPs(gomock.Any(), mockPP, ipnet.IP4, domain4, flap, params).Return(setter.ResponseUpdated)
+	update(t, updated, notified)
+	require.True(t, tracker.NextRecheck(conf).IsZero())
+}
+
 func TestFinalDeleteIPsMultiple(t *testing.T) {
 	t.Parallel()
 
@@ -281,6 +673,7 @@ func TestFinalDeleteIPsMultiple(t *testing.T) {
 				conf.Provider[ipnet] = mocks.NewMockProvider(mockCtrl)
 			}
 			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
 			if tc.prepareMocks != nil {
 				tc.prepareMocks(mockPP, mockSetter)
 			}
@@ -296,6 +689,64 @@ func TestFinalDeleteIPsMultiple(t *testing.T) {
 	}
 }
 
+func TestFinalDeleteIPsDryRun(t *testing.T) {
+	t.Parallel()
+
+	params := api.RecordParams{
+		TTL:     api.TTLAuto,
+		Proxied: false,
+		Comment: recordComment,
+	}
+
+	list := api.WAFList{AccountID: "12341234", Name: "list"}
+
+	for name, tc := range map[string]struct {
+		monitorMessages  []string
+		notifierMessages []string
+		resp             setter.ResponseCode
+	}{
+		"updated": {
+			[]string{"Dry run (nothing was actually changed)", "Deleted A of ip4.hello", "Cleared list(s) 12341234/list"},
+			[]string{"Dry run (nothing was actually changed):", "Deleted A records of ip4.hello.", `Cleared WAF list(s) 12341234/list.`},
+			setter.ResponseUpdated,
+		},
+		"noop": {
+			[]string{"Dry run (nothing was actually changed)"},
+			nil,
+			setter.ResponseNoop,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			ctx := context.Background()
+
+			conf := initUpdateConfig()
+			conf.Domains = map[ipnet.Type][]domain.Domain{ipnet.IP4: {domain4}}
+			conf.WAFLists = []api.WAFList{list}
+			conf.Provider[ipnet.IP4] = mocks.NewMockProvider(mockCtrl)
+			conf.DryRun = true
+
+			mockPP := mocks.NewMockPP(mockCtrl)
+			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
+			gomock.InOrder(
+				mockSetter.EXPECT().FinalDelete(gomock.Any(), mockPP, ipnet.IP4, domain4, params).Return(tc.resp),
+				mockSetter.EXPECT().FinalClearWAFList(gomock.Any(), mockPP, list, wafListDescription).Return(tc.resp),
+			)
+			resp := updater.FinalDeleteIPs(ctx, mockPP, conf, mockSetter)
+			require.Equal(t, updater.Message{
+				HeartbeatMessage: heartbeat.Message{
+					OK:    true,
+					Lines: tc.monitorMessages,
+				},
+				NotifierMessage: notifier.Message(tc.notifierMessages),
+			}, resp)
+		})
+	}
+}
+
 func TestUpdateIPs(t *testing.T) {
 	t.Parallel()
 
@@ -601,6 +1052,7 @@ func TestUpdateIPs(t *testing.T) {
 				mockProviders[ipnet] = mockProvider
 			}
 			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
 			if tc.prepareMocks != nil {
 				tc.prepareMocks(mockPP, mockProviders, mockSetter)
 			}
@@ -816,6 +1268,7 @@ func TestFinalDeleteIPs(t *testing.T) {
 
 			mockPP := mocks.NewMockPP(mockCtrl)
 			mockSetter := mocks.NewMockSetter(mockCtrl)
+			mockSetter.EXPECT().StartRound()
 			if tc.prepareMocks != nil {
 				tc.prepareMocks(mockPP, mockSetter)
 			}